@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -128,7 +129,7 @@ roots:
 // callTool calls an MCP tool and returns the parsed JSON result.
 func callTool(t *testing.T, client *mcp.HTTPClient, name string, args map[string]any) (map[string]any, bool) {
 	t.Helper()
-	result, err := client.CallTool(name, args)
+	result, err := client.CallTool(context.Background(), name, args)
 	if err != nil {
 		t.Fatalf("Tool call %s failed: %v", name, err)
 	}
@@ -147,7 +148,7 @@ func callTool(t *testing.T, client *mcp.HTTPClient, name string, args map[string
 // callToolExpectError calls a tool and expects an error result.
 func callToolExpectError(t *testing.T, client *mcp.HTTPClient, name string, args map[string]any) string {
 	t.Helper()
-	result, err := client.CallTool(name, args)
+	result, err := client.CallTool(context.Background(), name, args)
 	if err != nil {
 		t.Fatalf("Tool call %s failed: %v", name, err)
 	}
@@ -222,7 +223,7 @@ func TestFS_E2E002_MultiRootCrossRootCopy(t *testing.T) {
 	env := setupFSTest(t, 9191, 0)
 
 	// List roots (returns JSON array, not object)
-	raw, err := env.client.CallTool("list_roots", map[string]any{})
+	raw, err := env.client.CallTool(context.Background(), "list_roots", map[string]any{})
 	if err != nil {
 		t.Fatalf("list_roots failed: %v", err)
 	}
@@ -1175,7 +1176,7 @@ func TestFS_E2E060_GlobBothPatternAndRegex(t *testing.T) {
 func TestFS_E2E061_ListRoots(t *testing.T) {
 	env := setupFSTest(t, 9250, 0)
 
-	raw, err := env.client.CallTool("list_roots", map[string]any{})
+	raw, err := env.client.CallTool(context.Background(), "list_roots", map[string]any{})
 	if err != nil {
 		t.Fatalf("list_roots failed: %v", err)
 	}
@@ -1189,7 +1190,7 @@ func TestFS_E2E061_ListRoots(t *testing.T) {
 func TestFS_E2E062_ListRootsNoPaths(t *testing.T) {
 	env := setupFSTest(t, 9251, 0)
 
-	raw, err := env.client.CallTool("list_roots", map[string]any{})
+	raw, err := env.client.CallTool(context.Background(), "list_roots", map[string]any{})
 	if err != nil {
 		t.Fatalf("list_roots failed: %v", err)
 	}
@@ -75,6 +75,7 @@ func startOrchServer(t *testing.T, configPath string, port int) *orchServer {
 	if err != nil {
 		t.Fatalf("Failed to load config %s: %v", configPath, err)
 	}
+	withCassette(cfg)
 	cfg.Port = port
 
 	store, err := storage.New(cfg.DataDir)
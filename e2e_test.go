@@ -32,6 +32,14 @@ const baseURL = "http://localhost:9090"
 var testAgent *agent.Agent
 
 func TestMain(m *testing.M) {
+	// Replaying needs a recorded cassette: skip the suite cleanly without one
+	if os.Getenv("E2E_LLM") == "replay" {
+		if _, err := os.Stat(cassettePath()); err != nil {
+			fmt.Printf("SKIP: no LLM cassette at %s; record one with make e2e-record\n", cassettePath())
+			os.Exit(0)
+		}
+	}
+
 	// Start mcp-resources as a separate HTTP server
 	mcpDBPath := "./data/e2e_test/resources.db"
 	os.MkdirAll("./data/e2e_test", 0755)
@@ -59,6 +67,7 @@ func TestMain(m *testing.M) {
 	}
 
 	// Override for tests
+	withCassette(cfg)
 	cfg.Port = 9090
 	cfg.DataDir = "./data/e2e_test"
 	cfg.MCPServers = []config.MCPServerConfig{{Name: "resources", URL: "http://localhost:8090/mcp"}}
@@ -106,6 +115,47 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// cassettePath returns the cassette file of the record and replay providers:
+// LLM_CASSETTE, or testdata/cassettes/e2e.json.
+func cassettePath() string {
+	if path := os.Getenv("LLM_CASSETTE"); path != "" {
+		return path
+	}
+	return "testdata/cassettes/e2e.json"
+}
+
+// withCassette makes the LLM requests of cfg go through the record or replay
+// provider when E2E_LLM is "record" or "replay", so that the tests can run
+// offline. All the tests of a run share the cassette file (see cassettePath):
+// record the whole suite at once.
+func withCassette(cfg *config.Config) {
+	mode := os.Getenv("E2E_LLM")
+	if mode == "" {
+		return
+	}
+	os.Setenv("LLM_CASSETTE", cassettePath())
+
+	wrap := func(models config.ModelList) config.ModelList {
+		wrapped := make(config.ModelList, len(models))
+		for i, m := range models {
+			wrapped[i] = mode + ":" + m
+		}
+		return wrapped
+	}
+	var walk func(node *config.AgentNode)
+	walk = func(node *config.AgentNode) {
+		if node == nil {
+			return
+		}
+		node.Model = wrap(node.Model)
+		for i := range node.Agents {
+			walk(&node.Agents[i])
+		}
+	}
+	cfg.LLM.Model = wrap(cfg.LLM.Model)
+	walk(cfg.Agent)
+}
+
 // waitForHTTP polls a URL until it responds (any status) or times out.
 func waitForHTTP(url string, maxAttempts int) bool {
 	for i := 0; i < maxAttempts; i++ {
@@ -20,6 +20,7 @@ type Client struct {
 	url             string
 	description     string
 	destructiveHint bool
+	outbound        auth.Outbound
 	httpClient      *http.Client
 	nextID          int
 }
@@ -36,6 +37,10 @@ func NewClient(name, url, description string, destructiveHint bool) *Client {
 	}
 }
 
+// SetAuth configures the static headers and credentials sent to the agent.
+// By default the caller's Bearer token is forwarded.
+func (c *Client) SetAuth(outbound auth.Outbound) { c.outbound = outbound }
+
 // Name returns the agent name.
 func (c *Client) Name() string { return c.name }
 
@@ -57,12 +62,7 @@ func (c *Client) FetchAgentCard(ctx context.Context) (*AgentCard, error) {
 		return nil, fmt.Errorf("failed to create agent card request: %w", err)
 	}
 
-	if token := auth.BearerToken(ctx); token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	}
-	if sid := auth.SessionID(ctx); sid != "" {
-		req.Header.Set("X-Session-ID", sid)
-	}
+	c.setHeaders(ctx, req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -161,13 +161,7 @@ func (c *Client) call(ctx context.Context, method string, params any, result any
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	// Forward Bearer token and session ID from context
-	if token := auth.BearerToken(ctx); token != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+token)
-	}
-	if sid := auth.SessionID(ctx); sid != "" {
-		httpReq.Header.Set("X-Session-ID", sid)
-	}
+	c.setHeaders(ctx, httpReq)
 
 	httpResp, err := c.httpClient.Do(httpReq)
 	if err != nil {
@@ -197,3 +191,14 @@ func (c *Client) call(ctx context.Context, method string, params any, result any
 
 	return nil
 }
+
+// setHeaders adds the configured headers, the credentials selected by the
+// auth mode and the session ID from context to an outgoing request.
+func (c *Client) setHeaders(ctx context.Context, req *http.Request) {
+	for k, v := range c.outbound.RequestHeaders(ctx) {
+		req.Header.Set(k, v)
+	}
+	if sid := auth.SessionID(ctx); sid != "" {
+		req.Header.Set("X-Session-ID", sid)
+	}
+}
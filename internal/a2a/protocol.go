@@ -54,17 +54,28 @@ type Message struct {
 	Parts []Part `json:"parts"`
 }
 
-// Part represents a content part in an A2A message.
+// Part represents a content part in an A2A message: "text" or "file".
 type Part struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+	Type string       `json:"type"`
+	Text string       `json:"text,omitempty"`
+	File *FileContent `json:"file,omitempty"`
+}
+
+// FileContent is the file of a "file" part, given by its base64-encoded
+// bytes or by URI.
+type FileContent struct {
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Bytes    string `json:"bytes,omitempty"`
+	URI      string `json:"uri,omitempty"`
 }
 
 // Task represents an A2A task.
 type Task struct {
-	ID       string     `json:"id"`
-	Status   TaskStatus `json:"status"`
-	Artifact *Artifact  `json:"artifact,omitempty"`
+	ID       string         `json:"id"`
+	Status   TaskStatus     `json:"status"`
+	Artifact *Artifact      `json:"artifact,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"` // e.g. "usage": token usage of the request
 }
 
 // TaskStatus represents the status of an A2A task.
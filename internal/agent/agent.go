@@ -2,9 +2,13 @@ package agent
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 
@@ -24,10 +28,14 @@ type Agent struct {
 	config     *config.Config
 	storage    *storage.Storage
 	mcpClient  mcp.Client
+	composite  *mcp.CompositeClient  // underlying MCP servers (tool changes)
 	llmClient  llm.Client            // primary client (backward compat)
 	llmClients map[string]llm.Client // model -> client (for orchestrated agents)
-	llmMu      sync.Mutex            // protects llmClients map
+	llmMu      sync.Mutex            // protects llmClients and rateLimiters
 	a2aClients map[string]*a2a.Client
+	events     eventHub
+
+	rateLimiters map[string]*llm.RateLimiter // provider -> limiter shared by its models
 }
 
 // New creates a new agent instance.
@@ -37,6 +45,8 @@ func New(cfg *config.Config, store *storage.Storage) *Agent {
 		storage:    store,
 		a2aClients: make(map[string]*a2a.Client),
 		llmClients: make(map[string]llm.Client),
+
+		rateLimiters: make(map[string]*llm.RateLimiter),
 	}
 }
 
@@ -45,35 +55,74 @@ func (a *Agent) Start() error {
 	// Create MCP clients from config (one per server entry)
 	var namedClients []mcp.NamedClient
 	for _, serverCfg := range a.config.MCPServers {
+		toolPrefix := serverCfg.ToolPrefix
+		if toolPrefix == "" && a.config.MCPNamespaceTools {
+			toolPrefix = mcp.NamespacePrefix(serverCfg.Name)
+		}
 		client, err := mcp.NewClient(mcp.ClientConfig{
-			URL:     serverCfg.URL,
-			Command: serverCfg.Command,
-			Args:    serverCfg.Args,
+			Name:            serverCfg.Name,
+			URL:             serverCfg.URL,
+			Auth:            serverCfg.Outbound(),
+			Command:         serverCfg.Command,
+			Args:            serverCfg.Args,
+			Env:             serverCfg.Env,
+			EnvPassthrough:  serverCfg.EnvPassthrough,
+			Dir:             serverCfg.Cwd,
+			ShutdownTimeout: serverCfg.ShutdownTimeout,
+			Sampling:        a.samplingHandler(serverCfg.Name, serverCfg.Sampling),
+			Optional:        !serverCfg.IsRequired(),
 		})
 		if err != nil {
 			return fmt.Errorf("failed to create MCP client %q: %w", serverCfg.Name, err)
 		}
-		namedClients = append(namedClients, mcp.NamedClient{Name: serverCfg.Name, Client: client})
+		namedClients = append(namedClients, mcp.NamedClient{
+			Name:            serverCfg.Name,
+			Client:          client,
+			RefreshInterval: max(serverCfg.RefreshInterval, 0),
+			MaxConcurrency:  serverCfg.MaxConcurrency,
+			HealthInterval:  max(serverCfg.HealthInterval, 0),
+			Optional:        !serverCfg.IsRequired(),
+			ToolPrefix:      toolPrefix,
+		})
 	}
 
 	compositeClient := mcp.NewCompositeClient(namedClients)
 	if err := compositeClient.Start(); err != nil {
 		return fmt.Errorf("failed to start MCP clients: %w", err)
 	}
-	a.mcpClient = compositeClient
+	a.composite = compositeClient
+	a.mcpClient = newResourceClient(context.Background(), compositeClient)
+
+	// Register custom OpenAI-compatible providers before creating LLM clients
+	for _, p := range a.config.LLMProviders {
+		err := llm.RegisterProvider(llm.Provider{
+			Name:        p.Name,
+			BaseURL:     p.BaseURL,
+			APIKeyEnv:   p.APIKeyEnv,
+			Headers:     p.Headers,
+			Tools:       p.SupportsTools(),
+			JSONMode:    p.JSONMode,
+			PromptCache: p.PromptCache,
+			Vision:      p.Vision,
+		})
+		if err != nil {
+			a.mcpClient.Stop()
+			return fmt.Errorf("failed to register LLM provider: %w", err)
+		}
+	}
 
 	// Initialize primary LLM client
-	llmClient, err := llm.NewClient(a.config.LLM.Model)
+	llmClient, err := a.getLLMClient(a.config.LLM.Model)
 	if err != nil {
 		a.mcpClient.Stop()
 		return fmt.Errorf("failed to initialize LLM client: %w", err)
 	}
 	a.llmClient = llmClient
-	a.llmClients[a.config.LLM.Model] = llmClient
 
 	// Initialize A2A clients from top-level config
 	for _, agentCfg := range a.config.A2A {
 		client := a2a.NewClient(agentCfg.Name, agentCfg.URL, agentCfg.Description, agentCfg.DestructiveHint)
+		client.SetAuth(agentCfg.Outbound())
 		a.a2aClients[agentCfg.Name] = client
 	}
 
@@ -92,6 +141,7 @@ func (a *Agent) initA2AFromTree(node *config.AgentNode) {
 	for _, agentCfg := range node.A2A {
 		if _, exists := a.a2aClients[agentCfg.Name]; !exists {
 			client := a2a.NewClient(agentCfg.Name, agentCfg.URL, agentCfg.Description, agentCfg.DestructiveHint)
+			client.SetAuth(agentCfg.Outbound())
 			a.a2aClients[agentCfg.Name] = client
 		}
 	}
@@ -99,6 +149,7 @@ func (a *Agent) initA2AFromTree(node *config.AgentNode) {
 	if node.Type == "a2a" && node.URL != "" {
 		if _, exists := a.a2aClients[node.Name]; !exists {
 			client := a2a.NewClient(node.Name, node.URL, node.Description, node.DestructiveHint)
+			client.SetAuth(node.Outbound())
 			a.a2aClients[node.Name] = client
 		}
 	}
@@ -121,6 +172,7 @@ type ProcessResult struct {
 	WaitingApproval bool                          `json:"waiting_approval"`
 	Approval        *conversation.PendingApproval `json:"approval,omitempty"`
 	AuthRequired    bool                          `json:"auth_required"`
+	Usage           *llm.Usage                    `json:"usage,omitempty"` // summed over the LLM calls made for the request
 }
 
 // isAuthRequiredError checks if an error is an MCP AuthRequiredError.
@@ -147,12 +199,24 @@ func (a *Agent) StartConversation(ctx context.Context) (*conversation.Conversati
 	return conv, nil
 }
 
-// ProcessMessage handles a user message using the LLM.
-func (a *Agent) ProcessMessage(ctx context.Context, conv *conversation.Conversation, userMessage string) (*ProcessResult, error) {
+// ProcessMessage handles a user message using the LLM. The attachments are
+// files already saved in storage. The result reports the token usage of the
+// LLM calls made.
+func (a *Agent) ProcessMessage(ctx context.Context, conv *conversation.Conversation, userMessage string, attachments []conversation.Attachment) (*ProcessResult, error) {
+	usage := &usageTotal{}
+	result, err := a.processMessage(withUsage(ctx, usage), conv, userMessage, attachments)
+	if result != nil {
+		result.Usage = usage.total()
+	}
+	return result, err
+}
+
+func (a *Agent) processMessage(ctx context.Context, conv *conversation.Conversation, userMessage string, attachments []conversation.Attachment) (*ProcessResult, error) {
 	// Enrich context with conversation's session ID for downstream calls
 	if conv.SessionID != "" && auth.SessionID(ctx) == "" {
 		ctx = auth.WithSessionID(ctx, conv.SessionID)
 	}
+	ctx = a.withEvents(ctx, conv.ID)
 
 	if conv.Status == conversation.StatusWaitingApproval {
 		return &ProcessResult{
@@ -162,7 +226,7 @@ func (a *Agent) ProcessMessage(ctx context.Context, conv *conversation.Conversat
 		}, nil
 	}
 
-	conv.AddMessage(conversation.RoleUser, userMessage)
+	conv.AddUserMessage(userMessage, attachments)
 
 	if a.isSimpleAgent() {
 		return a.processSimpleMessage(ctx, conv)
@@ -201,26 +265,49 @@ func (a *Agent) processSimpleMessage(ctx context.Context, conv *conversation.Con
 	const maxToolIterations = 10
 	tools := a.getAllTools()
 
+	var resources []string
+	var generation config.GenerationConfig
+	if a.config.Agent != nil {
+		resources = a.config.Agent.Resources
+		generation = a.config.Agent.Generation
+	}
+	opts := a.requestOptions(generation)
+	prompt, err := a.withResources(ctx, conv, a.config.Prompt, resources)
+	if err != nil {
+		errorMsg := fmt.Sprintf("Resource error: %v", err)
+		conv.AddMessage(conversation.RoleAssistant, errorMsg)
+		_ = a.storage.SaveConversation(conv)
+		return &ProcessResult{Response: errorMsg}, nil
+	}
+
 	for range maxToolIterations {
-		llmMessages := a.convertToLLMMessages(conv)
+		llmMessages := a.contextMessages(ctx, conv)
 
-		response, err := a.llmClient.GenerateWithTools(ctx, a.config.Prompt, llmMessages, tools)
+		response, err := a.llmClient.GenerateWithTools(ctx, prompt, llmMessages, tools, opts)
 		if err != nil {
 			errorMsg := fmt.Sprintf("LLM error: %v", err)
 			conv.AddMessage(conversation.RoleAssistant, errorMsg)
 			_ = a.storage.SaveConversation(conv)
 			return &ProcessResult{Response: errorMsg}, nil
 		}
+		a.logUsage(ctx, conv.ID, response)
 
 		// Text response → done
 		if response.ToolCall == nil {
-			conv.AddMessage(conversation.RoleAssistant, response.Text)
+			conv.AddModelMessage(response.Text, response.Model)
 			if err := a.storage.SaveConversation(conv); err != nil {
 				return nil, err
 			}
 			return &ProcessResult{Response: response.Text}, nil
 		}
 
+		// Invalid arguments → report to the LLM, continue loop
+		if verr := validateToolCall(tools, response.ToolCall); verr != nil {
+			recordInvalidToolCall(conv, response.ToolCall, verr)
+			continue
+		}
+
+		toolCallID := response.ToolCall.ID
 		toolName := response.ToolCall.Name
 		toolArgs := response.ToolCall.Arguments
 
@@ -239,7 +326,8 @@ func (a *Agent) processSimpleMessage(ctx context.Context, conv *conversation.Con
 			if client.DestructiveHint() {
 				description := fmt.Sprintf("**DELEGATE to A2A Agent: %s**\n\nMessage: %v", agentName, toolArgs["message"])
 				approval := conv.SetWaitingApproval(toolName, toolArgs, description)
-				conv.AddToolCall(toolName, toolArgs)
+				approval.ToolCallID = toolCallID
+				conv.AddToolCall(toolCallID, toolName, toolArgs)
 				responseText := fmt.Sprintf("This action requires approval:\n\n%s\n\nPlease approve or reject using the approval UUID: %s", description, approval.UUID)
 				conv.AddMessage(conversation.RoleAssistant, responseText)
 				_ = a.storage.SaveConversation(conv)
@@ -248,10 +336,10 @@ func (a *Agent) processSimpleMessage(ctx context.Context, conv *conversation.Con
 
 			// Non-destructive A2A → execute, continue loop
 			message, _ := toolArgs["message"].(string)
-			conv.AddToolCall(toolName, toolArgs)
+			conv.AddToolCall(toolCallID, toolName, toolArgs)
 			task, err := client.SendMessage(ctx, message)
 			if err != nil {
-				conv.AddToolResult(toolName, fmt.Sprintf("A2A error: %v", err), true)
+				conv.AddToolResult(toolCallID, toolName, fmt.Sprintf("A2A error: %v", err), true)
 				continue
 			}
 
@@ -262,6 +350,7 @@ func (a *Agent) processSimpleMessage(ctx context.Context, conv *conversation.Con
 					description += *task.Status.Message
 				}
 				approval := conv.SetWaitingApproval(toolName, toolArgs, description)
+				approval.ToolCallID = toolCallID
 				approval.RemoteTaskID = task.ID
 				approval.RemoteAgentName = client.Name()
 				responseText := fmt.Sprintf("This action requires approval:\n\n%s\n\nPlease approve or reject using the approval UUID: %s", description, approval.UUID)
@@ -282,7 +371,7 @@ func (a *Agent) processSimpleMessage(ctx context.Context, conv *conversation.Con
 			}
 
 			resultText := extractTaskText(task)
-			conv.AddToolResult(toolName, resultText, task.Status.State == "failed")
+			conv.AddToolResult(toolCallID, toolName, resultText, task.Status.State == "failed")
 			continue
 		}
 
@@ -297,9 +386,10 @@ func (a *Agent) processSimpleMessage(ctx context.Context, conv *conversation.Con
 
 		// Destructive MCP tool → approval, break loop
 		if tool.DestructiveHint {
-			description := a.formatApprovalDescription(tool.Name, toolArgs)
+			description := a.formatApprovalDescription(tool, toolArgs)
 			approval := conv.SetWaitingApproval(tool.Name, toolArgs, description)
-			conv.AddToolCall(tool.Name, toolArgs)
+			approval.ToolCallID = toolCallID
+			conv.AddToolCall(toolCallID, tool.Name, toolArgs)
 			responseText := fmt.Sprintf("This action requires approval:\n\n%s\n\nPlease approve or reject using the approval UUID: %s", description, approval.UUID)
 			conv.AddMessage(conversation.RoleAssistant, responseText)
 			_ = a.storage.SaveConversation(conv)
@@ -307,7 +397,7 @@ func (a *Agent) processSimpleMessage(ctx context.Context, conv *conversation.Con
 		}
 
 		// Non-destructive MCP tool → execute, continue loop
-		conv.AddToolCall(toolName, toolArgs)
+		conv.AddToolCall(toolCallID, toolName, toolArgs)
 		result, err := a.mcpClient.CallTool(ctx, toolName, toolArgs)
 		if err != nil {
 			if isAuthRequiredError(err) {
@@ -316,14 +406,10 @@ func (a *Agent) processSimpleMessage(ctx context.Context, conv *conversation.Con
 				_ = a.storage.SaveConversation(conv)
 				return &ProcessResult{Response: response, AuthRequired: true}, nil
 			}
-			conv.AddToolResult(toolName, fmt.Sprintf("Tool execution failed: %v", err), true)
+			conv.AddToolResult(toolCallID, toolName, fmt.Sprintf("Tool execution failed: %v", err), true)
 			continue
 		}
-		var resultText string
-		if len(result.Content) > 0 {
-			resultText = result.Content[0].Text
-		}
-		conv.AddToolResult(toolName, resultText, result.IsError)
+		conv.AddToolResultContent(toolCallID, toolName, result)
 		continue
 	}
 
@@ -334,12 +420,13 @@ func (a *Agent) processSimpleMessage(ctx context.Context, conv *conversation.Con
 	return &ProcessResult{Response: response}, nil
 }
 
-// getAllTools returns MCP tools + synthetic A2A tools.
+// getAllTools returns MCP tools + synthetic A2A tools, in a stable order.
 func (a *Agent) getAllTools() []mcp.Tool {
-	tools := a.mcpClient.Tools()
+	tools := slices.Clone(a.mcpClient.Tools())
 
-	// Add A2A agents as synthetic tools
-	for _, client := range a.a2aClients {
+	// Add A2A agents as synthetic tools, by name
+	for _, name := range slices.Sorted(maps.Keys(a.a2aClients)) {
+		client := a.a2aClients[name]
 		tool := mcp.Tool{
 			Name:        a2aToolPrefix + client.Name(),
 			Description: fmt.Sprintf("Delegate task to A2A agent '%s'. %s", client.Name(), client.Description()),
@@ -362,29 +449,83 @@ func (a *Agent) getAllTools() []mcp.Tool {
 }
 
 // convertToLLMMessages converts conversation messages to LLM format.
-// Tool call records are skipped, tool results are included as user messages.
-// Consecutive same-role messages are merged (Gemini requires alternating user/model).
-func (a *Agent) convertToLLMMessages(conv *conversation.Conversation) []llm.Message {
-	var messages []llm.Message
+// Tool call records are sent as native tool calls, each immediately followed by
+// its result (matched by tool call ID). Calls that never got a result (e.g.
+// rejected approvals) are skipped; results without an ID (older conversations)
+// are included as user messages. Summaries are sent as user messages, and
+// tool results are capped at llm.context.max_tool_result_tokens.
+// Consecutive plain-text same-role messages are merged (Gemini requires alternating user/model).
+func (a *Agent) convertToLLMMessages(history []conversation.Message) []llm.Message {
+	return joinLLMParts(a.llmParts(history))
+}
+
+// llmParts converts each conversation message to its LLM messages, before
+// merging: parts[i] holds the messages of history[i], empty for the messages
+// that are not sent. See convertToLLMMessages.
+func (a *Agent) llmParts(history []conversation.Message) [][]llm.Message {
+	maxResultTokens := a.config.LLM.Context.MaxToolResultTokens
+
+	// Index tool results by tool call ID so each call can be paired with its result
+	results := make(map[string]*conversation.ToolCall)
+	for _, msg := range history {
+		if msg.Role == conversation.RoleTool && msg.ToolCall != nil && msg.ToolCall.ID != "" {
+			results[msg.ToolCall.ID] = msg.ToolCall
+		}
+	}
+
+	parts := make([][]llm.Message, len(history))
+	paired := make(map[string]bool)
 
-	for _, msg := range conv.Messages {
+	for i, msg := range history {
 		// Skip system messages (handled separately as system instruction)
 		if msg.Role == conversation.RoleSystem {
 			continue
 		}
 
-		var role, content string
-
-		// Tool call records: skip (exposing them causes LLMs to mimic the format)
+		// Tool call records → native tool call + result
 		if msg.Role == conversation.RoleAssistant && msg.Content == "" && msg.ToolCall != nil {
+			result, ok := results[msg.ToolCall.ID]
+			if msg.ToolCall.ID == "" || !ok {
+				continue
+			}
+			paired[msg.ToolCall.ID] = true
+			parts[i] = []llm.Message{
+				{
+					Role: "model",
+					ToolCalls: []llm.ToolCall{{
+						ID:        msg.ToolCall.ID,
+						Name:      msg.ToolCall.Name,
+						Arguments: msg.ToolCall.Arguments,
+					}},
+				},
+				{
+					Role: "tool",
+					ToolResult: truncateToolResult(&llm.ToolResult{
+						ToolCallID: result.ID,
+						Name:       result.Name,
+						Content:    result.Result,
+						Blocks:     result.Content,
+						IsError:    result.IsError,
+					}, maxResultTokens),
+				},
+			}
 			continue
 		}
 
-		// Tool results → user message
+		var role, content string
+
 		if msg.Role == conversation.RoleTool && msg.ToolCall != nil {
+			// Already sent right after its tool call
+			if paired[msg.ToolCall.ID] {
+				continue
+			}
+			// Tool results without a matching call → user message
 			role = "user"
-			content = fmt.Sprintf("Tool %q returned:\n%s", msg.ToolCall.Name, msg.ToolCall.Result)
-		} else if msg.Content != "" {
+			content = fmt.Sprintf("Tool %q returned:\n%s", msg.ToolCall.Name, truncateText(msg.ToolCall.Result, maxResultTokens))
+		} else if msg.Role == conversation.RoleSummary {
+			role = "user"
+			content = "Summary of the earlier conversation:\n\n" + msg.Content
+		} else if msg.Content != "" || len(msg.Attachments) > 0 {
 			role = string(msg.Role)
 			if msg.Role == conversation.RoleAssistant {
 				role = "model"
@@ -394,43 +535,101 @@ func (a *Agent) convertToLLMMessages(conv *conversation.Conversation) []llm.Mess
 			continue
 		}
 
-		// Merge consecutive same-role messages (Gemini requires alternating user/model)
-		if len(messages) > 0 && messages[len(messages)-1].Role == role {
-			messages[len(messages)-1].Content += "\n\n" + content
-		} else {
-			messages = append(messages, llm.Message{Role: role, Content: content})
-		}
+		parts[i] = []llm.Message{{Role: role, Content: content, Attachments: a.llmAttachments(msg.Attachments)}}
 	}
 
+	return parts
+}
+
+// joinLLMParts concatenates the LLM messages of llmParts, merging consecutive
+// same-role text messages (Gemini requires alternating user/model).
+func joinLLMParts(parts [][]llm.Message) []llm.Message {
+	var messages []llm.Message
+	for _, part := range parts {
+		for _, m := range part {
+			n := len(messages)
+			if n == 0 || m.ToolCalls != nil || m.ToolResult != nil || messages[n-1].Role != m.Role || messages[n-1].ToolCalls != nil {
+				messages = append(messages, m)
+				continue
+			}
+			switch {
+			case m.Content == "":
+			case messages[n-1].Content == "":
+				messages[n-1].Content = m.Content
+			default:
+				messages[n-1].Content += "\n\n" + m.Content
+			}
+			// Clipped: the parts may be joined again
+			messages[n-1].Attachments = append(slices.Clip(messages[n-1].Attachments), m.Attachments...)
+		}
+	}
 	return messages
 }
 
+// llmAttachments loads the content of message attachments from storage.
+// Attachments that cannot be read are left out.
+func (a *Agent) llmAttachments(attachments []conversation.Attachment) []llm.Attachment {
+	var result []llm.Attachment
+	for _, att := range attachments {
+		data, err := a.storage.LoadFile(att.ID)
+		if err != nil {
+			log.Printf("WARN: attachment %s not sent: %v", att.ID, err)
+			continue
+		}
+		result = append(result, llm.Attachment{
+			Name:     att.Name,
+			MimeType: att.MimeType,
+			Data:     base64.StdEncoding.EncodeToString(data),
+		})
+	}
+	return result
+}
+
+// lastUserAttachments returns the attachments of the latest user message,
+// sent to each LLM node of a pipeline along with the node's input.
+func (a *Agent) lastUserAttachments(conv *conversation.Conversation) []llm.Attachment {
+	for i := len(conv.Messages) - 1; i >= 0; i-- {
+		if msg := conv.Messages[i]; msg.Role == conversation.RoleUser && len(msg.Attachments) > 0 {
+			return a.llmAttachments(msg.Attachments)
+		}
+	}
+	return nil
+}
+
 // formatApprovalDescription creates a human-readable description of the pending tool call.
-func (a *Agent) formatApprovalDescription(toolName string, args map[string]any) string {
+// Known tools are matched by their name on the server; namespaced tools also
+// show their qualified name.
+func (a *Agent) formatApprovalDescription(tool *mcp.Tool, args map[string]any) string {
 	argsJSON, err := json.MarshalIndent(args, "", "  ")
 	if err != nil {
 		argsJSON = []byte(fmt.Sprintf("%v", args))
 	}
 
-	switch toolName {
+	var description string
+	switch tool.BaseName() {
 	case "resources_add":
-		return fmt.Sprintf("**ADD Resource**\n\nName: %v\nValue: %v", args["name"], args["value"])
+		description = fmt.Sprintf("**ADD Resource**\n\nName: %v\nValue: %v", args["name"], args["value"])
 	case "resources_remove":
 		if id, ok := args["id"]; ok && id != "" {
-			return fmt.Sprintf("**REMOVE Resource**\n\nID: %v", id)
-		}
-		if pattern, ok := args["pattern"]; ok && pattern != "" {
-			return fmt.Sprintf("**REMOVE Resources**\n\nPattern: %v\n\n⚠️ This will remove ALL resources matching this pattern!", pattern)
+			description = fmt.Sprintf("**REMOVE Resource**\n\nID: %v", id)
+		} else if pattern, ok := args["pattern"]; ok && pattern != "" {
+			description = fmt.Sprintf("**REMOVE Resources**\n\nPattern: %v\n\n⚠️ This will remove ALL resources matching this pattern!", pattern)
+		} else {
+			description = fmt.Sprintf("**REMOVE Resource**\n\nArgs: %s", argsJSON)
 		}
-		return fmt.Sprintf("**REMOVE Resource**\n\nArgs: %s", argsJSON)
 	default:
-		return fmt.Sprintf("**%s**\n\nArgs: %s", strings.ToUpper(toolName), argsJSON)
+		return fmt.Sprintf("**%s**\n\nArgs: %s", strings.ToUpper(tool.Name), argsJSON)
+	}
+
+	if tool.OriginalName != "" {
+		description += fmt.Sprintf("\n\nTool: %s", tool.Name)
 	}
+	return description
 }
 
 // executeToolAndRespond executes an MCP tool and creates a response.
 func (a *Agent) executeToolAndRespond(ctx context.Context, conv *conversation.Conversation, toolName string, args map[string]any) (*ProcessResult, error) {
-	conv.AddToolCall(toolName, args)
+	conv.AddToolCall("", toolName, args)
 
 	result, err := a.mcpClient.CallTool(ctx, toolName, args)
 	if err != nil {
@@ -448,7 +647,7 @@ func (a *Agent) executeToolAndRespond(ctx context.Context, conv *conversation.Co
 			return &ProcessResult{Response: response, AuthRequired: true}, nil
 		}
 		errorMsg := fmt.Sprintf("Tool execution failed: %v", err)
-		conv.AddToolResult(toolName, errorMsg, true)
+		conv.AddToolResult("", toolName, errorMsg, true)
 		conv.AddMessage(conversation.RoleAssistant, errorMsg)
 		if saveErr := a.storage.SaveConversation(conv); saveErr != nil {
 			return nil, saveErr
@@ -456,13 +655,8 @@ func (a *Agent) executeToolAndRespond(ctx context.Context, conv *conversation.Co
 		return &ProcessResult{Response: errorMsg, WaitingApproval: false}, nil
 	}
 
-	// Extract result text
-	var resultText string
-	if len(result.Content) > 0 {
-		resultText = result.Content[0].Text
-	}
-
-	conv.AddToolResult(toolName, resultText, result.IsError)
+	resultText := result.AsText()
+	conv.AddToolResultContent("", toolName, result)
 
 	// Create response
 	var response string
@@ -486,12 +680,12 @@ func (a *Agent) executeA2AAndRespond(ctx context.Context, conv *conversation.Con
 	toolName := a2aToolPrefix + client.Name()
 	message, _ := args["message"].(string)
 
-	conv.AddToolCall(toolName, args)
+	conv.AddToolCall("", toolName, args)
 
 	task, err := client.SendMessage(ctx, message)
 	if err != nil {
 		errorMsg := fmt.Sprintf("A2A agent '%s' error: %v", client.Name(), err)
-		conv.AddToolResult(toolName, errorMsg, true)
+		conv.AddToolResult("", toolName, errorMsg, true)
 		conv.AddMessage(conversation.RoleAssistant, errorMsg)
 		if saveErr := a.storage.SaveConversation(conv); saveErr != nil {
 			return nil, saveErr
@@ -550,7 +744,7 @@ func (a *Agent) executeA2AAndRespond(ctx context.Context, conv *conversation.Con
 	}
 
 	isError := task.Status.State == "failed"
-	conv.AddToolResult(toolName, resultText, isError)
+	conv.AddToolResult("", toolName, resultText, isError)
 
 	var response string
 	if isError {
@@ -568,8 +762,18 @@ func (a *Agent) executeA2AAndRespond(ctx context.Context, conv *conversation.Con
 	return &ProcessResult{Response: response, WaitingApproval: false}, nil
 }
 
-// ResolveApproval handles an approval response.
+// ResolveApproval handles an approval response. The result reports the token
+// usage of the LLM calls made to continue the conversation.
 func (a *Agent) ResolveApproval(ctx context.Context, approvalUUID string, approved bool) (*conversation.Conversation, *ProcessResult, error) {
+	usage := &usageTotal{}
+	conv, result, err := a.resolveApproval(withUsage(ctx, usage), approvalUUID, approved)
+	if result != nil {
+		result.Usage = usage.total()
+	}
+	return conv, result, err
+}
+
+func (a *Agent) resolveApproval(ctx context.Context, approvalUUID string, approved bool) (*conversation.Conversation, *ProcessResult, error) {
 	conv, err := a.storage.FindConversationByApprovalUUID(approvalUUID)
 	if err != nil {
 		return nil, nil, err
@@ -579,6 +783,7 @@ func (a *Agent) ResolveApproval(ctx context.Context, approvalUUID string, approv
 	if conv.SessionID != "" && auth.SessionID(ctx) == "" {
 		ctx = auth.WithSessionID(ctx, conv.SessionID)
 	}
+	ctx = a.withEvents(ctx, conv.ID)
 
 	if conv.PendingApproval == nil {
 		return nil, nil, fmt.Errorf("no pending approval found")
@@ -586,6 +791,7 @@ func (a *Agent) ResolveApproval(ctx context.Context, approvalUUID string, approv
 
 	toolName := conv.PendingApproval.ToolName
 	toolArgs := conv.PendingApproval.ToolArgs
+	toolCallID := conv.PendingApproval.ToolCallID
 	remoteTaskID := conv.PendingApproval.RemoteTaskID
 	remoteAgentName := conv.PendingApproval.RemoteAgentName
 	pipelineState := conv.PipelineState
@@ -633,6 +839,7 @@ func (a *Agent) ResolveApproval(ctx context.Context, approvalUUID string, approv
 				description += *task.Status.Message
 			}
 			approval := conv.SetWaitingApproval(toolName, toolArgs, description)
+			approval.ToolCallID = toolCallID
 			approval.RemoteTaskID = task.ID
 			approval.RemoteAgentName = client.Name()
 			responseText := fmt.Sprintf("This action requires approval:\n\n%s\n\nPlease approve or reject using the approval UUID: %s", description, approval.UUID)
@@ -656,7 +863,7 @@ func (a *Agent) ResolveApproval(ctx context.Context, approvalUUID string, approv
 
 		resultText := extractTaskText(task)
 		isError := task.Status.State == "failed"
-		conv.AddToolResult(toolName, resultText, isError)
+		conv.AddToolResult(toolCallID, toolName, resultText, isError)
 
 		// Pipeline: resume from paused node
 		if pipelineState != nil {
@@ -708,10 +915,10 @@ func (a *Agent) ResolveApproval(ctx context.Context, approvalUUID string, approv
 			message, _ := toolArgs["message"].(string)
 			task, err := client.SendMessage(ctx, message)
 			if err != nil {
-				conv.AddToolResult(toolName, fmt.Sprintf("A2A error: %v", err), true)
+				conv.AddToolResult(toolCallID, toolName, fmt.Sprintf("A2A error: %v", err), true)
 			} else {
 				resultText := extractTaskText(task)
-				conv.AddToolResult(toolName, resultText, task.Status.State == "failed")
+				conv.AddToolResult(toolCallID, toolName, resultText, task.Status.State == "failed")
 			}
 		} else {
 			result, err := a.mcpClient.CallTool(ctx, toolName, toolArgs)
@@ -727,13 +934,9 @@ func (a *Agent) ResolveApproval(ctx context.Context, approvalUUID string, approv
 					_ = a.storage.SaveConversation(conv)
 					return conv, &ProcessResult{Response: response, AuthRequired: true}, nil
 				}
-				conv.AddToolResult(toolName, fmt.Sprintf("Tool execution failed: %v", err), true)
+				conv.AddToolResult(toolCallID, toolName, fmt.Sprintf("Tool execution failed: %v", err), true)
 			} else {
-				var resultText string
-				if len(result.Content) > 0 {
-					resultText = result.Content[0].Text
-				}
-				conv.AddToolResult(toolName, resultText, result.IsError)
+				conv.AddToolResultContent(toolCallID, toolName, result)
 			}
 		}
 
@@ -759,7 +962,7 @@ func (a *Agent) ResolveApproval(ctx context.Context, approvalUUID string, approv
 			return nil, nil, fmt.Errorf("A2A execution failed: %w", err)
 		}
 		toolResult = extractTaskText(task)
-		conv.AddToolResult(toolName, toolResult, task.Status.State == "failed")
+		conv.AddToolResult(toolCallID, toolName, toolResult, task.Status.State == "failed")
 	} else {
 		result, err := a.mcpClient.CallTool(ctx, toolName, toolArgs)
 		if err != nil {
@@ -776,10 +979,8 @@ func (a *Agent) ResolveApproval(ctx context.Context, approvalUUID string, approv
 			}
 			return nil, nil, fmt.Errorf("tool execution failed: %w", err)
 		}
-		if len(result.Content) > 0 {
-			toolResult = result.Content[0].Text
-		}
-		conv.AddToolResult(toolName, toolResult, result.IsError)
+		toolResult = result.AsText()
+		conv.AddToolResultContent(toolCallID, toolName, result)
 	}
 
 	// Resume the pipeline from the paused node
@@ -814,6 +1015,21 @@ func (a *Agent) GetConversation(id string) (*conversation.Conversation, error) {
 	return a.storage.LoadConversation(id)
 }
 
+// SaveFile stores an uploaded file, to be sent as a message attachment.
+func (a *Agent) SaveFile(name, mimeType string, data []byte) (conversation.Attachment, error) {
+	return a.storage.SaveFile(name, mimeType, data)
+}
+
+// DeleteFile removes an uploaded file.
+func (a *Agent) DeleteFile(id string) error {
+	return a.storage.DeleteFile(id)
+}
+
+// GetFile returns the metadata of an uploaded file.
+func (a *Agent) GetFile(id string) (conversation.Attachment, error) {
+	return a.storage.File(id)
+}
+
 // ListConversations returns all conversations.
 func (a *Agent) ListConversations() ([]*conversation.Conversation, error) {
 	return a.storage.ListConversations()
@@ -823,3 +1039,19 @@ func (a *Agent) ListConversations() ([]*conversation.Conversation, error) {
 func (a *Agent) GetTools() []mcp.Tool {
 	return a.getAllTools()
 }
+
+// GetMCPHealth returns the connection state of every MCP server.
+func (a *Agent) GetMCPHealth() []mcp.ServerHealth {
+	if a.composite == nil {
+		return nil
+	}
+	return a.composite.Health()
+}
+
+// GetToolChanges returns the most recent MCP tool list changes, oldest first.
+func (a *Agent) GetToolChanges() []mcp.ToolChange {
+	if a.composite == nil {
+		return nil
+	}
+	return a.composite.ToolChanges()
+}
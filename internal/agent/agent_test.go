@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"agent-stop-and-go/internal/a2a"
+	"agent-stop-and-go/internal/config"
+	"agent-stop-and-go/internal/conversation"
+	"agent-stop-and-go/internal/llm"
+	"agent-stop-and-go/internal/mcp"
+	"agent-stop-and-go/internal/storage"
+)
+
+// testModel is the model name under which newTestAgent registers its LLM.
+const testModel = "test:scripted"
+
+// scriptedLLM is an llm.Client answering with its responses in order. It
+// records the messages and options of every request.
+type scriptedLLM struct {
+	mu        sync.Mutex
+	responses []*llm.Response
+	requests  [][]llm.Message
+	options   []llm.RequestOptions
+}
+
+func (c *scriptedLLM) GenerateWithTools(_ context.Context, _ string, messages []llm.Message, _ []mcp.Tool, opts llm.RequestOptions) (*llm.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, slices.Clone(messages))
+	c.options = append(c.options, opts)
+	if len(c.responses) == 0 {
+		return nil, fmt.Errorf("no scripted response left")
+	}
+	resp := c.responses[0]
+	c.responses = c.responses[1:]
+	return resp, nil
+}
+
+// fakeMCP is an MCP client serving a fixed tool list. Tool calls return the
+// tool name.
+type fakeMCP struct {
+	mcp.NopClient
+	tools []mcp.Tool
+}
+
+func (c *fakeMCP) Tools() []mcp.Tool { return c.tools }
+
+func (c *fakeMCP) GetTool(name string) *mcp.Tool {
+	for i := range c.tools {
+		if c.tools[i].Name == name {
+			return &c.tools[i]
+		}
+	}
+	return nil
+}
+
+func (c *fakeMCP) CallTool(_ context.Context, name string, _ map[string]any) (*mcp.CallToolResult, error) {
+	return &mcp.CallToolResult{Content: []mcp.ContentBlock{{Type: "text", Text: name + " done"}}}, nil
+}
+
+// newTestAgent returns an agent using the given tools and answering with
+// model, registered as testModel and as llm.model.
+func newTestAgent(t *testing.T, model llm.Client, tools ...mcp.Tool) *Agent {
+	t.Helper()
+	store, err := storage.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(&config.Config{LLM: config.LLMConfig{Model: config.ModelList{testModel}}}, store)
+	a.mcpClient = &fakeMCP{tools: tools}
+	a.llmClient = model
+	a.llmClients[testModel] = model
+	return a
+}
+
+func TestGetAllTools_Order(t *testing.T) {
+	a := newTestAgent(t, &scriptedLLM{}, mcp.Tool{Name: "resources_list"}, mcp.Tool{Name: "resources_add"})
+	for _, name := range []string{"zeta", "alpha", "mid"} {
+		a.a2aClients[name] = a2a.NewClient(name, "http://localhost", "", false)
+	}
+
+	want := []string{"resources_list", "resources_add", "a2a_alpha", "a2a_mid", "a2a_zeta"}
+	for range 10 {
+		var names []string
+		for _, tool := range a.getAllTools() {
+			names = append(names, tool.Name)
+		}
+		if !slices.Equal(names, want) {
+			t.Fatalf("tools = %v, want %v", names, want)
+		}
+	}
+	if got := len(a.mcpClient.Tools()); got != 2 {
+		t.Errorf("MCP client has %d tools after getAllTools, want 2", got)
+	}
+}
+
+func TestConvertToLLMMessages_AttachmentRoles(t *testing.T) {
+	a := newTestAgent(t, &scriptedLLM{})
+	file, err := a.SaveFile("chart.png", "image/png", []byte("\x89PNG\r\n\x1a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := []conversation.Message{
+		{Role: conversation.RoleUser, Content: "draw a chart", Attachments: []conversation.Attachment{file}},
+		{Role: conversation.RoleAssistant, Content: "here it is", Attachments: []conversation.Attachment{file}},
+		{Role: conversation.RoleAssistant, Content: "anything else?"},
+	}
+	messages := a.convertToLLMMessages(history)
+
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want user and merged model messages: %+v", len(messages), messages)
+	}
+	if messages[0].Role != "user" || len(messages[0].Attachments) != 1 {
+		t.Errorf("messages[0] = %+v, want the user message with its attachment", messages[0])
+	}
+	if messages[1].Role != "model" || messages[1].Content != "here it is\n\nanything else?" || len(messages[1].Attachments) != 1 {
+		t.Errorf("messages[1] = %+v, want the model messages merged with the attachment", messages[1])
+	}
+}
+
+func TestProcessMessage_Usage(t *testing.T) {
+	call := &llm.Response{
+		ToolCall: &llm.ToolCall{ID: "call_1", Name: "resources_add", Arguments: map[string]any{"name": "db"}},
+		Usage:    &llm.Usage{InputTokens: 100, OutputTokens: 10},
+	}
+	answer := &llm.Response{Text: "Added.", Usage: &llm.Usage{InputTokens: 150, OutputTokens: 5, CacheReadTokens: 90}}
+
+	tests := []struct {
+		name      string
+		responses []*llm.Response
+		want      *llm.Usage
+	}{
+		{name: "summed over calls", responses: []*llm.Response{call, answer}, want: &llm.Usage{InputTokens: 250, OutputTokens: 15, CacheReadTokens: 90}},
+		{name: "not reported", responses: []*llm.Response{{Text: "Hi."}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, &scriptedLLM{responses: tt.responses}, addTool(t))
+			conv := conversation.New("", "")
+
+			result, err := a.ProcessMessage(context.Background(), conv, "add db", nil)
+			if err != nil {
+				t.Fatalf("ProcessMessage() error: %v", err)
+			}
+			if !reflect.DeepEqual(result.Usage, tt.want) {
+				t.Errorf("Usage = %+v, want %+v", result.Usage, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"agent-stop-and-go/internal/config"
+	"agent-stop-and-go/internal/conversation"
+	"agent-stop-and-go/internal/llm"
+	"agent-stop-and-go/internal/mcp"
+)
+
+// summaryPrompt is the system prompt of the requests summarizing the oldest turns.
+const summaryPrompt = `You summarize the beginning of a conversation between a user and an AI agent that uses tools, so that the agent can continue it without the full history.
+Keep the user's goals and requests, the decisions made, the facts and identifiers (names, IDs, paths, values) returned by tools, and what remains to be done.
+Be concise and do not add anything that was not said.`
+
+// contextMessages returns the LLM messages of the conversation within the
+// llm.context budget. The history starts at the latest summary; when it
+// exceeds max_tokens, the oldest turns are dropped or, with the summarize
+// strategy, replaced by a summary stored in the conversation.
+func (a *Agent) contextMessages(ctx context.Context, conv *conversation.Conversation) []llm.Message {
+	cfg := a.config.LLM.Context
+	history := historyWindow(conv.Messages)
+	parts := a.llmParts(history)
+	messages := joinLLMParts(parts)
+	if cfg.MaxTokens == 0 || llm.EstimateTokens("", messages, nil) <= cfg.MaxTokens {
+		return messages
+	}
+
+	// Leave room for the next turns so that summaries are not requested on every turn
+	budget := cfg.MaxTokens
+	if cfg.Strategy == config.ContextSummarize {
+		budget /= 2
+	}
+	cut := contextCut(history, parts, budget)
+	if cut == 0 {
+		return messages // the current turn alone exceeds the budget
+	}
+
+	if cfg.Strategy == config.ContextSummarize {
+		summary, err := a.summarize(ctx, joinLLMParts(parts[:cut]))
+		if err == nil {
+			conv.AddSummary(summary, history[cut].ID)
+			return a.convertToLLMMessages(historyWindow(conv.Messages))
+		}
+		log.Printf("WARN: failed to summarize conversation %s, dropping its oldest turns: %v", conv.ID, err)
+	}
+	return joinLLMParts(parts[cut:])
+}
+
+// historyWindow returns the messages from the latest summary on.
+func historyWindow(messages []conversation.Message) []conversation.Message {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == conversation.RoleSummary {
+			return messages[i:]
+		}
+	}
+	return messages
+}
+
+// contextCut returns the index of the first message kept so that the history
+// fits in budget tokens, or 0 when no turn can be dropped. parts are the LLM
+// messages of history (see llmParts). Turns start at user messages; a turn is
+// never split between a tool call and its result (approval decisions are user
+// messages). When no cut fits, only the current turn is kept.
+func contextCut(history []conversation.Message, parts [][]llm.Message, budget int) int {
+	// blocked[i] > 0 when a tool result at or after i answers a call before i
+	calls := make(map[string]int)
+	blocked := make([]int, len(history)+1)
+	for i, msg := range history {
+		if msg.ToolCall == nil || msg.ToolCall.ID == "" {
+			continue
+		}
+		switch msg.Role {
+		case conversation.RoleAssistant:
+			calls[msg.ToolCall.ID] = i
+		case conversation.RoleTool:
+			if k, ok := calls[msg.ToolCall.ID]; ok {
+				blocked[k+1]++
+				blocked[i+1]--
+			}
+		}
+	}
+
+	// suffix[i] estimates the tokens of the messages from i on
+	suffix := make([]int, len(history)+1)
+	for i := len(history) - 1; i >= 0; i-- {
+		suffix[i] = suffix[i+1]
+		if len(parts[i]) > 0 {
+			suffix[i] += llm.EstimateTokens("", parts[i], nil)
+		}
+	}
+
+	cut, open := 0, 0
+	for i, msg := range history {
+		open += blocked[i]
+		if i == 0 || msg.Role != conversation.RoleUser || open > 0 {
+			continue
+		}
+		cut = i
+		if suffix[i] <= budget {
+			break
+		}
+	}
+	return cut
+}
+
+// summarize asks llm.context.summary_model (llm.model by default) for a
+// summary of the given messages.
+func (a *Agent) summarize(ctx context.Context, messages []llm.Message) (string, error) {
+	client, err := a.getLLMClient(a.config.LLM.Context.SummaryModel)
+	if err != nil {
+		return "", fmt.Errorf("failed to create summary client: %w", err)
+	}
+
+	var transcript strings.Builder
+	transcript.WriteString("Summarize this conversation:\n\n")
+	for _, msg := range messages {
+		switch {
+		case msg.ToolResult != nil:
+			fmt.Fprintf(&transcript, "Tool %s returned: %s\n\n", msg.ToolResult.Name, msg.ToolResult.Content)
+		case len(msg.ToolCalls) > 0:
+			for _, tc := range msg.ToolCalls {
+				args, _ := json.Marshal(tc.Arguments)
+				fmt.Fprintf(&transcript, "Agent called %s(%s)\n\n", tc.Name, args)
+			}
+		case msg.Role == "model":
+			fmt.Fprintf(&transcript, "Agent: %s\n\n", msg.Content)
+		default:
+			fmt.Fprintf(&transcript, "User: %s\n\n", msg.Content)
+		}
+	}
+
+	opts := llm.RequestOptions{MaxTokens: a.config.LLM.Context.MaxTokens / 4}
+	resp, err := client.GenerateWithTools(ctx, summaryPrompt, []llm.Message{{Role: "user", Content: transcript.String()}}, nil, opts)
+	if err != nil {
+		return "", err
+	}
+	a.logUsage(ctx, "summary", resp)
+	if strings.TrimSpace(resp.Text) == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return resp.Text, nil
+}
+
+// truncateToolResult caps the content of a tool result at maxTokens (0 means
+// no cap). Images of a truncated result are kept next to the truncated text.
+func truncateToolResult(r *llm.ToolResult, maxTokens int) *llm.ToolResult {
+	content := truncateText(r.Content, maxTokens)
+	if content == r.Content {
+		return r
+	}
+	truncated := *r
+	truncated.Content = content
+	truncated.Blocks = nil
+	for _, b := range r.Blocks {
+		if b.Type == "image" {
+			truncated.Blocks = append(truncated.Blocks, b)
+		}
+	}
+	if truncated.Blocks != nil {
+		truncated.Blocks = append([]mcp.ContentBlock{{Type: "text", Text: content}}, truncated.Blocks...)
+	}
+	return &truncated
+}
+
+// truncateText cuts text to about maxTokens tokens (4 characters per token)
+// and notes how much was left out. 0 means no cap.
+func truncateText(text string, maxTokens int) string {
+	n := maxTokens * 4
+	if maxTokens <= 0 || len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s\n\n[truncated: %d of %d bytes omitted]", text[:n], len(text)-n, len(text))
+}
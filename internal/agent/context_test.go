@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"agent-stop-and-go/internal/config"
+	"agent-stop-and-go/internal/conversation"
+	"agent-stop-and-go/internal/llm"
+	"agent-stop-and-go/internal/mcp"
+)
+
+// long is a message of 101 estimated tokens.
+var long = strings.Repeat("x", 400)
+
+func TestContextCut(t *testing.T) {
+	user := func(content string) conversation.Message {
+		return conversation.Message{Role: conversation.RoleUser, Content: content}
+	}
+	model := func(content string) conversation.Message {
+		return conversation.Message{Role: conversation.RoleAssistant, Content: content}
+	}
+	call := func(id string) conversation.Message {
+		return conversation.Message{Role: conversation.RoleAssistant, ToolCall: &conversation.ToolCall{ID: id, Name: "resources_list"}}
+	}
+	result := func(id, content string) conversation.Message {
+		return conversation.Message{Role: conversation.RoleTool, ToolCall: &conversation.ToolCall{ID: id, Name: "resources_list", Result: content}}
+	}
+
+	tests := []struct {
+		name    string
+		history []conversation.Message
+		budget  int
+		want    int
+	}{
+		{
+			name:    "oldest turn dropped",
+			history: []conversation.Message{user(long), model(long), user(long), model(long), user("now")},
+			budget:  250,
+			want:    2,
+		},
+		{
+			name:    "only the current turn fits",
+			history: []conversation.Message{user(long), model(long), user(long), model(long), user("now")},
+			budget:  150,
+			want:    4,
+		},
+		{
+			name:    "current turn over budget",
+			history: []conversation.Message{user(long), model(long), user(long)},
+			budget:  10,
+			want:    2,
+		},
+		{
+			name:    "single turn",
+			history: []conversation.Message{user(long), model(long), model(long)},
+			budget:  10,
+			want:    0,
+		},
+		{
+			name:    "approval between a call and its result",
+			history: []conversation.Message{user(long), call("c1"), user("[APPROVAL]: Approved"), result("c1", long), model(long), user("now")},
+			budget:  300,
+			want:    5,
+		},
+		{
+			name:    "cut after a complete tool exchange",
+			history: []conversation.Message{user(long), call("c1"), result("c1", long), user(long), model(long), user("now")},
+			budget:  250,
+			want:    3,
+		},
+	}
+
+	a := newTestAgent(t, &scriptedLLM{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contextCut(tt.history, a.llmParts(tt.history), tt.budget); got != tt.want {
+				t.Errorf("contextCut() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextMessages(t *testing.T) {
+	summary := &llm.Response{Text: "The user added two resources."}
+
+	tests := []struct {
+		name        string
+		strategy    string
+		responses   []*llm.Response
+		wantFirst   string // content of the first message sent
+		wantCount   int
+		wantSummary bool
+	}{
+		{name: "truncate", strategy: config.ContextTruncate, wantFirst: long, wantCount: 3},
+		{name: "summarize", strategy: config.ContextSummarize, responses: []*llm.Response{summary}, wantFirst: "Summary of the earlier conversation:\n\nThe user added two resources.\n\nnow", wantCount: 1, wantSummary: true},
+		{name: "summary failure", strategy: config.ContextSummarize, wantFirst: "now", wantCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &scriptedLLM{responses: tt.responses}
+			a := newTestAgent(t, model)
+			a.config.LLM.Context = config.ContextConfig{MaxTokens: 300, Strategy: tt.strategy}
+
+			conv := conversation.New("", "")
+			conv.AddUserMessage(long, nil)
+			conv.AddModelMessage(long, "")
+			conv.AddUserMessage(long, nil)
+			conv.AddModelMessage(long, "")
+			conv.AddUserMessage("now", nil)
+
+			messages := a.contextMessages(context.Background(), conv)
+			if len(messages) != tt.wantCount || messages[0].Content != tt.wantFirst {
+				t.Fatalf("messages = %+v, want %d starting with %q", messages, tt.wantCount, tt.wantFirst)
+			}
+			if messages[len(messages)-1].Role != "user" || !strings.HasSuffix(messages[len(messages)-1].Content, "now") {
+				t.Errorf("last message = %+v, want the current turn", messages[len(messages)-1])
+			}
+
+			summaries := 0
+			for i, msg := range conv.Messages {
+				if msg.Role == conversation.RoleSummary {
+					summaries++
+					if next := conv.Messages[i+1]; next.Content != "now" {
+						t.Errorf("summary inserted before %q, want before the current turn", next.Content)
+					}
+				}
+			}
+			if got := summaries == 1; got != tt.wantSummary {
+				t.Errorf("conversation has %d summaries, want summary %v", summaries, tt.wantSummary)
+			}
+			if tt.strategy == config.ContextSummarize {
+				if len(model.requests) != 1 || !strings.Contains(model.requests[0][0].Content, "User: "+long) {
+					t.Errorf("summary requests = %+v, want one with the dropped turns", model.requests)
+				}
+			}
+		})
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxTokens int
+		want      string
+	}{
+		{name: "no cap", text: "abcdefghij", maxTokens: 0, want: "abcdefghij"},
+		{name: "within cap", text: "abcdefgh", maxTokens: 2, want: "abcdefgh"},
+		{name: "cut", text: "abcdefghij", maxTokens: 2, want: "abcdefgh\n\n[truncated: 2 of 10 bytes omitted]"},
+		{name: "rune boundary", text: "aéééé", maxTokens: 1, want: "aé\n\n[truncated: 6 of 9 bytes omitted]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateText(tt.text, tt.maxTokens); got != tt.want {
+				t.Errorf("truncateText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateToolResult(t *testing.T) {
+	image := mcp.ContentBlock{Type: "image", MimeType: "image/png", Data: "aGVsbG8="}
+
+	t.Run("within cap", func(t *testing.T) {
+		r := &llm.ToolResult{Name: "read", Content: "short"}
+		if got := truncateToolResult(r, 10); got != r {
+			t.Errorf("truncateToolResult() = %+v, want the result unchanged", got)
+		}
+	})
+
+	t.Run("text only", func(t *testing.T) {
+		r := &llm.ToolResult{Name: "read", Content: long, Blocks: []mcp.ContentBlock{{Type: "text", Text: long}}}
+		got := truncateToolResult(r, 10)
+		if !strings.HasPrefix(got.Content, strings.Repeat("x", 40)+"\n\n[truncated: 360 of 400 bytes omitted]") || got.Blocks != nil {
+			t.Errorf("truncateToolResult() = %+v, want truncated text without blocks", got)
+		}
+		if r.Content != long {
+			t.Error("the original result was modified")
+		}
+	})
+
+	t.Run("images kept", func(t *testing.T) {
+		r := &llm.ToolResult{Name: "screenshot", Content: long, Blocks: []mcp.ContentBlock{{Type: "text", Text: long}, image}}
+		got := truncateToolResult(r, 10)
+		if len(got.Blocks) != 2 || got.Blocks[0].Text != got.Content || got.Blocks[1] != image {
+			t.Errorf("blocks = %+v, want the truncated text and the image", got.Blocks)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"agent-stop-and-go/internal/mcp"
+)
+
+// eventBuffer is how many events a subscriber may lag behind before events are dropped.
+const eventBuffer = 64
+
+// Event is a live update about a conversation, such as the progress of a
+// running tool. Events are not stored: only current subscribers receive them.
+type Event struct {
+	Type           string    `json:"type"` // "progress", "log" or "sampling"
+	ConversationID string    `json:"conversation_id"`
+	Time           time.Time `json:"time"`
+	Data           any       `json:"data"` // mcp.Notification for progress, log and sampling events
+}
+
+// eventHub fans out the events of each conversation to its subscribers.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan Event]struct{} // conversation ID -> subscribers
+}
+
+// subscribe registers a subscriber to the events of a conversation.
+func (h *eventHub) subscribe(convID string) (<-chan Event, func()) {
+	ch := make(chan Event, eventBuffer)
+
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[string]map[chan Event]struct{})
+	}
+	if h.subs[convID] == nil {
+		h.subs[convID] = make(map[chan Event]struct{})
+	}
+	h.subs[convID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs[convID], ch)
+			if len(h.subs[convID]) == 0 {
+				delete(h.subs, convID)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+// publish sends an event to the subscribers of its conversation. Subscribers
+// that are not keeping up miss the event rather than blocking the caller.
+func (h *eventHub) publish(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[ev.ConversationID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// SubscribeEvents returns the live events of a conversation and a function
+// that ends the subscription. The channel is never closed.
+func (a *Agent) SubscribeEvents(convID string) (<-chan Event, func()) {
+	return a.events.subscribe(convID)
+}
+
+// withEvents returns a context whose MCP tool calls publish their progress
+// updates and log messages as events of the conversation.
+func (a *Agent) withEvents(ctx context.Context, convID string) context.Context {
+	return mcp.WithNotify(ctx, func(n mcp.Notification) {
+		a.events.publish(Event{
+			Type:           n.Type,
+			ConversationID: convID,
+			Time:           time.Now(),
+			Data:           n,
+		})
+	})
+}
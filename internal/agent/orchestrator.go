@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
 	"sync"
@@ -259,8 +260,11 @@ func (a *Agent) executeLLMNode(ctx context.Context, node *config.AgentNode, stat
 		return &NodeResult{Response: resume.ToolResult}, nil
 	}
 
-	// Resolve prompt template
-	prompt := resolveTemplate(node.Prompt, state)
+	// Resolve prompt template and preload configured resources
+	prompt, err := a.withResources(ctx, conv, resolveTemplate(node.Prompt, state), node.Resources)
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %w", node.Name, err)
+	}
 
 	// Get LLM client for this node's model
 	llmClient, err := a.getLLMClient(node.Model)
@@ -271,21 +275,49 @@ func (a *Agent) executeLLMNode(ctx context.Context, node *config.AgentNode, stat
 	// Build tools: MCP + node's A2A + exit_loop
 	tools := a.getNodeTools(node)
 
-	// Single-turn LLM call with the user message
+	// Single-turn LLM call with the user message (retried on invalid tool arguments)
 	messages := []llm.Message{
-		{Role: "user", Content: userMessage},
+		{Role: "user", Content: userMessage, Attachments: a.lastUserAttachments(conv)},
 	}
 
-	response, err := llmClient.GenerateWithTools(ctx, prompt, messages, tools)
-	if err != nil {
-		errorMsg := fmt.Sprintf("[%s] LLM error: %v", node.Name, err)
-		conv.AddMessage(conversation.RoleAssistant, errorMsg)
-		return &NodeResult{Response: errorMsg}, nil
+	var response *llm.Response
+	for attempt := 0; ; attempt++ {
+		response, err = llmClient.GenerateWithTools(ctx, prompt, messages, tools, a.requestOptions(node.Generation))
+		if err != nil {
+			errorMsg := fmt.Sprintf("[%s] LLM error: %v", node.Name, err)
+			conv.AddMessage(conversation.RoleAssistant, errorMsg)
+			return &NodeResult{Response: errorMsg}, nil
+		}
+		a.logUsage(ctx, node.Name, response)
+		if response.ToolCall == nil {
+			break
+		}
+
+		// Invalid arguments: return the validation error to the LLM and let it retry
+		verr := validateToolCall(tools, response.ToolCall)
+		if verr == nil {
+			break
+		}
+		content := recordInvalidToolCall(conv, response.ToolCall, verr)
+		if attempt == maxInvalidToolCallRetries {
+			errorMsg := fmt.Sprintf("[%s] Invalid tool call %s: %v", node.Name, response.ToolCall.Name, verr)
+			conv.AddMessage(conversation.RoleAssistant, errorMsg)
+			return &NodeResult{Response: errorMsg}, nil
+		}
+		messages = append(messages,
+			llm.Message{Role: "model", ToolCalls: []llm.ToolCall{*response.ToolCall}},
+			llm.Message{Role: "tool", ToolResult: &llm.ToolResult{
+				ToolCallID: response.ToolCall.ID,
+				Name:       response.ToolCall.Name,
+				Content:    content,
+				IsError:    true,
+			}},
+		)
 	}
 
 	// Handle text response
 	if response.ToolCall == nil {
-		conv.AddMessage(conversation.RoleAssistant, fmt.Sprintf("[%s] %s", node.Name, response.Text))
+		conv.AddModelMessage(fmt.Sprintf("[%s] %s", node.Name, response.Text), response.Model)
 		if node.OutputKey != "" {
 			state.Set(node.OutputKey, response.Text)
 		}
@@ -293,6 +325,7 @@ func (a *Agent) executeLLMNode(ctx context.Context, node *config.AgentNode, stat
 	}
 
 	// Handle tool call
+	toolCallID := response.ToolCall.ID
 	toolName := response.ToolCall.Name
 	toolArgs := response.ToolCall.Arguments
 
@@ -313,22 +346,22 @@ func (a *Agent) executeLLMNode(ctx context.Context, node *config.AgentNode, stat
 		}
 
 		if client.DestructiveHint() && !allowDestructive {
-			return a.pauseForApproval(conv, state, node, path, userMessage, toolName, toolArgs,
+			return a.pauseForApproval(conv, state, node, path, userMessage, toolCallID, toolName, toolArgs,
 				fmt.Sprintf("[%s] Delegate to A2A agent: %s", node.Name, agentName))
 		}
 
 		message, _ := toolArgs["message"].(string)
-		conv.AddToolCall(toolName, toolArgs)
+		conv.AddToolCall(toolCallID, toolName, toolArgs)
 		task, err := client.SendMessage(ctx, message)
 		if err != nil {
 			errorMsg := fmt.Sprintf("[%s] A2A error: %v", node.Name, err)
-			conv.AddToolResult(toolName, errorMsg, true)
+			conv.AddToolResult(toolCallID, toolName, errorMsg, true)
 			return &NodeResult{Response: errorMsg}, nil
 		}
 
 		// Sub-agent returned "input-required" — create proxy approval
 		if task.Status.State == "input-required" {
-			result, err := a.pauseForApproval(conv, state, node, path, userMessage, toolName, toolArgs,
+			result, err := a.pauseForApproval(conv, state, node, path, userMessage, toolCallID, toolName, toolArgs,
 				fmt.Sprintf("[%s] Proxy approval for A2A agent: %s", node.Name, agentName))
 			if err != nil {
 				return nil, err
@@ -352,7 +385,7 @@ func (a *Agent) executeLLMNode(ctx context.Context, node *config.AgentNode, stat
 		}
 
 		resultText := extractTaskText(task)
-		conv.AddToolResult(toolName, resultText, task.Status.State == "failed")
+		conv.AddToolResult(toolCallID, toolName, resultText, task.Status.State == "failed")
 		if node.OutputKey != "" {
 			state.Set(node.OutputKey, resultText)
 		}
@@ -368,13 +401,13 @@ func (a *Agent) executeLLMNode(ctx context.Context, node *config.AgentNode, stat
 	}
 
 	if tool.DestructiveHint && !allowDestructive {
-		description := a.formatApprovalDescription(tool.Name, toolArgs)
-		conv.AddToolCall(tool.Name, toolArgs)
-		return a.pauseForApproval(conv, state, node, path, userMessage, tool.Name, toolArgs, description)
+		description := a.formatApprovalDescription(tool, toolArgs)
+		conv.AddToolCall(toolCallID, tool.Name, toolArgs)
+		return a.pauseForApproval(conv, state, node, path, userMessage, toolCallID, tool.Name, toolArgs, description)
 	}
 
 	// Execute non-destructive MCP tool (CompositeClient handles serialization)
-	conv.AddToolCall(toolName, toolArgs)
+	conv.AddToolCall(toolCallID, toolName, toolArgs)
 	result, err := a.mcpClient.CallTool(ctx, toolName, toolArgs)
 	if err != nil {
 		var authErr *mcp.AuthRequiredError
@@ -384,15 +417,12 @@ func (a *Agent) executeLLMNode(ctx context.Context, node *config.AgentNode, stat
 			return &NodeResult{Response: response, AuthRequired: true}, nil
 		}
 		errorMsg := fmt.Sprintf("[%s] Tool execution failed: %v", node.Name, err)
-		conv.AddToolResult(toolName, errorMsg, true)
+		conv.AddToolResult(toolCallID, toolName, errorMsg, true)
 		return &NodeResult{Response: errorMsg}, nil
 	}
 
-	var resultText string
-	if len(result.Content) > 0 {
-		resultText = result.Content[0].Text
-	}
-	conv.AddToolResult(toolName, resultText, result.IsError)
+	resultText := result.AsText()
+	conv.AddToolResultContent(toolCallID, toolName, result)
 	conv.AddMessage(conversation.RoleAssistant, fmt.Sprintf("[%s] %s", node.Name, resultText))
 
 	if node.OutputKey != "" {
@@ -428,22 +458,22 @@ func (a *Agent) executeA2ANode(ctx context.Context, node *config.AgentNode, stat
 	toolArgs := map[string]any{"message": message}
 
 	if node.DestructiveHint && !allowDestructive {
-		conv.AddToolCall(toolName, toolArgs)
+		conv.AddToolCall("", toolName, toolArgs)
 		description := fmt.Sprintf("[%s] Delegate to A2A agent: %s\n\nMessage: %s", node.Name, node.Name, message)
-		return a.pauseForApproval(conv, state, node, path, userMessage, toolName, toolArgs, description)
+		return a.pauseForApproval(conv, state, node, path, userMessage, "", toolName, toolArgs, description)
 	}
 
-	conv.AddToolCall(toolName, toolArgs)
+	conv.AddToolCall("", toolName, toolArgs)
 	task, err := client.SendMessage(ctx, message)
 	if err != nil {
 		errorMsg := fmt.Sprintf("[%s] A2A error: %v", node.Name, err)
-		conv.AddToolResult(toolName, errorMsg, true)
+		conv.AddToolResult("", toolName, errorMsg, true)
 		return &NodeResult{Response: errorMsg}, nil
 	}
 
 	// Sub-agent returned "input-required" — create proxy approval
 	if task.Status.State == "input-required" {
-		result, err := a.pauseForApproval(conv, state, node, path, userMessage, toolName, toolArgs,
+		result, err := a.pauseForApproval(conv, state, node, path, userMessage, "", toolName, toolArgs,
 			fmt.Sprintf("[%s] Proxy approval for A2A agent: %s", node.Name, node.Name))
 		if err != nil {
 			return nil, err
@@ -467,7 +497,7 @@ func (a *Agent) executeA2ANode(ctx context.Context, node *config.AgentNode, stat
 	}
 
 	resultText := extractTaskText(task)
-	conv.AddToolResult(toolName, resultText, task.Status.State == "failed")
+	conv.AddToolResult("", toolName, resultText, task.Status.State == "failed")
 	conv.AddMessage(conversation.RoleAssistant, fmt.Sprintf("[%s] %s", node.Name, resultText))
 
 	if node.OutputKey != "" {
@@ -477,8 +507,9 @@ func (a *Agent) executeA2ANode(ctx context.Context, node *config.AgentNode, stat
 }
 
 // pauseForApproval saves pipeline state and returns a waiting_approval result.
-func (a *Agent) pauseForApproval(conv *conversation.Conversation, state *SessionState, node *config.AgentNode, path []int, userMessage string, toolName string, toolArgs map[string]any, description string) (*NodeResult, error) {
+func (a *Agent) pauseForApproval(conv *conversation.Conversation, state *SessionState, node *config.AgentNode, path []int, userMessage string, toolCallID string, toolName string, toolArgs map[string]any, description string) (*NodeResult, error) {
 	approval := conv.SetWaitingApproval(toolName, toolArgs, description)
+	approval.ToolCallID = toolCallID
 	conv.PipelineState = &conversation.PipelineState{
 		PausedNodePath:      path,
 		PausedNodeOutputKey: node.OutputKey,
@@ -541,15 +572,98 @@ func (a *Agent) getNodeTools(node *config.AgentNode) []mcp.Tool {
 	return tools
 }
 
-// getLLMClient returns the LLM client for the given model, creating it if needed.
-func (a *Agent) getLLMClient(model string) (llm.Client, error) {
-	if model == "" {
-		model = a.config.LLM.Model
+// getLLMClient returns the LLM client for the given models, creating it if needed.
+// A list of several models returns a fallback client trying them in order.
+func (a *Agent) getLLMClient(models config.ModelList) (llm.Client, error) {
+	if len(models) == 0 {
+		models = a.config.LLM.Model
 	}
 
 	a.llmMu.Lock()
 	defer a.llmMu.Unlock()
 
+	if len(models) == 1 {
+		return a.modelClient(models[0])
+	}
+
+	key := models.String()
+	if client, ok := a.llmClients[key]; ok {
+		return client, nil
+	}
+	client, err := llm.NewFallbackClient(models, a.modelClient)
+	if err != nil {
+		return nil, err
+	}
+	a.llmClients[key] = client
+	return client, nil
+}
+
+// requestOptions returns the generation parameters of a node: llm.generation
+// with the fields set in the node's generation replaced.
+func (a *Agent) requestOptions(node config.GenerationConfig) llm.RequestOptions {
+	g := a.config.LLM.Generation.Merge(node)
+	return llm.RequestOptions{
+		Temperature:   g.Temperature,
+		TopP:          g.TopP,
+		MaxTokens:     g.MaxTokens,
+		StopSequences: g.StopSequences,
+		JSON:          g.JSON,
+		CachePrompt:   a.config.LLM.PromptCaching,
+	}
+}
+
+// usageKey is the context key of the usage total of a request.
+type usageKey struct{}
+
+// usageTotal sums the token usage of the LLM calls made for a request.
+type usageTotal struct {
+	mu    sync.Mutex
+	usage *llm.Usage
+}
+
+// withUsage returns a context whose LLM calls add their token usage to total.
+func withUsage(ctx context.Context, total *usageTotal) context.Context {
+	return context.WithValue(ctx, usageKey{}, total)
+}
+
+func (t *usageTotal) add(u *llm.Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.usage == nil {
+		t.usage = &llm.Usage{}
+	}
+	t.usage.InputTokens += u.InputTokens
+	t.usage.OutputTokens += u.OutputTokens
+	t.usage.CacheReadTokens += u.CacheReadTokens
+	t.usage.CacheWriteTokens += u.CacheWriteTokens
+}
+
+// total returns the summed usage, or nil if no call reported any.
+func (t *usageTotal) total() *llm.Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+// logUsage adds the token usage of a response to the total of the request
+// in ctx. It also logs it when prompt caching is enabled, to show the cache
+// savings.
+func (a *Agent) logUsage(ctx context.Context, caller string, resp *llm.Response) {
+	if resp.Usage == nil {
+		return
+	}
+	if total, ok := ctx.Value(usageKey{}).(*usageTotal); ok {
+		total.add(resp.Usage)
+	}
+	if a.config.LLM.PromptCaching {
+		log.Printf("LLM usage (%s): %s", caller, resp.Usage)
+	}
+}
+
+// modelClient returns the client of a single model, creating it if needed.
+// Models of a provider with rate limits share the provider's limiter.
+// The caller must hold a.llmMu.
+func (a *Agent) modelClient(model string) (llm.Client, error) {
 	if client, ok := a.llmClients[model]; ok {
 		return client, nil
 	}
@@ -558,6 +672,15 @@ func (a *Agent) getLLMClient(model string) (llm.Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	provider, _, _ := strings.Cut(model, ":")
+	if limit, ok := a.config.LLM.RateLimits[provider]; ok {
+		limiter, ok := a.rateLimiters[provider]
+		if !ok {
+			limiter = llm.NewRateLimiter(limit.RequestsPerMinute, limit.TokensPerMinute)
+			a.rateLimiters[provider] = limiter
+		}
+		client = llm.NewRateLimitedClient(client, limiter)
+	}
 	a.llmClients[model] = client
 	return client, nil
 }
@@ -0,0 +1,336 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-stop-and-go/internal/conversation"
+	"agent-stop-and-go/internal/mcp"
+)
+
+// Synthetic tool names exposing MCP resources and prompts to the LLM.
+const (
+	listResourcesTool = "mcp_list_resources"
+	readResourceTool  = "mcp_read_resource"
+	listPromptsTool   = "mcp_list_prompts"
+	getPromptTool     = "mcp_get_prompt"
+)
+
+// detectTimeout bounds the resource and prompt listing of a re-detection.
+const detectTimeout = 30 * time.Second
+
+// resourceClient wraps an MCP client and adds synthetic tools for the
+// resources and prompts published by its servers. Tools are only added
+// when at least one server publishes resources (or prompts).
+type resourceClient struct {
+	mcp.Client
+	mu        sync.Mutex
+	synthetic []mcp.Tool
+}
+
+// newResourceClient detects published resources and prompts and wraps client
+// accordingly. Detection runs again when the tool set changes or a server
+// reconnects, so that servers coming up later get their synthetic tools.
+func newResourceClient(ctx context.Context, client mcp.Client) *resourceClient {
+	rc := &resourceClient{Client: client}
+	rc.detect(ctx)
+	client.OnToolsChanged(func() {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), detectTimeout)
+			defer cancel()
+			rc.detect(ctx)
+		}()
+	})
+	return rc
+}
+
+// RefreshTools re-lists the MCP tools and re-detects resources and prompts.
+func (c *resourceClient) RefreshTools(ctx context.Context) error {
+	if err := c.Client.RefreshTools(ctx); err != nil {
+		return err
+	}
+	c.detect(ctx)
+	return nil
+}
+
+// detect lists the published resources and prompts and sets the synthetic tools.
+func (c *resourceClient) detect(ctx context.Context) {
+	var synthetic []mcp.Tool
+
+	resources, err := c.Client.ListResources(ctx)
+	if err != nil {
+		log.Printf("WARN: failed to list MCP resources: %v", err)
+	}
+	if len(resources) > 0 {
+		synthetic = append(synthetic,
+			mcp.Tool{
+				Name:        listResourcesTool,
+				Description: "List the resources (documents, files, data) published by the connected MCP servers.",
+				InputSchema: mcp.InputSchema{Type: "object", Properties: map[string]mcp.Property{}},
+			},
+			mcp.Tool{
+				Name:        readResourceTool,
+				Description: "Read the contents of an MCP resource by URI. Use " + listResourcesTool + " to discover URIs.",
+				InputSchema: mcp.InputSchema{
+					Type: "object",
+					Properties: map[string]mcp.Property{
+						"uri": {Type: "string", Description: "The resource URI"},
+					},
+					Required: []string{"uri"},
+				},
+			},
+		)
+	}
+
+	prompts, err := c.Client.ListPrompts(ctx)
+	if err != nil {
+		log.Printf("WARN: failed to list MCP prompts: %v", err)
+	}
+	if len(prompts) > 0 {
+		synthetic = append(synthetic,
+			mcp.Tool{
+				Name:        listPromptsTool,
+				Description: "List the prompt templates published by the connected MCP servers, with their arguments.",
+				InputSchema: mcp.InputSchema{Type: "object", Properties: map[string]mcp.Property{}},
+			},
+			mcp.Tool{
+				Name:        getPromptTool,
+				Description: "Render an MCP prompt template by name. Use " + listPromptsTool + " to discover prompts.",
+				InputSchema: mcp.InputSchema{
+					Type: "object",
+					Properties: map[string]mcp.Property{
+						"name": {Type: "string", Description: "The prompt name"},
+						"arguments": {
+							Type:        "object",
+							Description: `Prompt arguments by name, e.g. {"topic": "go"}`,
+							Extra:       map[string]any{"additionalProperties": map[string]any{"type": "string"}},
+						},
+					},
+					Required: []string{"name"},
+				},
+			},
+		)
+	}
+
+	c.mu.Lock()
+	c.synthetic = synthetic
+	c.mu.Unlock()
+}
+
+// syntheticTools returns the current synthetic resource/prompt tools.
+func (c *resourceClient) syntheticTools() []mcp.Tool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.synthetic
+}
+
+// Tools returns the MCP tools followed by the synthetic resource/prompt tools.
+func (c *resourceClient) Tools() []mcp.Tool {
+	tools := c.Client.Tools()
+	synthetic := c.syntheticTools()
+	if len(synthetic) == 0 {
+		return tools
+	}
+	all := make([]mcp.Tool, 0, len(tools)+len(synthetic))
+	all = append(all, tools...)
+	return append(all, synthetic...)
+}
+
+// GetTool returns a tool by name, including synthetic tools.
+func (c *resourceClient) GetTool(name string) *mcp.Tool {
+	synthetic := c.syntheticTools()
+	for i := range synthetic {
+		if synthetic[i].Name == name {
+			return &synthetic[i]
+		}
+	}
+	return c.Client.GetTool(name)
+}
+
+// CallTool executes synthetic tools locally and forwards everything else.
+func (c *resourceClient) CallTool(ctx context.Context, name string, args map[string]any) (*mcp.CallToolResult, error) {
+	switch name {
+	case listResourcesTool:
+		return c.listResources(ctx)
+	case readResourceTool:
+		uri, _ := args["uri"].(string)
+		return c.readResource(ctx, uri)
+	case listPromptsTool:
+		return c.listPrompts(ctx)
+	case getPromptTool:
+		promptName, _ := args["name"].(string)
+		promptArgs, err := parsePromptArguments(args["arguments"])
+		if err != nil {
+			return errorResult(err.Error()), nil
+		}
+		return c.getPrompt(ctx, promptName, promptArgs)
+	}
+	return c.Client.CallTool(ctx, name, args)
+}
+
+func (c *resourceClient) listResources(ctx context.Context) (*mcp.CallToolResult, error) {
+	resources, err := c.ListResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return textResult("No resources available."), nil
+	}
+
+	var sb strings.Builder
+	for _, r := range resources {
+		fmt.Fprintf(&sb, "- %s", r.URI)
+		if r.Name != "" {
+			fmt.Fprintf(&sb, " (%s)", r.Name)
+		}
+		if r.MimeType != "" {
+			fmt.Fprintf(&sb, " [%s]", r.MimeType)
+		}
+		if r.Description != "" {
+			fmt.Fprintf(&sb, ": %s", r.Description)
+		}
+		sb.WriteString("\n")
+	}
+	return textResult(strings.TrimSuffix(sb.String(), "\n")), nil
+}
+
+func (c *resourceClient) readResource(ctx context.Context, uri string) (*mcp.CallToolResult, error) {
+	if uri == "" {
+		return errorResult("uri is required"), nil
+	}
+	result, err := c.ReadResource(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &mcp.CallToolResult{}
+	for i := range result.Contents {
+		r.Content = append(r.Content, mcp.ContentBlock{Type: "resource", Resource: &result.Contents[i]})
+	}
+	return r, nil
+}
+
+func (c *resourceClient) listPrompts(ctx context.Context) (*mcp.CallToolResult, error) {
+	prompts, err := c.ListPrompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(prompts) == 0 {
+		return textResult("No prompts available."), nil
+	}
+
+	var sb strings.Builder
+	for _, p := range prompts {
+		fmt.Fprintf(&sb, "- %s", p.Name)
+		if p.Description != "" {
+			fmt.Fprintf(&sb, ": %s", p.Description)
+		}
+		sb.WriteString("\n")
+		for _, arg := range p.Arguments {
+			required := ""
+			if arg.Required {
+				required = ", required"
+			}
+			fmt.Fprintf(&sb, "  - %s (argument%s): %s\n", arg.Name, required, arg.Description)
+		}
+	}
+	return textResult(strings.TrimSuffix(sb.String(), "\n")), nil
+}
+
+func (c *resourceClient) getPrompt(ctx context.Context, name string, args map[string]string) (*mcp.CallToolResult, error) {
+	if name == "" {
+		return errorResult("name is required"), nil
+	}
+	result, err := c.GetPrompt(ctx, name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &mcp.CallToolResult{}
+	if result.Description != "" {
+		r.Content = append(r.Content, mcp.ContentBlock{Type: "text", Text: result.Description})
+	}
+	for _, m := range result.Messages {
+		block := m.Content
+		if block.Type == "text" {
+			block.Text = fmt.Sprintf("[%s] %s", m.Role, block.Text)
+		}
+		r.Content = append(r.Content, block)
+	}
+	return r, nil
+}
+
+// parsePromptArguments accepts prompt arguments either as a JSON object string
+// or as an object, and converts all values to strings as required by MCP.
+func parsePromptArguments(v any) (map[string]string, error) {
+	var raw map[string]any
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(val) == "" {
+			return nil, nil
+		}
+		if err := json.Unmarshal([]byte(val), &raw); err != nil {
+			return nil, fmt.Errorf("arguments must be a JSON object: %w", err)
+		}
+	case map[string]any:
+		raw = val
+	default:
+		return nil, fmt.Errorf("arguments must be a JSON object")
+	}
+
+	args := make(map[string]string, len(raw))
+	for k, val := range raw {
+		if s, ok := val.(string); ok {
+			args[k] = s
+		} else {
+			args[k] = fmt.Sprint(val)
+		}
+	}
+	return args, nil
+}
+
+// withResources appends the contents of the given resource URIs to a system
+// prompt. Each resource is read once per conversation and cached in it, so
+// that the prompt stays the same across turns and nodes and can be cached by
+// the provider.
+func (a *Agent) withResources(ctx context.Context, conv *conversation.Conversation, prompt string, uris []string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	for _, uri := range uris {
+		text, ok := conv.Resource(uri)
+		if !ok {
+			result, err := a.mcpClient.ReadResource(ctx, uri)
+			if err != nil {
+				return "", fmt.Errorf("failed to read resource %s: %w", uri, err)
+			}
+			var content strings.Builder
+			for _, c := range result.Contents {
+				if c.Text != "" {
+					content.WriteString("\n" + c.Text)
+				} else {
+					block := mcp.ContentBlock{Type: "resource", Resource: &c}
+					content.WriteString("\n" + block.AsText())
+				}
+			}
+			text = content.String()
+			conv.SetResource(uri, text)
+		}
+		fmt.Fprintf(&sb, "\n\n## Resource: %s\n%s", uri, text)
+	}
+	return sb.String(), nil
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{Content: []mcp.ContentBlock{{Type: "text", Text: text}}}
+}
+
+func errorResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{Content: []mcp.ContentBlock{{Type: "text", Text: text}}, IsError: true}
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"agent-stop-and-go/internal/conversation"
+	"agent-stop-and-go/internal/mcp"
+)
+
+// resourceMCP is an MCP client publishing one resource and one prompt. It
+// counts resource reads.
+type resourceMCP struct {
+	mcp.NopClient
+	reads int
+}
+
+func (c *resourceMCP) ListResources(context.Context) ([]mcp.Resource, error) {
+	return []mcp.Resource{{URI: "file:///guide.md", Name: "guide"}}, nil
+}
+
+func (c *resourceMCP) ReadResource(_ context.Context, uri string) (*mcp.ReadResourceResult, error) {
+	c.reads++
+	return &mcp.ReadResourceResult{Contents: []mcp.ResourceContents{{URI: uri, Text: "Be brief."}}}, nil
+}
+
+func (c *resourceMCP) ListPrompts(context.Context) ([]mcp.Prompt, error) {
+	return []mcp.Prompt{{Name: "review"}}, nil
+}
+
+func TestGetPromptTool_Arguments(t *testing.T) {
+	rc := newResourceClient(context.Background(), &resourceMCP{})
+	tool := rc.GetTool(getPromptTool)
+	if tool == nil {
+		t.Fatal("get prompt tool not detected")
+	}
+
+	tests := []struct {
+		name    string
+		args    map[string]any
+		wantErr bool
+	}{
+		{name: "object", args: map[string]any{"name": "review", "arguments": map[string]any{"topic": "go"}}},
+		{name: "no arguments", args: map[string]any{"name": "review"}},
+		{name: "string", args: map[string]any{"name": "review", "arguments": `{"topic": "go"}`}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tool.InputSchema.Validate(tt.args); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithResources_ReadOncePerConversation(t *testing.T) {
+	client := &resourceMCP{}
+	a := newTestAgent(t, &scriptedLLM{})
+	a.mcpClient = client
+
+	conv := conversation.New("", "")
+	want := "You help.\n\n## Resource: file:///guide.md\n\nBe brief."
+	for range 3 {
+		prompt, err := a.withResources(context.Background(), conv, "You help.", []string{"file:///guide.md"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prompt != want {
+			t.Errorf("prompt = %q, want %q", prompt, want)
+		}
+	}
+	if client.reads != 1 {
+		t.Errorf("resource read %d times, want once per conversation", client.reads)
+	}
+
+	if _, err := a.withResources(context.Background(), conversation.New("", ""), "You help.", []string{"file:///guide.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if client.reads != 2 {
+		t.Errorf("resource read %d times, want again for a new conversation", client.reads)
+	}
+}
@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"agent-stop-and-go/internal/config"
+	"agent-stop-and-go/internal/llm"
+	"agent-stop-and-go/internal/mcp"
+)
+
+// samplingHandler returns the handler answering the sampling requests of an
+// MCP server with the agent's LLM, or nil if the server may not sample.
+// Completions use llm.generation, with the server's temperature and stop
+// sequences, are capped at its sampling.max_tokens and never use tools.
+func (a *Agent) samplingHandler(server string, cfg config.SamplingConfig) mcp.SamplingHandler {
+	if !cfg.Enabled {
+		return nil
+	}
+	return func(ctx context.Context, params mcp.CreateMessageParams) (*mcp.CreateMessageResult, error) {
+		if a.llmClient == nil {
+			return nil, fmt.Errorf("LLM client not initialized")
+		}
+
+		opts := a.requestOptions(config.GenerationConfig{})
+		opts.MaxTokens = cfg.MaxTokens
+		if params.MaxTokens > 0 && params.MaxTokens < opts.MaxTokens {
+			opts.MaxTokens = params.MaxTokens
+		}
+		if params.Temperature != nil {
+			opts.Temperature = params.Temperature
+		}
+		if len(params.StopSequences) > 0 {
+			opts.StopSequences = params.StopSequences
+		}
+
+		messages := make([]llm.Message, 0, len(params.Messages))
+		for _, m := range params.Messages {
+			role := "user"
+			if m.Role == "assistant" {
+				role = "model"
+			}
+			messages = append(messages, llm.Message{Role: role, Content: m.Content.AsText()})
+		}
+
+		resp, err := a.llmClient.GenerateWithTools(ctx, params.SystemPrompt, messages, nil, opts)
+		if err != nil {
+			return nil, fmt.Errorf("sampling for MCP server %s: %w", server, err)
+		}
+
+		model := resp.Model
+		if model == "" {
+			model = a.config.LLM.Model[0]
+		}
+		return &mcp.CreateMessageResult{
+			Role:       "assistant",
+			Content:    mcp.ContentBlock{Type: "text", Text: resp.Text},
+			Model:      model,
+			StopReason: "endTurn",
+		}, nil
+	}
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"agent-stop-and-go/internal/config"
+	"agent-stop-and-go/internal/llm"
+	"agent-stop-and-go/internal/mcp"
+)
+
+func TestSamplingHandler_Options(t *testing.T) {
+	zero, warm := 0.0, 0.7
+
+	tests := []struct {
+		name            string
+		params          mcp.CreateMessageParams
+		wantTemperature *float64
+		wantMaxTokens   int
+	}{
+		{name: "defaults", params: mcp.CreateMessageParams{}, wantMaxTokens: 500},
+		{name: "zero temperature", params: mcp.CreateMessageParams{Temperature: &zero}, wantTemperature: &zero, wantMaxTokens: 500},
+		{name: "temperature and lower cap", params: mcp.CreateMessageParams{Temperature: &warm, MaxTokens: 100}, wantTemperature: &warm, wantMaxTokens: 100},
+		{name: "higher cap ignored", params: mcp.CreateMessageParams{MaxTokens: 1000}, wantMaxTokens: 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &scriptedLLM{responses: []*llm.Response{{Text: "done"}}}
+			a := newTestAgent(t, model)
+			handler := a.samplingHandler("test", config.SamplingConfig{Enabled: true, MaxTokens: 500})
+
+			tt.params.Messages = []mcp.SamplingMessage{{Role: "user", Content: mcp.ContentBlock{Type: "text", Text: "hi"}}}
+			result, err := handler(context.Background(), tt.params)
+			if err != nil {
+				t.Fatalf("handler error: %v", err)
+			}
+			if result.Content.Text != "done" || result.Model != testModel {
+				t.Errorf("result = %+v", result)
+			}
+
+			opts := model.options[0]
+			if opts.MaxTokens != tt.wantMaxTokens {
+				t.Errorf("MaxTokens = %d, want %d", opts.MaxTokens, tt.wantMaxTokens)
+			}
+			switch {
+			case tt.wantTemperature == nil && opts.Temperature != nil:
+				t.Errorf("Temperature = %v, want unset", *opts.Temperature)
+			case tt.wantTemperature != nil && (opts.Temperature == nil || *opts.Temperature != *tt.wantTemperature):
+				t.Errorf("Temperature = %v, want %v", opts.Temperature, *tt.wantTemperature)
+			}
+		})
+	}
+}
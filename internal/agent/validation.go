@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"agent-stop-and-go/internal/conversation"
+	"agent-stop-and-go/internal/llm"
+	"agent-stop-and-go/internal/mcp"
+)
+
+// maxInvalidToolCallRetries is how many times an LLM node may retry a tool
+// call whose arguments do not match the tool's input schema.
+const maxInvalidToolCallRetries = 2
+
+// invalidArguments is the tool result returned to the LLM for a tool call
+// whose arguments do not match the tool's input schema.
+type invalidArguments struct {
+	Error  string            `json:"error"` // always "invalid_arguments"
+	Tool   string            `json:"tool"`
+	Issues []mcp.SchemaIssue `json:"issues"`
+	Hint   string            `json:"hint"`
+}
+
+// validateToolCall checks the arguments of a tool call against the input
+// schema of the matching tool. Unknown tools are not validated here.
+func validateToolCall(tools []mcp.Tool, call *llm.ToolCall) *mcp.ValidationError {
+	for _, tool := range tools {
+		if tool.Name != call.Name {
+			continue
+		}
+		args := call.Arguments
+		if args == nil {
+			args = map[string]any{}
+		}
+		schema := tool.InputSchema.ObjectSchema()
+		var verr *mcp.ValidationError
+		if errors.As(schema.Validate(args), &verr) {
+			return verr
+		}
+		return nil
+	}
+	return nil
+}
+
+// recordInvalidToolCall records a rejected tool call and its validation error
+// in the conversation and returns the error content sent back to the LLM.
+func recordInvalidToolCall(conv *conversation.Conversation, call *llm.ToolCall, verr *mcp.ValidationError) string {
+	log.Printf("WARN: rejected tool call %s: %v", call.Name, verr)
+
+	data, _ := json.Marshal(invalidArguments{
+		Error:  "invalid_arguments",
+		Tool:   call.Name,
+		Issues: verr.Issues,
+		Hint:   "The tool was not called. Correct the arguments to match the tool's input schema and call it again.",
+	})
+	content := string(data)
+
+	conv.AddToolCall(call.ID, call.Name, call.Arguments)
+	conv.AddToolResult(call.ID, call.Name, content, true)
+	return content
+}
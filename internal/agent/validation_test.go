@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"agent-stop-and-go/internal/config"
+	"agent-stop-and-go/internal/conversation"
+	"agent-stop-and-go/internal/llm"
+	"agent-stop-and-go/internal/mcp"
+)
+
+// addTool is a non-destructive tool with required, typed and union arguments.
+func addTool(t *testing.T) mcp.Tool {
+	t.Helper()
+	schema, err := mcp.ParseSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"count": {"type": "integer", "minimum": 1},
+			"port": {"type": ["string", "integer"]}
+		},
+		"required": ["name"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mcp.Tool{Name: "resources_add", InputSchema: schema}
+}
+
+func TestValidateToolCall(t *testing.T) {
+	tools := []mcp.Tool{addTool(t)}
+
+	tests := []struct {
+		name    string
+		call    llm.ToolCall
+		coerce  bool // coerce the arguments first, as the LLM clients do
+		wantErr []string
+	}{
+		{name: "valid", call: llm.ToolCall{Name: "resources_add", Arguments: map[string]any{"name": "db", "count": float64(2)}}},
+		{name: "missing required", call: llm.ToolCall{Name: "resources_add", Arguments: map[string]any{"count": float64(2)}}, wantErr: []string{`missing required property "name"`}},
+		{name: "no arguments", call: llm.ToolCall{Name: "resources_add"}, wantErr: []string{`missing required property "name"`}},
+		{name: "wrong type", call: llm.ToolCall{Name: "resources_add", Arguments: map[string]any{"name": "db", "count": "two"}}, wantErr: []string{"count: must be integer, got string"}},
+		{name: "coercible", call: llm.ToolCall{Name: "resources_add", Arguments: map[string]any{"name": float64(42), "count": "3"}}, coerce: true},
+		{name: "not coerced", call: llm.ToolCall{Name: "resources_add", Arguments: map[string]any{"name": "db", "count": "3"}}, wantErr: []string{"count: must be integer, got string"}},
+		{name: "union integer", call: llm.ToolCall{Name: "resources_add", Arguments: map[string]any{"name": "db", "port": float64(8080)}}},
+		{name: "union string", call: llm.ToolCall{Name: "resources_add", Arguments: map[string]any{"name": "db", "port": "http"}}},
+		{name: "union mismatch", call: llm.ToolCall{Name: "resources_add", Arguments: map[string]any{"name": "db", "port": true}}, wantErr: []string{"port: must be string or integer, got boolean"}},
+		{name: "several issues", call: llm.ToolCall{Name: "resources_add", Arguments: map[string]any{"count": float64(0)}}, wantErr: []string{`missing required property "name"`, "count: must be >= 1"}},
+		{name: "unknown tool", call: llm.ToolCall{Name: "other", Arguments: map[string]any{"x": 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call := tt.call
+			if tt.coerce {
+				llm.CoerceToolCallArgs(&call, tools)
+			}
+			verr := validateToolCall(tools, &call)
+			if len(tt.wantErr) == 0 {
+				if verr != nil {
+					t.Fatalf("unexpected error: %v", verr)
+				}
+				return
+			}
+			if verr == nil {
+				t.Fatalf("expected errors %q", tt.wantErr)
+			}
+			if len(verr.Issues) != len(tt.wantErr) {
+				t.Errorf("issues = %v, want %d", verr.Issues, len(tt.wantErr))
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(verr.Error(), want) {
+					t.Errorf("error %q does not contain %q", verr, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordInvalidToolCall(t *testing.T) {
+	conv := conversation.New("", "")
+	call := &llm.ToolCall{ID: "call_1", Name: "resources_add", Arguments: map[string]any{"count": "two"}}
+	verr := validateToolCall([]mcp.Tool{addTool(t)}, call)
+
+	content := recordInvalidToolCall(conv, call, verr)
+
+	var got invalidArguments
+	if err := json.Unmarshal([]byte(content), &got); err != nil {
+		t.Fatalf("content is not JSON: %v", err)
+	}
+	if got.Error != "invalid_arguments" || got.Tool != "resources_add" || len(got.Issues) != 2 || got.Hint == "" {
+		t.Errorf("content = %+v", got)
+	}
+
+	if len(conv.Messages) != 2 {
+		t.Fatalf("got %d messages, want tool call and result", len(conv.Messages))
+	}
+	if msg := conv.Messages[0]; msg.Role != conversation.RoleAssistant || msg.ToolCall.ID != "call_1" {
+		t.Errorf("messages[0] = %+v, want the rejected call", msg)
+	}
+	if msg := conv.Messages[1]; msg.Role != conversation.RoleTool || msg.ToolCall.ID != "call_1" || !msg.ToolCall.IsError || msg.ToolCall.Result != content {
+		t.Errorf("messages[1] = %+v, want the error result", msg)
+	}
+}
+
+func TestExecuteLLMNode_InvalidToolCallRetries(t *testing.T) {
+	invalid := &llm.Response{ToolCall: &llm.ToolCall{ID: "bad", Name: "resources_add", Arguments: map[string]any{"count": float64(1)}}}
+	valid := &llm.Response{ToolCall: &llm.ToolCall{ID: "good", Name: "resources_add", Arguments: map[string]any{"name": "db"}}}
+
+	tests := []struct {
+		name         string
+		responses    []*llm.Response
+		wantRequests int
+		wantRejected int
+		wantResponse string
+	}{
+		{
+			name:         "corrected on retry",
+			responses:    []*llm.Response{invalid, invalid, valid},
+			wantRequests: 3,
+			wantRejected: 2,
+			wantResponse: "resources_add done",
+		},
+		{
+			name:         "retries exhausted",
+			responses:    []*llm.Response{invalid, invalid, invalid, valid},
+			wantRequests: maxInvalidToolCallRetries + 1,
+			wantRejected: maxInvalidToolCallRetries + 1,
+			wantResponse: "[step] Invalid tool call resources_add: invalid arguments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &scriptedLLM{responses: tt.responses}
+			a := newTestAgent(t, model, addTool(t))
+			conv := conversation.New("", "")
+			node := &config.AgentNode{Name: "step", Type: "llm", Model: config.ModelList{testModel}}
+
+			result, err := a.executeLLMNode(context.Background(), node, NewSessionState(), "add db", conv, nil, nil, false)
+			if err != nil {
+				t.Fatalf("executeLLMNode() error: %v", err)
+			}
+			if !strings.HasPrefix(result.Response, tt.wantResponse) {
+				t.Errorf("response = %q, want prefix %q", result.Response, tt.wantResponse)
+			}
+			if len(model.requests) != tt.wantRequests {
+				t.Fatalf("got %d LLM requests, want %d", len(model.requests), tt.wantRequests)
+			}
+
+			// Each retry carries the rejected calls and their validation errors
+			last := model.requests[len(model.requests)-1]
+			if want := 1 + 2*(tt.wantRequests-1); len(last) != want {
+				t.Fatalf("last request has %d messages, want %d", len(last), want)
+			}
+			if len(last) > 1 {
+				result := last[2].ToolResult
+				if last[1].ToolCalls[0].ID != "bad" || result == nil || !result.IsError || !strings.Contains(result.Content, `"error":"invalid_arguments"`) {
+					t.Errorf("retry messages = %+v, want the rejected call and its error", last[1:3])
+				}
+			}
+
+			rejected := 0
+			for _, msg := range conv.Messages {
+				if msg.Role == conversation.RoleTool && msg.ToolCall.ID == "bad" && msg.ToolCall.IsError {
+					rejected++
+				}
+			}
+			if rejected != tt.wantRejected {
+				t.Errorf("recorded %d rejected calls, want %d", rejected, tt.wantRejected)
+			}
+		})
+	}
+}
@@ -11,17 +11,22 @@ import (
 	"agent-stop-and-go/internal/config"
 )
 
+// maxBodySize bounds request bodies, which carry message attachments.
+const maxBodySize = 32 << 20
+
 // Server holds the API server components.
 type Server struct {
 	app    *fiber.App
 	agent  *agent.Agent
 	config *config.Config
+	done   chan struct{} // closed on shutdown to end event streams
 }
 
 // New creates a new API server.
 func New(cfg *config.Config, ag *agent.Agent) *Server {
 	app := fiber.New(fiber.Config{
-		AppName: "Agent Stop and Go",
+		AppName:   "Agent Stop and Go",
+		BodyLimit: maxBodySize,
 	})
 
 	// Session ID middleware: extract from X-Session-ID header or generate a new one
@@ -42,6 +47,7 @@ func New(cfg *config.Config, ag *agent.Agent) *Server {
 		app:    app,
 		agent:  ag,
 		config: cfg,
+		done:   make(chan struct{}),
 	}
 
 	server.setupRoutes()
@@ -57,5 +63,6 @@ func (s *Server) Start() error {
 
 // Shutdown gracefully stops the server.
 func (s *Server) Shutdown() error {
+	close(s.done)
 	return s.app.Shutdown()
 }
@@ -53,11 +53,16 @@ func getAPISpec() APISpec {
 				Method:      "GET",
 				Path:        "/health",
 				Summary:     "Health Check",
-				Description: "Returns the health status of the API server.",
+				Description: "Returns the health status of the API server and of each MCP server. The status is \"degraded\" while an MCP server is unavailable.",
 				Responses: map[string]Response{
 					"200": {
-						Description: "Server is healthy",
-						Example:     map[string]string{"status": "ok"},
+						Description: "Server is running",
+						Example: map[string]any{
+							"status": "ok",
+							"mcp_servers": []any{
+								map[string]any{"name": "resources", "healthy": true, "last_check": "2025-01-01T00:00:00Z", "restarts": 0},
+							},
+						},
 					},
 				},
 			},
@@ -69,7 +74,8 @@ func getAPISpec() APISpec {
 				Request: &RequestSpec{
 					ContentType: "application/json",
 					Schema: map[string]Field{
-						"message": {Type: "string", Description: "Optional initial message to send", Required: false},
+						"message":     {Type: "string", Description: "Optional initial message to send", Required: false},
+						"attachments": {Type: "array", Description: "Optional files sent with the message: {file_id} of an uploaded file, or {name, mime_type, data} with base64 data", Required: false},
 					},
 					Example: map[string]string{"message": "Hello, I need help with deployment"},
 				},
@@ -129,17 +135,48 @@ func getAPISpec() APISpec {
 					},
 				},
 			},
+			{
+				Method:      "GET",
+				Path:        "/conversations/:id/events",
+				Summary:     "Stream Conversation Events",
+				Description: "Streams live events of a conversation as Server-Sent Events while its messages and approvals are processed: progress updates of running MCP tools (event: progress), log messages of their MCP servers (event: log) and completions requested by MCP servers (event: sampling). Events are not stored; only open streams receive them.",
+				Responses: map[string]Response{
+					"200": {
+						Description: "text/event-stream of events",
+						Example: map[string]any{
+							"type":            "progress",
+							"conversation_id": "uuid",
+							"time":            "2026-01-01T12:00:00Z",
+							"data": map[string]any{
+								"type":     "progress",
+								"server":   "filesystem",
+								"tool":     "grep",
+								"progress": 1200,
+								"message":  "1200 files scanned, 3 matches",
+							},
+						},
+					},
+					"404": {
+						Description: "Conversation not found",
+						Example:     map[string]string{"error": "conversation not found"},
+					},
+				},
+			},
 			{
 				Method:      "POST",
 				Path:        "/conversations/:id/messages",
 				Summary:     "Send Message",
-				Description: "Sends a message to an existing conversation. If the agent needs approval, it will return a pending_approval object with a UUID. While waiting for approval, no new messages can be processed.",
+				Description: "Sends a message to an existing conversation. If the agent needs approval, it will return a pending_approval object with a UUID. While waiting for approval, no new messages can be processed. Images and PDFs are sent to vision-capable models; text files are inlined.",
 				Request: &RequestSpec{
 					ContentType: "application/json",
 					Schema: map[string]Field{
-						"message": {Type: "string", Description: "The message to send to the agent", Required: true},
+						"message":     {Type: "string", Description: "The message to send to the agent (required without attachments)", Required: true},
+						"attachments": {Type: "array", Description: "Files sent with the message: {file_id} of an uploaded file, or {name, mime_type, data} with base64 data", Required: false},
+					},
+					Example: map[string]any{
+						"message":     "Find the code that raises this error",
+						"attachments": []map[string]string{{"name": "error.png", "mime_type": "image/png", "data": "iVBORw0KGgo..."}},
 					},
-					Example: map[string]string{"message": "Please scale the web deployment to 5 replicas"},
 				},
 				Responses: map[string]Response{
 					"200": {
@@ -179,6 +216,33 @@ func getAPISpec() APISpec {
 					},
 				},
 			},
+			{
+				Method:      "POST",
+				Path:        "/files",
+				Summary:     "Upload File",
+				Description: "Stores a file to attach to messages by file_id. The MIME type is taken from the part's Content-Type, or detected from the file name and content.",
+				Request: &RequestSpec{
+					ContentType: "multipart/form-data",
+					Schema: map[string]Field{
+						"file": {Type: "file", Description: "The file to upload", Required: true},
+					},
+				},
+				Responses: map[string]Response{
+					"201": {
+						Description: "File stored",
+						Example: map[string]any{
+							"id":        "file-uuid",
+							"name":      "error.png",
+							"mime_type": "image/png",
+							"size":      48213,
+						},
+					},
+					"400": {
+						Description: "No file in the request",
+						Example:     map[string]string{"error": "multipart field \"file\" is required"},
+					},
+				},
+			},
 			{
 				Method:      "GET",
 				Path:        "/.well-known/agent.json",
@@ -202,7 +266,7 @@ func getAPISpec() APISpec {
 				Method:      "POST",
 				Path:        "/a2a",
 				Summary:     "A2A JSON-RPC Endpoint",
-				Description: "JSON-RPC 2.0 endpoint for A2A protocol. Supports methods: message/send (send a message and get a task result, or continue an existing task with taskId), and tasks/get (retrieve a task by ID).",
+				Description: "JSON-RPC 2.0 endpoint for A2A protocol. Supports methods: message/send (send a message and get a task result, or continue an existing task with taskId), and tasks/get (retrieve a task by ID). Messages may carry file parts (type \"file\" with file.name, file.mimeType and base64 file.bytes).",
 				Request: &RequestSpec{
 					ContentType: "application/json",
 					Schema: map[string]Field{
@@ -275,6 +339,46 @@ func getAPISpec() APISpec {
 					},
 				},
 			},
+			{
+				Method:      "POST",
+				Path:        "/mcp",
+				Summary:     "MCP Server (Streamable HTTP)",
+				Description: "Serves the agent as an MCP server. Tools: one named after the agent (arguments: message, optional conversation_id) that runs the agent and returns its response, and resolve_approval (arguments: approval_uuid, approved) to answer approvals. Actions that need approval return an error result with the approval_uuid. Responses are JSON only; GET (server-initiated streams) returns 405.",
+				Request: &RequestSpec{
+					ContentType: "application/json",
+					Schema: map[string]Field{
+						"jsonrpc": {Type: "string", Description: "Must be \"2.0\"", Required: true},
+						"id":      {Type: "integer", Description: "Request ID", Required: true},
+						"method":  {Type: "string", Description: "MCP method, e.g. \"initialize\", \"tools/list\" or \"tools/call\"", Required: true},
+						"params":  {Type: "object", Description: "Method-specific parameters"},
+					},
+					Example: map[string]any{
+						"jsonrpc": "2.0",
+						"id":      2,
+						"method":  "tools/call",
+						"params": map[string]any{
+							"name":      "resource-manager",
+							"arguments": map[string]string{"message": "list resources"},
+						},
+					},
+				},
+				Responses: map[string]Response{
+					"200": {
+						Description: "JSON-RPC response with the tool result",
+						Example: map[string]any{
+							"jsonrpc": "2.0",
+							"id":      2,
+							"result": map[string]any{
+								"content": []map[string]string{
+									{"type": "text", "text": "Here are the resources..."},
+									{"type": "text", "text": "conversation_id: uuid"},
+								},
+								"structuredContent": map[string]string{"conversation_id": "uuid", "response": "Here are the resources..."},
+							},
+						},
+					},
+				},
+			},
 		},
 	}
 }
@@ -1,14 +1,20 @@
 package api
 
 import (
+	"bufio"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
 	"agent-stop-and-go/internal/a2a"
+	"agent-stop-and-go/internal/agent"
 	"agent-stop-and-go/internal/auth"
 	"agent-stop-and-go/internal/conversation"
 )
@@ -37,40 +43,154 @@ func extractContext(c *fiber.Ctx) context.Context {
 	return ctx
 }
 
-// healthHandler returns the API health status.
+// healthHandler returns the API health status and the state of each MCP server.
+// The status is "degraded" while any MCP server is unavailable.
 func (s *Server) healthHandler(c *fiber.Ctx) error {
+	servers := s.agent.GetMCPHealth()
+	status := "ok"
+	for _, h := range servers {
+		if !h.Healthy {
+			status = "degraded"
+			break
+		}
+	}
 	return c.JSON(fiber.Map{
-		"status": "ok",
+		"status":      status,
+		"mcp_servers": servers,
 	})
 }
 
-// toolsHandler returns the available MCP tools.
+// toolsHandler returns the available MCP tools, the recent tool list changes
+// and the state of each MCP server.
 func (s *Server) toolsHandler(c *fiber.Ctx) error {
 	tools := s.agent.GetTools()
 	return c.JSON(fiber.Map{
-		"tools": tools,
+		"tools":   tools,
+		"changes": s.agent.GetToolChanges(),
+		"servers": s.agent.GetMCPHealth(),
 	})
 }
 
 // CreateConversationRequest is the request body for creating a conversation.
 type CreateConversationRequest struct {
-	Message string `json:"message,omitempty"`
+	Message     string              `json:"message,omitempty"`
+	Attachments []AttachmentRequest `json:"attachments,omitempty"`
+}
+
+// AttachmentRequest is a file sent with a message: a file uploaded with
+// POST /files, or its base64-encoded content.
+type AttachmentRequest struct {
+	FileID   string `json:"file_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"` // detected from the name or content when empty
+	Data     string `json:"data,omitempty"`      // base64
+}
+
+// resolveAttachments returns the stored attachments of a message, saving the
+// ones sent inline. On error, the files it saved are deleted.
+func (s *Server) resolveAttachments(reqs []AttachmentRequest) ([]conversation.Attachment, error) {
+	attachments := make([]conversation.Attachment, 0, len(reqs))
+	for i, r := range reqs {
+		var file conversation.Attachment
+		var err error
+		switch {
+		case r.FileID != "" && r.Data != "":
+			err = fmt.Errorf("file_id and data are exclusive")
+		case r.FileID != "":
+			file, err = s.agent.GetFile(r.FileID)
+		case r.Data != "":
+			var data []byte
+			data, err = base64.StdEncoding.DecodeString(r.Data)
+			if err != nil {
+				err = fmt.Errorf("invalid base64 data: %w", err)
+				break
+			}
+			file, err = s.agent.SaveFile(r.Name, r.MimeType, data)
+		default:
+			err = fmt.Errorf("file_id or data is required")
+		}
+		if err != nil {
+			s.discardAttachments(reqs, attachments)
+			return nil, fmt.Errorf("attachments[%d]: %w", i, err)
+		}
+		attachments = append(attachments, file)
+	}
+	return attachments, nil
+}
+
+// discardAttachments deletes the files saved by resolveAttachments for the
+// inline attachments of a message that is not sent. Uploaded files are kept.
+func (s *Server) discardAttachments(reqs []AttachmentRequest, attachments []conversation.Attachment) {
+	for i, file := range attachments {
+		if reqs[i].Data == "" {
+			continue
+		}
+		if err := s.agent.DeleteFile(file.ID); err != nil {
+			log.Printf("WARN: failed to delete attachment %s: %v", file.ID, err)
+		}
+	}
+}
+
+// uploadFileHandler stores a file sent as multipart form field "file", to be
+// referenced by file_id in message attachments.
+func (s *Server) uploadFileHandler(c *fiber.Ctx) error {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "multipart field \"file\" is required",
+		})
+	}
+	f, err := header.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "application/octet-stream" {
+		mimeType = "" // detected from the name or content
+	}
+	file, err := s.agent.SaveFile(header.Filename, mimeType, data)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(file)
 }
 
 // createConversationHandler starts a new conversation.
 func (s *Server) createConversationHandler(c *fiber.Ctx) error {
+	var req CreateConversationRequest
+	_ = parseJSON(c, &req)
+	attachments, err := s.resolveAttachments(req.Attachments)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	ctx := extractContext(c)
 	conv, err := s.agent.StartConversation(ctx)
 	if err != nil {
+		s.discardAttachments(req.Attachments, attachments)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	var req CreateConversationRequest
-	if err := parseJSON(c, &req); err == nil && req.Message != "" {
-		result, err := s.agent.ProcessMessage(ctx, conv, req.Message)
+	if req.Message != "" || len(attachments) > 0 {
+		result, err := s.agent.ProcessMessage(ctx, conv, req.Message, attachments)
 		if err != nil {
+			s.discardAttachments(req.Attachments, attachments)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
 			})
@@ -142,9 +262,62 @@ func (s *Server) getConversationHandler(c *fiber.Ctx) error {
 	})
 }
 
+// eventKeepAlive is the interval of the comments that keep idle event streams open.
+const eventKeepAlive = 15 * time.Second
+
+// conversationEventsHandler streams the live events of a conversation
+// (tool progress and MCP server log messages) as Server-Sent Events.
+func (s *Server) conversationEventsHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	if _, err := s.agent.GetConversation(id); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	events, unsubscribe := s.agent.SubscribeEvents(id)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		// Send the headers right away
+		fmt.Fprint(w, ": connected\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(eventKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case ev := <-events:
+				data, err := json.Marshal(ev)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+			case <-ticker.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case <-s.done:
+				return
+			}
+			// A failed flush means the client went away
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
+
 // SendMessageRequest is the request body for sending a message.
 type SendMessageRequest struct {
-	Message string `json:"message"`
+	Message     string              `json:"message"`
+	Attachments []AttachmentRequest `json:"attachments,omitempty"`
 }
 
 // sendMessageHandler processes a user message in a conversation.
@@ -158,12 +331,11 @@ func (s *Server) sendMessageHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Message == "" {
+	if req.Message == "" && len(req.Attachments) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "message is required",
 		})
 	}
-
 	conv, err := s.agent.GetConversation(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -171,8 +343,20 @@ func (s *Server) sendMessageHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	attachments, err := s.resolveAttachments(req.Attachments)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// A conversation waiting for approval does not take the message
+	waiting := conv.Status == conversation.StatusWaitingApproval
 	ctx := extractContext(c)
-	result, err := s.agent.ProcessMessage(ctx, conv, req.Message)
+	result, err := s.agent.ProcessMessage(ctx, conv, req.Message, attachments)
+	if err != nil || waiting {
+		s.discardAttachments(req.Attachments, attachments)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -306,22 +490,31 @@ func (s *Server) a2aMessageSend(c *fiber.Ctx, req a2a.Request) error {
 		})
 	}
 
-	// Extract text from message parts
+	// Extract text and files from message parts
 	var text string
+	var files []AttachmentRequest
 	for _, p := range params.Message.Parts {
-		if p.Type == "text" && p.Text != "" {
+		switch {
+		case p.Type == "text" && p.Text != "" && text == "":
 			text = p.Text
-			break
+		case p.Type == "file" && p.File != nil:
+			if p.File.Bytes == "" {
+				return c.JSON(a2a.Response{
+					JSONRPC: "2.0",
+					ID:      req.ID,
+					Error:   &a2a.RPCError{Code: -32602, Message: "File parts must carry bytes (uri is not supported)"},
+				})
+			}
+			files = append(files, AttachmentRequest{Name: p.File.Name, MimeType: p.File.MimeType, Data: p.File.Bytes})
 		}
 	}
-	if text == "" {
+	if text == "" && len(files) == 0 {
 		return c.JSON(a2a.Response{
 			JSONRPC: "2.0",
 			ID:      req.ID,
-			Error:   &a2a.RPCError{Code: -32602, Message: "No text part in message"},
+			Error:   &a2a.RPCError{Code: -32602, Message: "No text or file part in message"},
 		})
 	}
-
 	ctx := extractContext(c)
 
 	// If taskId is provided, this is a continuation of an existing task
@@ -349,7 +542,7 @@ func (s *Server) a2aMessageSend(c *fiber.Ctx, req a2a.Request) error {
 				})
 			}
 
-			task := conversationToTask(conv, result.Response, result.AuthRequired)
+			task := resultToTask(conv, result)
 			taskBytes, err := json.Marshal(task)
 			if err != nil {
 				return c.JSON(a2a.Response{
@@ -367,8 +560,18 @@ func (s *Server) a2aMessageSend(c *fiber.Ctx, req a2a.Request) error {
 		}
 
 		// Active conversation: process as a new message
-		result, err := s.agent.ProcessMessage(ctx, conv, text)
+		attachments, err := s.resolveAttachments(files)
 		if err != nil {
+			return c.JSON(a2a.Response{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Error:   &a2a.RPCError{Code: -32602, Message: err.Error()},
+			})
+		}
+
+		result, err := s.agent.ProcessMessage(ctx, conv, text, attachments)
+		if err != nil {
+			s.discardAttachments(files, attachments)
 			return c.JSON(a2a.Response{
 				JSONRPC: "2.0",
 				ID:      req.ID,
@@ -384,7 +587,7 @@ func (s *Server) a2aMessageSend(c *fiber.Ctx, req a2a.Request) error {
 				Error:   &a2a.RPCError{Code: -32603, Message: err.Error()},
 			})
 		}
-		task := conversationToTask(updatedConv, result.Response, result.AuthRequired)
+		task := resultToTask(updatedConv, result)
 		taskBytes, err := json.Marshal(task)
 		if err != nil {
 			return c.JSON(a2a.Response{
@@ -402,8 +605,18 @@ func (s *Server) a2aMessageSend(c *fiber.Ctx, req a2a.Request) error {
 	}
 
 	// No taskId: create a new conversation
+	attachments, err := s.resolveAttachments(files)
+	if err != nil {
+		return c.JSON(a2a.Response{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error:   &a2a.RPCError{Code: -32602, Message: err.Error()},
+		})
+	}
+
 	conv, err := s.agent.StartConversation(ctx)
 	if err != nil {
+		s.discardAttachments(files, attachments)
 		return c.JSON(a2a.Response{
 			JSONRPC: "2.0",
 			ID:      req.ID,
@@ -411,8 +624,9 @@ func (s *Server) a2aMessageSend(c *fiber.Ctx, req a2a.Request) error {
 		})
 	}
 
-	result, err := s.agent.ProcessMessage(ctx, conv, text)
+	result, err := s.agent.ProcessMessage(ctx, conv, text, attachments)
 	if err != nil {
+		s.discardAttachments(files, attachments)
 		return c.JSON(a2a.Response{
 			JSONRPC: "2.0",
 			ID:      req.ID,
@@ -430,7 +644,7 @@ func (s *Server) a2aMessageSend(c *fiber.Ctx, req a2a.Request) error {
 		})
 	}
 
-	task := conversationToTask(updatedConv, result.Response, result.AuthRequired)
+	task := resultToTask(updatedConv, result)
 	taskBytes, err := json.Marshal(task)
 	if err != nil {
 		return c.JSON(a2a.Response{
@@ -503,6 +717,16 @@ func (s *Server) a2aTasksGet(c *fiber.Ctx, req a2a.Request) error {
 	})
 }
 
+// resultToTask maps a conversation and the result of its latest request to an
+// A2A Task, with the token usage of the request in the task metadata.
+func resultToTask(conv *conversation.Conversation, result *agent.ProcessResult) a2a.Task {
+	task := conversationToTask(conv, result.Response, result.AuthRequired)
+	if result.Usage != nil {
+		task.Metadata = map[string]any{"usage": result.Usage}
+	}
+	return task
+}
+
 // conversationToTask maps a conversation to an A2A Task.
 // If authRequired is true, the task state is set to "auth-required".
 func conversationToTask(conv *conversation.Conversation, responseText string, authRequired bool) a2a.Task {
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-stop-and-go/internal/agent"
+	"agent-stop-and-go/internal/config"
+	"agent-stop-and-go/internal/conversation"
+	"agent-stop-and-go/internal/llm"
+	"agent-stop-and-go/internal/storage"
+)
+
+func TestAttachments_RejectedMessagesKeepNoFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Name: "test"}
+	s := New(cfg, agent.New(cfg, store))
+
+	uploaded, err := store.SaveFile("notes.txt", "", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiting := conversation.New("", "")
+	waiting.SetWaitingApproval("resources_add", map[string]any{"name": "db"}, "Add resource db")
+	if err := store.SaveConversation(waiting); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "unknown conversation",
+			path:       "/conversations/00000000-0000-0000-0000-000000000000/messages",
+			body:       `{"message": "see file", "attachments": [{"name": "a.txt", "data": "aGVsbG8="}]}`,
+			wantStatus: 404,
+		},
+		{
+			name:       "conversation waiting for approval",
+			path:       "/conversations/" + waiting.ID + "/messages",
+			body:       `{"message": "see file", "attachments": [{"name": "a.txt", "data": "aGVsbG8="}]}`,
+			wantStatus: 200,
+		},
+		{
+			name:       "invalid attachment after an inline one",
+			path:       "/conversations",
+			body:       `{"message": "see files", "attachments": [{"name": "a.txt", "data": "aGVsbG8="}, {"data": "not base64!"}]}`,
+			wantStatus: 400,
+		},
+		{
+			name:       "invalid attachment after an uploaded one",
+			path:       "/conversations",
+			body:       `{"message": "see files", "attachments": [{"file_id": "` + uploaded.ID + `"}, {}]}`,
+			wantStatus: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := s.app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			// Only the uploaded file and its metadata remain
+			entries, err := os.ReadDir(filepath.Join(dir, "files"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 2 {
+				var names []string
+				for _, e := range entries {
+					names = append(names, e.Name())
+				}
+				t.Errorf("files = %v, want only %s", names, uploaded.ID)
+			}
+		})
+	}
+}
+
+func TestResultToTask_Usage(t *testing.T) {
+	conv := conversation.New("", "")
+	usage := &llm.Usage{InputTokens: 120, OutputTokens: 8}
+
+	task := resultToTask(conv, &agent.ProcessResult{Response: "done", Usage: usage})
+	if task.Status.State != "completed" || task.Metadata["usage"] != usage {
+		t.Errorf("task = %+v, want completed with the usage in metadata", task)
+	}
+
+	task = resultToTask(conv, &agent.ProcessResult{Response: "done"})
+	if task.Metadata != nil {
+		t.Errorf("metadata = %v, want none without usage", task.Metadata)
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"agent-stop-and-go/internal/agent"
+	"agent-stop-and-go/internal/auth"
+	"agent-stop-and-go/internal/conversation"
+)
+
+// resolveApprovalTool is the MCP tool that answers a pending approval.
+const resolveApprovalTool = "resolve_approval"
+
+// invalidToolChars matches the characters not allowed in MCP tool names.
+var invalidToolChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// mcpToolName returns the name of the MCP tool running the agent.
+func mcpToolName(agentName string) string {
+	name := strings.Trim(invalidToolChars.ReplaceAllString(agentName, "_"), "_")
+	if name == "" || name == resolveApprovalTool {
+		return "agent"
+	}
+	return name
+}
+
+// newMCPHandler serves the agent over MCP Streamable HTTP: one tool sends a
+// message to the agent, another resolves the approvals it asks for.
+func (s *Server) newMCPHandler() fiber.Handler {
+	mcpServer := server.NewMCPServer(s.config.Name, "1.0.0",
+		server.WithToolCapabilities(false),
+	)
+
+	description := s.config.Description
+	if description == "" {
+		description = "Send a message to the " + s.config.Name + " agent."
+	}
+	mcpServer.AddTool(
+		mcp.NewTool(mcpToolName(s.config.Name),
+			mcp.WithDescription(description+" Actions that need approval return an error with an approval_uuid: call "+resolveApprovalTool+" to continue."),
+			mcp.WithString("message", mcp.Required(), mcp.Description("Message for the agent")),
+			mcp.WithString("conversation_id", mcp.Description("Continue an existing conversation (returned by previous calls)")),
+		),
+		s.mcpSendMessage,
+	)
+	mcpServer.AddTool(
+		mcp.NewTool(resolveApprovalTool,
+			mcp.WithDescription("Approve or reject an action the agent asked approval for, and continue the conversation."),
+			mcp.WithString("approval_uuid", mcp.Required(), mcp.Description("approval_uuid returned by the agent")),
+			mcp.WithBoolean("approved", mcp.Required(), mcp.Description("true to run the action, false to cancel it")),
+		),
+		s.mcpResolveApproval,
+	)
+
+	// Streaming responses are not supported through the fiber adaptor: answer with JSON only
+	handler := server.NewStreamableHTTPServer(mcpServer,
+		server.WithDisableStreaming(true),
+		server.WithHTTPContextFunc(mcpRequestContext),
+	)
+	return adaptor.HTTPHandler(handler)
+}
+
+// mcpRequestContext adds the caller's Bearer token and session ID to the
+// context of an MCP request, like extractContext for REST requests.
+func mcpRequestContext(ctx context.Context, r *http.Request) context.Context {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		ctx = auth.WithBearerToken(ctx, token)
+	}
+	sid := r.Header.Get("X-Session-ID")
+	if sid == "" {
+		sid = auth.GenerateSessionID()
+	}
+	return auth.WithSessionID(ctx, sid)
+}
+
+// mcpSendMessage handles the agent tool: it sends the message to a new or
+// existing conversation and returns the agent's response.
+func (s *Server) mcpSendMessage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	message, err := req.RequireString("message")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var conv *conversation.Conversation
+	if id := req.GetString("conversation_id", ""); id != "" {
+		conv, err = s.agent.GetConversation(id)
+	} else {
+		conv, err = s.agent.StartConversation(ctx)
+	}
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	result, err := s.agent.ProcessMessage(ctx, conv, message, nil)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return mcpProcessResult(conv.ID, result), nil
+}
+
+// mcpResolveApproval handles the resolve_approval tool.
+func (s *Server) mcpResolveApproval(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	uuid, err := req.RequireString("approval_uuid")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	approved, err := req.RequireBool("approved")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	conv, result, err := s.agent.ResolveApproval(ctx, uuid, approved)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return mcpProcessResult(conv.ID, result), nil
+}
+
+// mcpProcessResult converts the result of a message or approval into an MCP
+// tool result. The conversation ID follows the response, in a second text
+// block and in the structured content, so that clients can continue the
+// conversation. Approvals and authentication requests are error results so
+// that clients do not take them for an answer.
+func mcpProcessResult(convID string, result *agent.ProcessResult) *mcp.CallToolResult {
+	structured := map[string]any{
+		"conversation_id": convID,
+		"response":        result.Response,
+	}
+
+	text := result.Response
+	isError := false
+	switch {
+	case result.WaitingApproval && result.Approval != nil:
+		structured["approval_uuid"] = result.Approval.UUID
+		text = fmt.Sprintf("Approval required: %s\n\nCall %s with approval_uuid %q and approved true or false to continue.",
+			result.Approval.Description, resolveApprovalTool, result.Approval.UUID)
+		isError = true
+	case result.AuthRequired:
+		structured["auth_required"] = true
+		isError = true
+	}
+	if result.Usage != nil {
+		structured["usage"] = result.Usage
+	}
+
+	r := mcp.NewToolResultStructured(structured, text)
+	r.Content = append(r.Content, mcp.NewTextContent("conversation_id: "+convID))
+	r.IsError = isError
+	return r
+}
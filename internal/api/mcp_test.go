@@ -0,0 +1,276 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"agent-stop-and-go/internal/agent"
+	"agent-stop-and-go/internal/config"
+	"agent-stop-and-go/internal/conversation"
+	"agent-stop-and-go/internal/llm"
+	"agent-stop-and-go/internal/storage"
+)
+
+func TestMCPToolName(t *testing.T) {
+	tests := []struct {
+		agentName string
+		want      string
+	}{
+		{"resource-agent", "resource-agent"},
+		{"My Agent v2", "My_Agent_v2"},
+		{"  agent.ops!  ", "agent_ops"},
+		{"", "agent"},
+		{"***", "agent"},
+		{"resolve_approval", "agent"},
+	}
+
+	for _, tt := range tests {
+		if got := mcpToolName(tt.agentName); got != tt.want {
+			t.Errorf("mcpToolName(%q) = %q, want %q", tt.agentName, got, tt.want)
+		}
+	}
+}
+
+func TestMCPProcessResult(t *testing.T) {
+	usage := &llm.Usage{InputTokens: 120, OutputTokens: 8}
+
+	tests := []struct {
+		name           string
+		result         *agent.ProcessResult
+		wantError      bool
+		wantText       string
+		wantStructured map[string]any
+	}{
+		{
+			name:           "response",
+			result:         &agent.ProcessResult{Response: "3 resources"},
+			wantText:       "3 resources",
+			wantStructured: map[string]any{"conversation_id": "conv-1", "response": "3 resources"},
+		},
+		{
+			name: "approval",
+			result: &agent.ProcessResult{
+				Response:        "This action requires approval",
+				WaitingApproval: true,
+				Approval:        &conversation.PendingApproval{UUID: "uuid-1", Description: "Add resource db"},
+			},
+			wantError:      true,
+			wantText:       `Approval required: Add resource db` + "\n\n" + `Call resolve_approval with approval_uuid "uuid-1" and approved true or false to continue.`,
+			wantStructured: map[string]any{"conversation_id": "conv-1", "response": "This action requires approval", "approval_uuid": "uuid-1"},
+		},
+		{
+			name:           "auth required",
+			result:         &agent.ProcessResult{Response: "Authentication required to access the resources server.", AuthRequired: true},
+			wantError:      true,
+			wantText:       "Authentication required to access the resources server.",
+			wantStructured: map[string]any{"conversation_id": "conv-1", "response": "Authentication required to access the resources server.", "auth_required": true},
+		},
+		{
+			name:           "usage",
+			result:         &agent.ProcessResult{Response: "3 resources", Usage: usage},
+			wantText:       "3 resources",
+			wantStructured: map[string]any{"conversation_id": "conv-1", "response": "3 resources", "usage": usage},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mcpProcessResult("conv-1", tt.result)
+
+			if r.IsError != tt.wantError {
+				t.Errorf("IsError = %v, want %v", r.IsError, tt.wantError)
+			}
+			if len(r.Content) != 2 {
+				t.Fatalf("got %d content blocks, want text and conversation ID", len(r.Content))
+			}
+			if text, _ := r.Content[0].(mcp.TextContent); text.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", text.Text, tt.wantText)
+			}
+			if text, _ := r.Content[1].(mcp.TextContent); text.Text != "conversation_id: conv-1" {
+				t.Errorf("second block = %q, want the conversation ID", text.Text)
+			}
+
+			structured, _ := r.StructuredContent.(map[string]any)
+			if len(structured) != len(tt.wantStructured) {
+				t.Errorf("structured = %v, want %v", structured, tt.wantStructured)
+			}
+			for k, want := range tt.wantStructured {
+				if structured[k] != want {
+					t.Errorf("structured[%q] = %v, want %v", k, structured[k], want)
+				}
+			}
+		})
+	}
+}
+
+// newFakeLLM serves the OpenAI-compatible API of the ollama provider. The
+// model calls resources_add for "db", then answers once a tool result is in
+// the conversation.
+func newFakeLLM(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role string `json:"role"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		message := map[string]any{"role": "assistant", "content": "", "tool_calls": []map[string]any{{
+			"id": "call_1", "type": "function",
+			"function": map[string]any{"name": "resources_add", "arguments": `{"name": "db"}`},
+		}}}
+		for _, m := range req.Messages {
+			if m.Role == "tool" {
+				message = map[string]any{"role": "assistant", "content": "Resource added."}
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"choices": []map[string]any{{"message": message}}})
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("OLLAMA_BASE_URL", srv.URL)
+}
+
+// newMCPTestServer starts an agent whose LLM adds a resource with the
+// destructive resources_add tool of an MCP server, and serves its API.
+// It returns an MCP client connected to the agent's /mcp endpoint and the
+// channel receiving the names of the added resources.
+func newMCPTestServer(t *testing.T) (*mcpclient.Client, <-chan string) {
+	t.Helper()
+
+	added := make(chan string, 1)
+
+	resources := server.NewMCPServer("resources", "1.0.0", server.WithToolCapabilities(false))
+	resources.AddTool(
+		mcp.NewTool("resources_add", mcp.WithString("name", mcp.Required()), mcp.WithDestructiveHintAnnotation(true)),
+		func(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			added <- req.GetString("name", "")
+			return mcp.NewToolResultText("added"), nil
+		})
+	resourcesSrv := httptest.NewServer(server.NewStreamableHTTPServer(resources))
+	t.Cleanup(func() {
+		resourcesSrv.CloseClientConnections()
+		resourcesSrv.Close()
+	})
+
+	newFakeLLM(t)
+
+	cfg := &config.Config{
+		Name:       "Resource Agent",
+		LLM:        config.LLMConfig{Model: config.ModelList{"ollama:test"}},
+		MCPServers: []config.MCPServerConfig{{Name: "resources", URL: resourcesSrv.URL}},
+	}
+	store, err := storage.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ag := agent.New(cfg, store)
+	if err := ag.Start(); err != nil {
+		t.Fatalf("agent Start() error: %v", err)
+	}
+	t.Cleanup(func() { ag.Stop() })
+
+	s := New(cfg, ag)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Closing the listener stops the app: fasthttp's Shutdown races with the
+	// request contexts of the MCP adaptor
+	go s.app.Listener(ln)
+	t.Cleanup(func() { ln.Close() })
+
+	client, err := mcpclient.NewStreamableHttpClient("http://" + ln.Addr().String() + "/mcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if err := client.Start(context.Background()); err != nil {
+		t.Fatalf("client Start() error: %v", err)
+	}
+	init := mcp.InitializeRequest{}
+	init.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	init.Params.ClientInfo = mcp.Implementation{Name: "test", Version: "1.0.0"}
+	if _, err := client.Initialize(context.Background(), init); err != nil {
+		t.Fatalf("Initialize() error: %v", err)
+	}
+	return client, added
+}
+
+func callTool(t *testing.T, client *mcpclient.Client, name string, args map[string]any) (*mcp.CallToolResult, map[string]any) {
+	t.Helper()
+	req := mcp.CallToolRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+	result, err := client.CallTool(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CallTool(%s) error: %v", name, err)
+	}
+	structured, _ := result.StructuredContent.(map[string]any)
+	return result, structured
+}
+
+func TestMCPHandler_ApprovalRoundTrip(t *testing.T) {
+	client, added := newMCPTestServer(t)
+
+	tools, err := client.ListTools(context.Background(), mcp.ListToolsRequest{})
+	if err != nil {
+		t.Fatalf("ListTools() error: %v", err)
+	}
+	var names []string
+	for _, tool := range tools.Tools {
+		names = append(names, tool.Name)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"Resource_Agent", "resolve_approval"}) {
+		t.Fatalf("tools = %v, want Resource_Agent and resolve_approval", names)
+	}
+
+	// The destructive tool call asks for approval
+	result, structured := callTool(t, client, "Resource_Agent", map[string]any{"message": "add db"})
+	uuid, _ := structured["approval_uuid"].(string)
+	convID, _ := structured["conversation_id"].(string)
+	if !result.IsError || uuid == "" || convID == "" {
+		t.Fatalf("send result = %+v, want an approval error with approval_uuid and conversation_id", result)
+	}
+
+	// Approving it runs the tool and continues the conversation
+	result, structured = callTool(t, client, resolveApprovalTool, map[string]any{"approval_uuid": uuid, "approved": true})
+	if result.IsError {
+		t.Fatalf("resolve result = %+v, want success", result)
+	}
+	select {
+	case name := <-added:
+		if name != "db" {
+			t.Errorf("added resource %q, want db", name)
+		}
+	default:
+		t.Error("resources_add was not called")
+	}
+	if structured["response"] != "Resource added." || structured["conversation_id"] != convID {
+		t.Errorf("resolve structured = %v, want the final response of conversation %s", structured, convID)
+	}
+
+	// The approval can only be resolved once
+	result, _ = callTool(t, client, resolveApprovalTool, map[string]any{"approval_uuid": uuid, "approved": true})
+	if !result.IsError {
+		t.Errorf("second resolve result = %+v, want an error", result)
+	}
+
+	// Missing arguments are tool errors
+	result, _ = callTool(t, client, "Resource_Agent", map[string]any{})
+	if !result.IsError {
+		t.Errorf("result without message = %+v, want an error", result)
+	}
+}
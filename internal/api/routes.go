@@ -15,12 +15,19 @@ func (s *Server) setupRoutes() {
 	s.app.Post("/conversations", s.createConversationHandler)
 	s.app.Get("/conversations", s.listConversationsHandler)
 	s.app.Get("/conversations/:id", s.getConversationHandler)
+	s.app.Get("/conversations/:id/events", s.conversationEventsHandler)
 	s.app.Post("/conversations/:id/messages", s.sendMessageHandler)
 
+	// File uploads (message attachments)
+	s.app.Post("/files", s.uploadFileHandler)
+
 	// Approval routes
 	s.app.Post("/approvals/:uuid", s.resolveApprovalHandler)
 
 	// A2A server routes
 	s.app.Get("/.well-known/agent.json", s.agentCardHandler)
 	s.app.Post("/a2a", s.a2aHandler)
+
+	// MCP server route (Streamable HTTP)
+	s.app.All("/mcp", s.newMCPHandler())
 }
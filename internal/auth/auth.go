@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"strings"
 )
 
 type bearerKey struct{}
@@ -39,3 +40,55 @@ func GenerateSessionID() string {
 	_, _ = rand.Read(b)
 	return hex.EncodeToString(b)
 }
+
+// Mode selects the credentials sent to an upstream MCP server or A2A agent.
+type Mode string
+
+const (
+	// ModeForward forwards the caller's Bearer token (default).
+	ModeForward Mode = "forward"
+	// ModeStatic sends a configured Bearer token instead of the caller's.
+	ModeStatic Mode = "static"
+	// ModeNone sends no Authorization header.
+	ModeNone Mode = "none"
+)
+
+// Outbound describes the headers sent with requests to an upstream server.
+type Outbound struct {
+	Mode    Mode              // empty means ModeForward
+	Token   string            // Bearer token for ModeStatic
+	Headers map[string]string // static headers sent with every request
+}
+
+// RequestHeaders returns the headers to send with a request made on behalf of ctx.
+// The Authorization header set by the mode replaces a static one, except
+// in forward mode when the caller has no token.
+func (o Outbound) RequestHeaders(ctx context.Context) map[string]string {
+	headers := make(map[string]string, len(o.Headers)+1)
+	for k, v := range o.Headers {
+		headers[k] = v
+	}
+
+	switch o.Mode {
+	case ModeStatic:
+		deleteHeader(headers, "Authorization")
+		headers["Authorization"] = "Bearer " + o.Token
+	case ModeNone:
+		deleteHeader(headers, "Authorization")
+	default:
+		if token := BearerToken(ctx); token != "" {
+			deleteHeader(headers, "Authorization")
+			headers["Authorization"] = "Bearer " + token
+		}
+	}
+	return headers
+}
+
+// deleteHeader removes a header whatever the case of its configured name.
+func deleteHeader(headers map[string]string, name string) {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			delete(headers, k)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"maps"
 	"testing"
 )
 
@@ -65,3 +66,51 @@ func TestGenerateSessionID(t *testing.T) {
 		t.Error("GenerateSessionID() returned duplicate IDs")
 	}
 }
+
+func TestOutboundHeaders(t *testing.T) {
+	static := map[string]string{"X-API-Key": "k", "authorization": "Bearer configured"}
+
+	tests := []struct {
+		name     string
+		outbound Outbound
+		token    string
+		want     map[string]string
+	}{
+		{
+			name:     "forward caller token",
+			outbound: Outbound{Headers: map[string]string{"X-API-Key": "k"}},
+			token:    "user",
+			want:     map[string]string{"X-API-Key": "k", "Authorization": "Bearer user"},
+		},
+		{
+			name:     "forward without caller token keeps static header",
+			outbound: Outbound{Mode: ModeForward, Headers: static},
+			want:     map[string]string{"X-API-Key": "k", "authorization": "Bearer configured"},
+		},
+		{
+			name:     "static token replaces caller token",
+			outbound: Outbound{Mode: ModeStatic, Token: "server"},
+			token:    "user",
+			want:     map[string]string{"Authorization": "Bearer server"},
+		},
+		{
+			name:     "none drops all authorization",
+			outbound: Outbound{Mode: ModeNone, Headers: static},
+			token:    "user",
+			want:     map[string]string{"X-API-Key": "k"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = WithBearerToken(ctx, tt.token)
+			}
+			got := tt.outbound.RequestHeaders(ctx)
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("RequestHeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,21 +3,279 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"agent-stop-and-go/internal/auth"
+)
+
+const (
+	// DefaultToolsRefreshInterval is the default period of the MCP tools/list fallback refresh.
+	DefaultToolsRefreshInterval = 5 * time.Minute
+	// DefaultHTTPMaxConcurrency is the default number of in-flight requests per HTTP MCP server.
+	DefaultHTTPMaxConcurrency = 10
+	// DefaultHealthInterval is the default period of MCP server health checks.
+	DefaultHealthInterval = 30 * time.Second
+	// DefaultShutdownTimeout is the default grace period of each stdio MCP server shutdown step.
+	DefaultShutdownTimeout = 5 * time.Second
+	// DefaultSamplingMaxTokens is the default cap on the tokens of each MCP sampling completion.
+	DefaultSamplingMaxTokens = 1024
 )
 
+// SamplingConfig lets an MCP server request completions from the agent's LLM
+// (MCP sampling/createMessage). Servers without it cannot sample.
+type SamplingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxTokens caps the tokens of each completion, whatever the server asks
+	// for. Defaults to 1024.
+	MaxTokens int `yaml:"max_tokens,omitempty"`
+}
+
+// UpstreamAuth holds the headers and credentials sent to an HTTP MCP server
+// or A2A agent. Token and header values may reference the agent's
+// environment as ${VAR}.
+type UpstreamAuth struct {
+	Auth    string            `yaml:"auth,omitempty"`    // forward (default): caller's Bearer token, static: Token, none
+	Token   string            `yaml:"token,omitempty"`   // Bearer token for auth: static
+	Headers map[string]string `yaml:"headers,omitempty"` // sent with every request
+}
+
+// Outbound converts the configuration for the MCP and A2A clients.
+func (u UpstreamAuth) Outbound() auth.Outbound {
+	return auth.Outbound{Mode: auth.Mode(u.Auth), Token: u.Token, Headers: u.Headers}
+}
+
+// IsSet reports whether any option differs from the default.
+func (u UpstreamAuth) IsSet() bool {
+	return u.Auth != "" || u.Token != "" || len(u.Headers) > 0
+}
+
+// expand interpolates environment variables in the token and header values.
+func (u *UpstreamAuth) expand() {
+	u.Token = os.ExpandEnv(u.Token)
+	for k, v := range u.Headers {
+		u.Headers[k] = os.ExpandEnv(v)
+	}
+}
+
+// validate checks the auth mode and that static mode has a token.
+func (u UpstreamAuth) validate() error {
+	switch auth.Mode(u.Auth) {
+	case "", auth.ModeForward, auth.ModeNone:
+	case auth.ModeStatic:
+		if u.Token == "" {
+			return fmt.Errorf("auth: static requires a token")
+		}
+	default:
+		return fmt.Errorf("invalid auth %q (must be forward, static or none)", u.Auth)
+	}
+	return nil
+}
+
 // MCPServerConfig holds the configuration for a single MCP server.
 type MCPServerConfig struct {
 	Name    string   `yaml:"name"`    // Unique server name (required)
 	URL     string   `yaml:"url"`     // Streamable HTTP endpoint
 	Command string   `yaml:"command"` // stdio subprocess command
 	Args    []string `yaml:"args"`    // stdio subprocess args
+	// RefreshInterval re-lists the server's tools periodically, as a fallback for
+	// missed list_changed notifications. Defaults to 5m; a negative value disables it.
+	RefreshInterval time.Duration `yaml:"refresh_interval,omitempty"`
+	// MaxConcurrency caps in-flight requests to the server. Defaults to 10 for
+	// HTTP servers and 1 for stdio servers, which cannot multiplex requests.
+	MaxConcurrency int `yaml:"max_concurrency,omitempty"`
+	// HealthInterval pings the server periodically and reconnects (or restarts the
+	// stdio process) when it fails. Defaults to 30s; a negative value disables it.
+	HealthInterval time.Duration `yaml:"health_interval,omitempty"`
+	// Required servers must start for the agent to boot (default). Optional
+	// servers (required: false) that fail are retried in the background.
+	Required *bool `yaml:"required,omitempty"`
+	// ToolPrefix is prepended to the names of the server's tools as seen by the LLM.
+	ToolPrefix string `yaml:"tool_prefix,omitempty"`
+	// Env sets environment variables for a stdio server. Values may reference the
+	// agent's environment as ${VAR}. The server does not inherit the agent's
+	// environment beyond a minimal base (PATH, HOME, ...) and EnvPassthrough.
+	Env map[string]string `yaml:"env,omitempty"`
+	// EnvPassthrough lists agent environment variables passed to a stdio server:
+	// exact names, prefixes ending in "*" (e.g. "AWS_*"), or "*" for all.
+	EnvPassthrough []string `yaml:"env_passthrough,omitempty"`
+	// Cwd is the working directory of a stdio server (default: the agent's).
+	Cwd string `yaml:"cwd,omitempty"`
+	// ShutdownTimeout is how long a stdio server gets to exit after its stdin is
+	// closed, and again after SIGTERM, before it is killed. Defaults to 5s.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout,omitempty"`
+	// Sampling allows the server to request completions from the agent's LLM.
+	Sampling SamplingConfig `yaml:"sampling,omitempty"`
+	// UpstreamAuth sets the headers and credentials sent to an HTTP server.
+	UpstreamAuth `yaml:",inline"`
+}
+
+// IsRequired reports whether the agent must fail to start when the server is unreachable.
+func (s MCPServerConfig) IsRequired() bool {
+	return s.Required == nil || *s.Required
 }
 
 // LLMConfig holds the LLM configuration.
 type LLMConfig struct {
-	Model string `yaml:"model"`
+	Model ModelList `yaml:"model"`
+	// Generation sets the default generation parameters of all LLM nodes.
+	Generation GenerationConfig `yaml:"generation,omitempty"`
+	// RateLimits limits the requests sent to each provider ("google",
+	// "anthropic", "openai"...), shared by all the models of the provider.
+	RateLimits map[string]RateLimit `yaml:"rate_limits,omitempty"`
+	// Context bounds the conversation history sent on each turn.
+	Context ContextConfig `yaml:"context,omitempty"`
+	// PromptCaching caches the system prompt and tools of each request
+	// (Anthropic cache_control, OpenAI prompt_cache_key).
+	PromptCaching bool `yaml:"prompt_caching,omitempty"`
+}
+
+// Context strategies applied when the history exceeds ContextConfig.MaxTokens.
+const (
+	ContextTruncate  = "truncate"  // drop the oldest turns
+	ContextSummarize = "summarize" // replace the oldest turns with a summary
+)
+
+// ContextConfig bounds the conversation history sent to the LLM. Token counts
+// are estimated (4 characters per token); 0 means no limit.
+type ContextConfig struct {
+	MaxToolResultTokens int       `yaml:"max_tool_result_tokens,omitempty"` // cap of each tool result
+	MaxTokens           int       `yaml:"max_tokens,omitempty"`             // budget of the whole history
+	Strategy            string    `yaml:"strategy,omitempty"`               // "truncate" (default) or "summarize"
+	SummaryModel        ModelList `yaml:"summary_model,omitempty"`          // defaults to llm.model
+}
+
+// validate checks the strategy and the budgets.
+func (c ContextConfig) validate() error {
+	if c.MaxToolResultTokens < 0 || c.MaxTokens < 0 {
+		return fmt.Errorf("context: token budgets must not be negative")
+	}
+	switch c.Strategy {
+	case "", ContextTruncate, ContextSummarize:
+	default:
+		return fmt.Errorf("context.strategy: unknown strategy %q (use %s or %s)", c.Strategy, ContextTruncate, ContextSummarize)
+	}
+	return nil
+}
+
+// GenerationConfig holds the generation parameters of LLM requests. Unset
+// fields keep the provider's default.
+type GenerationConfig struct {
+	Temperature   *float64 `yaml:"temperature,omitempty"` // 0 to 2 (0 to 1 for Anthropic)
+	TopP          *float64 `yaml:"top_p,omitempty"`       // 0 to 1
+	MaxTokens     int      `yaml:"max_tokens,omitempty"`  // maximum output tokens
+	StopSequences []string `yaml:"stop_sequences,omitempty"`
+	JSON          bool     `yaml:"json,omitempty"` // JSON object output, where the provider supports it
+}
+
+// Merge returns g with the fields set in override replacing its own.
+func (g GenerationConfig) Merge(override GenerationConfig) GenerationConfig {
+	if override.Temperature != nil {
+		g.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		g.TopP = override.TopP
+	}
+	if override.MaxTokens != 0 {
+		g.MaxTokens = override.MaxTokens
+	}
+	if override.StopSequences != nil {
+		g.StopSequences = override.StopSequences
+	}
+	if override.JSON {
+		g.JSON = true
+	}
+	return g
+}
+
+// validate checks that the parameters are within the ranges of the providers.
+func (g GenerationConfig) validate() error {
+	if g.Temperature != nil && (*g.Temperature < 0 || *g.Temperature > 2) {
+		return fmt.Errorf("generation.temperature must be between 0 and 2")
+	}
+	if g.TopP != nil && (*g.TopP < 0 || *g.TopP > 1) {
+		return fmt.Errorf("generation.top_p must be between 0 and 1")
+	}
+	if g.MaxTokens < 0 {
+		return fmt.Errorf("generation.max_tokens must not be negative")
+	}
+	return nil
+}
+
+// LLMProvider declares an OpenAI-compatible API (vLLM, LM Studio, llama.cpp,
+// gateways...), used with "<name>:<model>" like the built-in providers.
+type LLMProvider struct {
+	Name        string            `yaml:"name"`
+	BaseURL     string            `yaml:"base_url"`               // e.g. "http://localhost:8000/v1"
+	APIKeyEnv   string            `yaml:"api_key_env,omitempty"`  // environment variable holding the API key
+	Headers     map[string]string `yaml:"headers,omitempty"`      // values expand ${VAR}
+	Tools       *bool             `yaml:"tools,omitempty"`        // default: true
+	JSONMode    bool              `yaml:"json_mode,omitempty"`    // supports response_format json_object
+	PromptCache bool              `yaml:"prompt_cache,omitempty"` // supports prompt_cache_key
+	Vision      bool              `yaml:"vision,omitempty"`       // accepts images and PDF files
+}
+
+// SupportsTools reports whether the provider supports function calling.
+func (p LLMProvider) SupportsTools() bool {
+	return p.Tools == nil || *p.Tools
+}
+
+// validateLLMProviders checks that each provider has a unique name and a base URL.
+func validateLLMProviders(providers []LLMProvider) error {
+	seen := make(map[string]bool)
+	for i, p := range providers {
+		if p.Name == "" || strings.Contains(p.Name, ":") {
+			return fmt.Errorf("llm_providers[%d]: invalid name %q", i, p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("llm_providers: duplicate name %q", p.Name)
+		}
+		seen[p.Name] = true
+		if p.BaseURL == "" {
+			return fmt.Errorf("llm_providers.%s: base_url is required", p.Name)
+		}
+	}
+	return nil
+}
+
+// RateLimit is a client-side limit on the requests sent to an LLM provider.
+// 0 means no limit.
+type RateLimit struct {
+	RequestsPerMinute int `yaml:"requests_per_minute,omitempty"`
+	TokensPerMinute   int `yaml:"tokens_per_minute,omitempty"` // estimated input tokens
+}
+
+// ModelList is an ordered list of "provider:model" names: when a model fails
+// with a retryable error (rate limit, quota, server error...), the next one
+// answers. It is written as a single model or as a YAML list.
+type ModelList []string
+
+// UnmarshalYAML accepts a single model name as well as a list.
+func (m *ModelList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var model string
+		if err := value.Decode(&model); err != nil {
+			return err
+		}
+		*m = nil
+		if model != "" {
+			*m = ModelList{model}
+		}
+		return nil
+	}
+	var models []string
+	if err := value.Decode(&models); err != nil {
+		return err
+	}
+	*m = models
+	return nil
+}
+
+// String returns the models separated by commas.
+func (m ModelList) String() string {
+	return strings.Join(m, ",")
 }
 
 // A2AAgent holds the configuration for an A2A sub-agent.
@@ -26,36 +284,45 @@ type A2AAgent struct {
 	URL             string `yaml:"url"`
 	Description     string `yaml:"description"`
 	DestructiveHint bool   `yaml:"destructiveHint"`
+	UpstreamAuth    `yaml:",inline"`
 }
 
 // AgentNode defines a node in the agent orchestration tree.
 type AgentNode struct {
-	Name            string      `yaml:"name"`
-	Type            string      `yaml:"type"`                      // llm, sequential, parallel, loop, a2a
-	Model           string      `yaml:"model,omitempty"`           // llm: Gemini model name
-	Prompt          string      `yaml:"prompt,omitempty"`          // llm: system prompt, a2a: message template
-	OutputKey       string      `yaml:"output_key,omitempty"`      // key to store output in session state
-	CanExitLoop     bool        `yaml:"can_exit_loop,omitempty"`   // llm: gets exit_loop tool
-	MaxIterations   int         `yaml:"max_iterations,omitempty"`  // loop: max iterations (0 = 10 safety cap)
-	Agents          []AgentNode `yaml:"agents,omitempty"`          // sequential, parallel, loop: sub-agents
-	URL             string      `yaml:"url,omitempty"`             // a2a: remote agent URL
-	Description     string      `yaml:"description,omitempty"`     // a2a: agent description
-	DestructiveHint bool        `yaml:"destructiveHint,omitempty"` // a2a: requires approval
-	A2A             []A2AAgent  `yaml:"a2a,omitempty"`             // llm: local A2A tools
+	Name            string           `yaml:"name"`
+	Type            string           `yaml:"type"`                      // llm, sequential, parallel, loop, a2a
+	Model           ModelList        `yaml:"model,omitempty"`           // llm: model or fallback chain (default: llm.model)
+	Generation      GenerationConfig `yaml:"generation,omitempty"`      // llm: overrides llm.generation
+	Prompt          string           `yaml:"prompt,omitempty"`          // llm: system prompt, a2a: message template
+	OutputKey       string           `yaml:"output_key,omitempty"`      // key to store output in session state
+	CanExitLoop     bool             `yaml:"can_exit_loop,omitempty"`   // llm: gets exit_loop tool
+	MaxIterations   int              `yaml:"max_iterations,omitempty"`  // loop: max iterations (0 = 10 safety cap)
+	Agents          []AgentNode      `yaml:"agents,omitempty"`          // sequential, parallel, loop: sub-agents
+	URL             string           `yaml:"url,omitempty"`             // a2a: remote agent URL
+	Description     string           `yaml:"description,omitempty"`     // a2a: agent description
+	DestructiveHint bool             `yaml:"destructiveHint,omitempty"` // a2a: requires approval
+	A2A             []A2AAgent       `yaml:"a2a,omitempty"`             // llm: local A2A tools
+	Resources       []string         `yaml:"resources,omitempty"`       // llm: MCP resource URIs preloaded into the system prompt
+
+	// a2a: headers and credentials sent to the remote agent
+	UpstreamAuth `yaml:",inline"`
 }
 
 // Config holds the agent configuration loaded from agent.yaml.
 type Config struct {
-	Name        string            `yaml:"name"`
-	Description string            `yaml:"description"`
-	Prompt      string            `yaml:"prompt"`
-	Host        string            `yaml:"host"`
-	Port        int               `yaml:"port"`
-	DataDir     string            `yaml:"data_dir"`
-	LLM         LLMConfig         `yaml:"llm"`
-	MCPServers  []MCPServerConfig `yaml:"mcp_servers"`
-	A2A         []A2AAgent        `yaml:"a2a"`
-	Agent       *AgentNode        `yaml:"agent,omitempty"` // Agent tree (overrides top-level prompt/llm/a2a)
+	Name              string            `yaml:"name"`
+	Description       string            `yaml:"description"`
+	Prompt            string            `yaml:"prompt"`
+	Host              string            `yaml:"host"`
+	Port              int               `yaml:"port"`
+	DataDir           string            `yaml:"data_dir"`
+	LLM               LLMConfig         `yaml:"llm"`
+	LLMProviders      []LLMProvider     `yaml:"llm_providers,omitempty"` // custom OpenAI-compatible providers
+	MCPServers        []MCPServerConfig `yaml:"mcp_servers"`
+	MCPNamespaceTools bool              `yaml:"mcp_namespace_tools,omitempty"` // prefix MCP tools with "<server>__" unless tool_prefix is set
+	A2A               []A2AAgent        `yaml:"a2a"`
+	Resources         []string          `yaml:"resources,omitempty"` // MCP resource URIs preloaded into the system prompt
+	Agent             *AgentNode        `yaml:"agent,omitempty"`     // Agent tree (overrides top-level prompt/llm/a2a)
 }
 
 // Load reads and parses the agent.yaml configuration file.
@@ -82,30 +349,90 @@ func Load(path string) (*Config, error) {
 	if cfg.DataDir == "" {
 		cfg.DataDir = "./data"
 	}
-	if cfg.LLM.Model == "" {
-		cfg.LLM.Model = "google:gemini-2.5-flash"
+	if len(cfg.LLM.Model) == 0 {
+		cfg.LLM.Model = ModelList{"google:gemini-2.5-flash"}
+	}
+
+	if err := cfg.LLM.Generation.validate(); err != nil {
+		return nil, fmt.Errorf("llm.%w", err)
+	}
+	if err := cfg.LLM.Context.validate(); err != nil {
+		return nil, fmt.Errorf("llm.%w", err)
+	}
+	for i := range cfg.LLMProviders {
+		for k, v := range cfg.LLMProviders[i].Headers {
+			cfg.LLMProviders[i].Headers[k] = os.ExpandEnv(v)
+		}
+	}
+	if err := validateLLMProviders(cfg.LLMProviders); err != nil {
+		return nil, err
+	}
+	if cfg.LLM.Context.Strategy == "" {
+		cfg.LLM.Context.Strategy = ContextTruncate
+	}
+	for provider, limit := range cfg.LLM.RateLimits {
+		if limit.RequestsPerMinute < 0 || limit.TokensPerMinute < 0 {
+			return nil, fmt.Errorf("llm.rate_limits.%s: limits must not be negative", provider)
+		}
 	}
 
 	// Validate MCP server configs
+	for i := range cfg.MCPServers {
+		cfg.MCPServers[i].UpstreamAuth.expand()
+	}
 	if err := validateMCPServers(cfg.MCPServers); err != nil {
 		return nil, err
 	}
+	for i := range cfg.MCPServers {
+		s := &cfg.MCPServers[i]
+		if s.RefreshInterval == 0 {
+			s.RefreshInterval = DefaultToolsRefreshInterval
+		}
+		if s.HealthInterval == 0 {
+			s.HealthInterval = DefaultHealthInterval
+		}
+		if s.MaxConcurrency == 0 {
+			s.MaxConcurrency = 1
+			if s.URL != "" {
+				s.MaxConcurrency = DefaultHTTPMaxConcurrency
+			}
+		}
+		if s.ShutdownTimeout == 0 {
+			s.ShutdownTimeout = DefaultShutdownTimeout
+		}
+		if s.Sampling.MaxTokens == 0 {
+			s.Sampling.MaxTokens = DefaultSamplingMaxTokens
+		}
+		for k, v := range s.Env {
+			s.Env[k] = os.ExpandEnv(v)
+		}
+	}
+
+	// Validate A2A agent credentials
+	if err := validateA2A(cfg.A2A, "a2a"); err != nil {
+		return nil, err
+	}
+	if err := validateAgentTree(cfg.Agent); err != nil {
+		return nil, err
+	}
 
 	// Synthesize default agent node from top-level fields when agent tree is not defined
 	if cfg.Agent == nil {
 		cfg.Agent = &AgentNode{
-			Type:   "llm",
-			Name:   cfg.Name,
-			Model:  cfg.LLM.Model,
-			Prompt: cfg.Prompt,
-			A2A:    cfg.A2A,
+			Type:      "llm",
+			Name:      cfg.Name,
+			Model:     cfg.LLM.Model,
+			Prompt:    cfg.Prompt,
+			A2A:       cfg.A2A,
+			Resources: cfg.Resources,
 		}
 	}
 
 	return &cfg, nil
 }
 
-// validateMCPServers checks that all MCP server entries have a non-empty, unique name.
+// validateMCPServers checks that all MCP server entries have a non-empty, unique name
+// and settings supported by their transport.
 func validateMCPServers(servers []MCPServerConfig) error {
 	seen := make(map[string]bool, len(servers))
 	for i, s := range servers {
@@ -115,7 +442,62 @@ func validateMCPServers(servers []MCPServerConfig) error {
 		if seen[s.Name] {
 			return fmt.Errorf("mcp_servers: duplicate name %q", s.Name)
 		}
+		if s.MaxConcurrency < 0 {
+			return fmt.Errorf("mcp_servers[%d]: max_concurrency must not be negative", i)
+		}
+		if s.URL == "" && s.MaxConcurrency > 1 {
+			return fmt.Errorf("mcp_servers[%d]: max_concurrency > 1 is not supported for stdio servers", i)
+		}
+		if s.URL != "" && (len(s.Env) > 0 || len(s.EnvPassthrough) > 0 || s.Cwd != "") {
+			return fmt.Errorf("mcp_servers[%d]: env, env_passthrough and cwd only apply to stdio servers", i)
+		}
+		if s.ShutdownTimeout < 0 {
+			return fmt.Errorf("mcp_servers[%d]: shutdown_timeout must not be negative", i)
+		}
+		if s.Sampling.MaxTokens < 0 {
+			return fmt.Errorf("mcp_servers[%d]: sampling.max_tokens must not be negative", i)
+		}
+		if s.URL == "" && s.UpstreamAuth.IsSet() {
+			return fmt.Errorf("mcp_servers[%d]: auth, token and headers only apply to HTTP servers", i)
+		}
+		if err := s.UpstreamAuth.validate(); err != nil {
+			return fmt.Errorf("mcp_servers[%d]: %w", i, err)
+		}
 		seen[s.Name] = true
 	}
 	return nil
 }
+
+// validateA2A expands and checks the credentials of A2A agents.
+func validateA2A(agents []A2AAgent, field string) error {
+	for i := range agents {
+		agents[i].UpstreamAuth.expand()
+		if err := agents[i].UpstreamAuth.validate(); err != nil {
+			return fmt.Errorf("%s[%d]: %w", field, i, err)
+		}
+	}
+	return nil
+}
+
+// validateAgentTree expands and checks the credentials of A2A agents in the agent tree.
+func validateAgentTree(node *AgentNode) error {
+	if node == nil {
+		return nil
+	}
+	if err := validateA2A(node.A2A, "agent "+node.Name+": a2a"); err != nil {
+		return err
+	}
+	node.UpstreamAuth.expand()
+	if err := node.UpstreamAuth.validate(); err != nil {
+		return fmt.Errorf("agent %s: %w", node.Name, err)
+	}
+	if err := node.Generation.validate(); err != nil {
+		return fmt.Errorf("agent %s: %w", node.Name, err)
+	}
+	for i := range node.Agents {
+		if err := validateAgentTree(&node.Agents[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -68,7 +69,7 @@ func TestLoad(t *testing.T) {
 			if cfg.Port != tt.wantPort {
 				t.Errorf("Port = %d, want %d", cfg.Port, tt.wantPort)
 			}
-			if cfg.LLM.Model != tt.wantModel {
+			if cfg.LLM.Model.String() != tt.wantModel {
 				t.Errorf("LLM.Model = %q, want %q", cfg.LLM.Model, tt.wantModel)
 			}
 		})
@@ -99,6 +100,648 @@ func TestLoad_SynthesizesDefaultAgentNode(t *testing.T) {
 	}
 }
 
+func TestLoad_MCPServerRefreshInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.yaml")
+	yaml := `mcp_servers:
+  - name: default
+    command: ./bin/mcp
+  - name: fast
+    url: http://localhost:8090/mcp
+    refresh_interval: 30s
+  - name: off
+    url: http://localhost:8091/mcp
+    refresh_interval: -1s
+    health_interval: -1s
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []time.Duration{DefaultToolsRefreshInterval, 30 * time.Second, -time.Second}
+	for i, w := range want {
+		if got := cfg.MCPServers[i].RefreshInterval; got != w {
+			t.Errorf("MCPServers[%d].RefreshInterval = %v, want %v", i, got, w)
+		}
+	}
+	if got := cfg.MCPServers[0].HealthInterval; got != DefaultHealthInterval {
+		t.Errorf("MCPServers[0].HealthInterval = %v, want %v", got, DefaultHealthInterval)
+	}
+	if got := cfg.MCPServers[2].HealthInterval; got != -time.Second {
+		t.Errorf("MCPServers[2].HealthInterval = %v, want -1s", got)
+	}
+}
+
+func TestLoad_MCPServerMaxConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []int
+		wantErr string
+	}{
+		{
+			name: "defaults per transport",
+			yaml: "mcp_servers:\n  - name: stdio\n    command: ./bin/mcp\n  - name: http\n    url: http://localhost:8090/mcp\n",
+			want: []int{1, DefaultHTTPMaxConcurrency},
+		},
+		{
+			name: "explicit value for http",
+			yaml: "mcp_servers:\n  - name: http\n    url: http://localhost:8090/mcp\n    max_concurrency: 3\n",
+			want: []int{3},
+		},
+		{
+			name:    "stdio cannot multiplex",
+			yaml:    "mcp_servers:\n  - name: stdio\n    command: ./bin/mcp\n    max_concurrency: 4\n",
+			wantErr: "not supported for stdio",
+		},
+		{
+			name:    "negative value",
+			yaml:    "mcp_servers:\n  - name: http\n    url: http://localhost:8090/mcp\n    max_concurrency: -1\n",
+			wantErr: "must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "agent.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for i, w := range tt.want {
+				if got := cfg.MCPServers[i].MaxConcurrency; got != w {
+					t.Errorf("MCPServers[%d].MaxConcurrency = %d, want %d", i, got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_MCPServerRequired(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.yaml")
+	yaml := `mcp_servers:
+  - name: default
+    command: ./bin/mcp
+  - name: dev-db
+    url: http://localhost:8090/mcp
+    required: false
+  - name: explicit
+    url: http://localhost:8091/mcp
+    required: true
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []bool{true, false, true}
+	for i, w := range want {
+		if got := cfg.MCPServers[i].IsRequired(); got != w {
+			t.Errorf("MCPServers[%d].IsRequired() = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestLoad_MCPServerStdioEnv(t *testing.T) {
+	t.Setenv("TEST_MCP_TOKEN", "s3cret")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.yaml")
+	yaml := `mcp_servers:
+  - name: github
+    command: ./bin/mcp-github
+    env:
+      GITHUB_TOKEN: ${TEST_MCP_TOKEN}
+      LOG_LEVEL: debug
+    env_passthrough: [HTTPS_PROXY, "AWS_*"]
+    cwd: /srv/github
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := cfg.MCPServers[0]
+	if s.Env["GITHUB_TOKEN"] != "s3cret" || s.Env["LOG_LEVEL"] != "debug" {
+		t.Errorf("Env = %v, want interpolated values", s.Env)
+	}
+	if len(s.EnvPassthrough) != 2 || s.EnvPassthrough[1] != "AWS_*" {
+		t.Errorf("EnvPassthrough = %v", s.EnvPassthrough)
+	}
+	if s.Cwd != "/srv/github" {
+		t.Errorf("Cwd = %q", s.Cwd)
+	}
+	if s.ShutdownTimeout != DefaultShutdownTimeout {
+		t.Errorf("ShutdownTimeout = %v, want %v", s.ShutdownTimeout, DefaultShutdownTimeout)
+	}
+}
+
+func TestLoad_MCPServerValidation_StdioOnlyOptions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.yaml")
+	yaml := "mcp_servers:\n  - name: http\n    url: http://localhost:8090/mcp\n    cwd: /tmp\n"
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "only apply to stdio servers") {
+		t.Fatalf("error = %v, want stdio-only options error", err)
+	}
+}
+
+func TestLoad_MCPServerSampling(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.yaml")
+	yaml := "mcp_servers:\n  - name: fs\n    url: http://localhost:8091/mcp\n    sampling:\n      enabled: true\n  - name: capped\n    command: ./server\n    sampling:\n      enabled: true\n      max_tokens: 200\n  - name: other\n    command: ./other\n"
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	want := []SamplingConfig{
+		{Enabled: true, MaxTokens: DefaultSamplingMaxTokens},
+		{Enabled: true, MaxTokens: 200},
+		{Enabled: false, MaxTokens: DefaultSamplingMaxTokens},
+	}
+	for i, w := range want {
+		if got := cfg.MCPServers[i].Sampling; got != w {
+			t.Errorf("mcp_servers[%d].Sampling = %+v, want %+v", i, got, w)
+		}
+	}
+
+	yaml = "mcp_servers:\n  - name: fs\n    command: ./server\n    sampling:\n      enabled: true\n      max_tokens: -1\n"
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "sampling.max_tokens") {
+		t.Fatalf("error = %v, want sampling.max_tokens error", err)
+	}
+}
+
+func TestLoad_ModelFallbackChain(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.yaml")
+	yaml := `llm:
+  model: [google:gemini-2.5-flash, anthropic:claude-sonnet-4-6, ollama:llama3]
+agent:
+  name: pipeline
+  type: sequential
+  agents:
+    - name: step1
+      type: llm
+      model: openai:gpt-4o
+    - name: step2
+      type: llm
+      model:
+        - mistral:mistral-large-latest
+        - ollama:llama3
+    - name: step3
+      type: llm
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name string
+		got  ModelList
+		want string
+	}{
+		{"llm.model", cfg.LLM.Model, "google:gemini-2.5-flash,anthropic:claude-sonnet-4-6,ollama:llama3"},
+		{"single model", cfg.Agent.Agents[0].Model, "openai:gpt-4o"},
+		{"block list", cfg.Agent.Agents[1].Model, "mistral:mistral-large-latest,ollama:llama3"},
+		{"inherited", cfg.Agent.Agents[2].Model, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.got.String(); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoad_LLMRateLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    map[string]RateLimit
+		wantErr string
+	}{
+		{
+			name: "per provider limits",
+			yaml: "llm:\n  rate_limits:\n    google:\n      requests_per_minute: 60\n      tokens_per_minute: 100000\n    anthropic:\n      requests_per_minute: 50\n",
+			want: map[string]RateLimit{
+				"google":    {RequestsPerMinute: 60, TokensPerMinute: 100000},
+				"anthropic": {RequestsPerMinute: 50},
+			},
+		},
+		{
+			name: "no limits",
+			yaml: "llm:\n  model: google:gemini-2.5-flash\n",
+		},
+		{
+			name:    "negative limit",
+			yaml:    "llm:\n  rate_limits:\n    openai:\n      tokens_per_minute: -1\n",
+			wantErr: "llm.rate_limits.openai",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(cfg.LLM.RateLimits) != len(tt.want) {
+				t.Fatalf("RateLimits = %+v, want %+v", cfg.LLM.RateLimits, tt.want)
+			}
+			for provider, want := range tt.want {
+				if got := cfg.LLM.RateLimits[provider]; got != want {
+					t.Errorf("RateLimits[%s] = %+v, want %+v", provider, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_LLMProviders(t *testing.T) {
+	t.Setenv("GATEWAY_TEAM", "platform")
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name: "custom providers",
+			yaml: `llm:
+  model: vllm:qwen2.5-7b
+  prompt_caching: true
+llm_providers:
+  - name: vllm
+    base_url: http://gpu-box:8000/v1
+    api_key_env: VLLM_API_KEY
+  - name: gateway
+    base_url: https://llm.internal/v1
+    headers:
+      X-Team: ${GATEWAY_TEAM}
+    tools: false
+    json_mode: true
+    prompt_cache: true
+    vision: true
+`,
+		},
+		{
+			name:    "missing base_url",
+			yaml:    "llm_providers:\n  - name: vllm\n",
+			wantErr: "llm_providers.vllm: base_url is required",
+		},
+		{
+			name:    "duplicate name",
+			yaml:    "llm_providers:\n  - {name: vllm, base_url: http://a/v1}\n  - {name: vllm, base_url: http://b/v1}\n",
+			wantErr: "duplicate name",
+		},
+		{
+			name:    "invalid name",
+			yaml:    "llm_providers:\n  - {name: 'a:b', base_url: http://a/v1}\n",
+			wantErr: "invalid name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(cfg.LLMProviders) != 2 {
+				t.Fatalf("LLMProviders = %+v, want 2 providers", cfg.LLMProviders)
+			}
+			vllm, gateway := cfg.LLMProviders[0], cfg.LLMProviders[1]
+			if vllm.BaseURL != "http://gpu-box:8000/v1" || vllm.APIKeyEnv != "VLLM_API_KEY" || !vllm.SupportsTools() || vllm.JSONMode || vllm.Vision {
+				t.Errorf("vllm = %+v", vllm)
+			}
+			if gateway.Headers["X-Team"] != "platform" || gateway.SupportsTools() || !gateway.JSONMode || !gateway.PromptCache || !gateway.Vision {
+				t.Errorf("gateway = %+v", gateway)
+			}
+			if !cfg.LLM.PromptCaching {
+				t.Error("PromptCaching = false, want true")
+			}
+		})
+	}
+}
+
+func TestLoad_LLMContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    ContextConfig
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			yaml: "llm:\n  model: google:gemini-2.5-flash\n",
+			want: ContextConfig{Strategy: ContextTruncate},
+		},
+		{
+			name: "summarize with a cheap model",
+			yaml: "llm:\n  context:\n    max_tool_result_tokens: 2000\n    max_tokens: 50000\n    strategy: summarize\n    summary_model: google:gemini-2.5-flash-lite\n",
+			want: ContextConfig{MaxToolResultTokens: 2000, MaxTokens: 50000, Strategy: ContextSummarize, SummaryModel: ModelList{"google:gemini-2.5-flash-lite"}},
+		},
+		{
+			name:    "unknown strategy",
+			yaml:    "llm:\n  context:\n    strategy: forget\n",
+			wantErr: "llm.context.strategy",
+		},
+		{
+			name:    "negative budget",
+			yaml:    "llm:\n  context:\n    max_tokens: -1\n",
+			wantErr: "llm.context",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := cfg.LLM.Context
+			if got.MaxToolResultTokens != tt.want.MaxToolResultTokens || got.MaxTokens != tt.want.MaxTokens ||
+				got.Strategy != tt.want.Strategy || got.SummaryModel.String() != tt.want.SummaryModel.String() {
+				t.Errorf("Context = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_Generation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.yaml")
+	yaml := `llm:
+  generation:
+    temperature: 0.7
+    max_tokens: 2048
+agent:
+  name: review
+  type: sequential
+  agents:
+    - name: critic
+      type: llm
+      generation:
+        temperature: 0
+        stop_sequences: ["END"]
+        json: true
+    - name: brainstorm
+      type: llm
+      generation:
+        temperature: 1.0
+        top_p: 0.95
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	critic := cfg.LLM.Generation.Merge(cfg.Agent.Agents[0].Generation)
+	if critic.Temperature == nil || *critic.Temperature != 0 || critic.MaxTokens != 2048 || len(critic.StopSequences) != 1 || !critic.JSON {
+		t.Errorf("critic generation = %+v, want temperature 0, max_tokens 2048, stop END, json", critic)
+	}
+	brainstorm := cfg.LLM.Generation.Merge(cfg.Agent.Agents[1].Generation)
+	if brainstorm.Temperature == nil || *brainstorm.Temperature != 1 || brainstorm.TopP == nil || *brainstorm.TopP != 0.95 || brainstorm.StopSequences != nil || brainstorm.JSON {
+		t.Errorf("brainstorm generation = %+v, want temperature 1, top_p 0.95", brainstorm)
+	}
+
+	for _, tt := range []struct {
+		yaml    string
+		wantErr string
+	}{
+		{"llm:\n  generation:\n    temperature: 3\n", "llm.generation.temperature"},
+		{"llm:\n  generation:\n    max_tokens: -1\n", "llm.generation.max_tokens"},
+		{"agent:\n  name: n\n  type: llm\n  generation:\n    top_p: 1.5\n", "agent n: generation.top_p"},
+	} {
+		if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("error = %v, want %q", err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoad_UpstreamAuth(t *testing.T) {
+	t.Setenv("TEST_UPSTREAM_KEY", "k1")
+	t.Setenv("TEST_UPSTREAM_TOKEN", "t1")
+
+	tests := []struct {
+		name    string
+		yaml    string
+		check   func(t *testing.T, cfg *Config)
+		wantErr string
+	}{
+		{
+			name: "mcp server headers and static token",
+			yaml: "mcp_servers:\n  - name: http\n    url: http://localhost:8090/mcp\n    auth: static\n    token: ${TEST_UPSTREAM_TOKEN}\n    headers:\n      X-API-Key: ${TEST_UPSTREAM_KEY}\n",
+			check: func(t *testing.T, cfg *Config) {
+				o := cfg.MCPServers[0].Outbound()
+				if o.Mode != "static" || o.Token != "t1" || o.Headers["X-API-Key"] != "k1" {
+					t.Errorf("Outbound() = %+v", o)
+				}
+			},
+		},
+		{
+			name: "a2a agents in config and tree",
+			yaml: "a2a:\n  - name: top\n    url: http://top\n    auth: none\nagent:\n  name: root\n  type: sequential\n  agents:\n    - name: remote\n      type: a2a\n      url: http://remote\n      headers:\n        X-API-Key: ${TEST_UPSTREAM_KEY}\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.A2A[0].Auth != "none" {
+					t.Errorf("a2a[0].Auth = %q, want none", cfg.A2A[0].Auth)
+				}
+				if got := cfg.Agent.Agents[0].Headers["X-API-Key"]; got != "k1" {
+					t.Errorf("remote X-API-Key = %q, want k1", got)
+				}
+			},
+		},
+		{
+			name:    "static without token",
+			yaml:    "mcp_servers:\n  - name: http\n    url: http://localhost:8090/mcp\n    auth: static\n",
+			wantErr: "static requires a token",
+		},
+		{
+			name:    "unknown mode",
+			yaml:    "a2a:\n  - name: top\n    url: http://top\n    auth: basic\n",
+			wantErr: `a2a[0]: invalid auth "basic"`,
+		},
+		{
+			name:    "headers on stdio server",
+			yaml:    "mcp_servers:\n  - name: stdio\n    command: ./bin/mcp\n    headers:\n      X-API-Key: k\n",
+			wantErr: "only apply to HTTP servers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "agent.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoad_ToolNamespacing(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.yaml")
+	yaml := `mcp_namespace_tools: true
+mcp_servers:
+  - name: fs-dev
+    url: http://localhost:8091/mcp
+    tool_prefix: dev_
+  - name: fs-prod
+    url: http://localhost:8092/mcp
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.MCPNamespaceTools {
+		t.Error("MCPNamespaceTools = false, want true")
+	}
+	if cfg.MCPServers[0].ToolPrefix != "dev_" {
+		t.Errorf("ToolPrefix = %q, want %q", cfg.MCPServers[0].ToolPrefix, "dev_")
+	}
+}
+
+func TestLoad_Resources(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.yaml")
+	yaml := `resources:
+  - file:///top.md
+agent:
+  name: pipeline
+  type: sequential
+  agents:
+    - name: step1
+      type: llm
+      resources:
+        - file:///a.md
+        - file:///b.md
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Resources) != 1 || cfg.Resources[0] != "file:///top.md" {
+		t.Errorf("Resources = %v, want [file:///top.md]", cfg.Resources)
+	}
+	got := cfg.Agent.Agents[0].Resources
+	if len(got) != 2 || got[1] != "file:///b.md" {
+		t.Errorf("node Resources = %v, want [file:///a.md file:///b.md]", got)
+	}
+}
+
+func TestLoad_SynthesizedNodeInheritsResources(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.yaml")
+	yaml := "prompt: test\nresources:\n  - file:///top.md\n"
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Agent.Resources) != 1 || cfg.Agent.Resources[0] != "file:///top.md" {
+		t.Errorf("Agent.Resources = %v, want [file:///top.md]", cfg.Agent.Resources)
+	}
+}
+
 func TestLoad_FileNotFound(t *testing.T) {
 	_, err := Load("/nonexistent/path/agent.yaml")
 	if err == nil {
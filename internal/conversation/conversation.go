@@ -1,10 +1,13 @@
 package conversation
 
 import (
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+
+	"agent-stop-and-go/internal/mcp"
 )
 
 // Status represents the current state of a conversation.
@@ -24,6 +27,7 @@ const (
 	RoleUser      Role = "user"
 	RoleAssistant Role = "assistant"
 	RoleTool      Role = "tool"
+	RoleSummary   Role = "summary"
 )
 
 // Message represents a single message in a conversation.
@@ -32,15 +36,30 @@ type Message struct {
 	Role      Role      `json:"role"`
 	Content   string    `json:"content"`
 	ToolCall  *ToolCall `json:"tool_call,omitempty"`
+	Model     string    `json:"model,omitempty"` // assistant: model that answered, when it came from a fallback chain
 	CreatedAt time.Time `json:"created_at"`
+
+	Attachments []Attachment `json:"attachments,omitempty"` // user: files sent with the message
+}
+
+// Attachment references a file sent with a user message. The content is kept
+// in storage under ID.
+type Attachment struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
 }
 
 // ToolCall represents a tool invocation.
+// ID links a tool call record to its result message.
 type ToolCall struct {
-	Name      string         `json:"name"`
-	Arguments map[string]any `json:"arguments"`
-	Result    string         `json:"result,omitempty"`
-	IsError   bool           `json:"is_error,omitempty"`
+	ID        string             `json:"id,omitempty"`
+	Name      string             `json:"name"`
+	Arguments map[string]any     `json:"arguments"`
+	Result    string             `json:"result,omitempty"`
+	Content   []mcp.ContentBlock `json:"content,omitempty"` // full MCP result content (Result is its text rendering)
+	IsError   bool               `json:"is_error,omitempty"`
 }
 
 // PendingApproval represents a tool call waiting for external approval.
@@ -49,6 +68,7 @@ type PendingApproval struct {
 	ConversationID  string         `json:"conversation_id"`
 	ToolName        string         `json:"tool_name"`
 	ToolArgs        map[string]any `json:"tool_args"`
+	ToolCallID      string         `json:"tool_call_id,omitempty"`
 	Description     string         `json:"description"`
 	RemoteTaskID    string         `json:"remote_task_id,omitempty"`
 	RemoteAgentName string         `json:"remote_agent_name,omitempty"`
@@ -65,15 +85,16 @@ type PipelineState struct {
 
 // Conversation represents a chat session with the agent.
 type Conversation struct {
-	mu              sync.Mutex       `json:"-"`
-	ID              string           `json:"id"`
-	SessionID       string           `json:"session_id,omitempty"`
-	Status          Status           `json:"status"`
-	Messages        []Message        `json:"messages"`
-	PendingApproval *PendingApproval `json:"pending_approval,omitempty"`
-	PipelineState   *PipelineState   `json:"pipeline_state,omitempty"`
-	CreatedAt       time.Time        `json:"created_at"`
-	UpdatedAt       time.Time        `json:"updated_at"`
+	mu              sync.Mutex        `json:"-"`
+	ID              string            `json:"id"`
+	SessionID       string            `json:"session_id,omitempty"`
+	Status          Status            `json:"status"`
+	Messages        []Message         `json:"messages"`
+	PendingApproval *PendingApproval  `json:"pending_approval,omitempty"`
+	PipelineState   *PipelineState    `json:"pipeline_state,omitempty"`
+	Resources       map[string]string `json:"resources,omitempty"` // text of the MCP resources preloaded into prompts, by URI
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
 }
 
 // New creates a new conversation with a system prompt and session ID.
@@ -116,8 +137,44 @@ func (c *Conversation) AddMessage(role Role, content string) Message {
 	return msg
 }
 
+// AddUserMessage appends a user message with its attachments.
+func (c *Conversation) AddUserMessage(content string, attachments []Attachment) Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	msg := Message{
+		ID:          uuid.New().String(),
+		Role:        RoleUser,
+		Content:     content,
+		Attachments: attachments,
+		CreatedAt:   time.Now(),
+	}
+	c.Messages = append(c.Messages, msg)
+	c.UpdatedAt = time.Now()
+	return msg
+}
+
+// AddModelMessage appends an assistant message answered by model.
+// model is empty when the LLM has no fallback chain.
+func (c *Conversation) AddModelMessage(content, model string) Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	msg := Message{
+		ID:        uuid.New().String(),
+		Role:      RoleAssistant,
+		Content:   content,
+		Model:     model,
+		CreatedAt: time.Now(),
+	}
+	c.Messages = append(c.Messages, msg)
+	c.UpdatedAt = time.Now()
+	return msg
+}
+
 // AddToolCall appends a tool call message to the conversation.
-func (c *Conversation) AddToolCall(name string, args map[string]any) Message {
+// The id is the LLM-assigned tool call ID, or empty when the call did not come from an LLM.
+func (c *Conversation) AddToolCall(id, name string, args map[string]any) Message {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -125,6 +182,7 @@ func (c *Conversation) AddToolCall(name string, args map[string]any) Message {
 		ID:   uuid.New().String(),
 		Role: RoleAssistant,
 		ToolCall: &ToolCall{
+			ID:        id,
 			Name:      name,
 			Arguments: args,
 		},
@@ -136,7 +194,8 @@ func (c *Conversation) AddToolCall(name string, args map[string]any) Message {
 }
 
 // AddToolResult appends a tool result message to the conversation.
-func (c *Conversation) AddToolResult(name string, result string, isError bool) Message {
+// The id must match the one passed to AddToolCall for the same invocation.
+func (c *Conversation) AddToolResult(id, name string, result string, isError bool) Message {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -144,6 +203,7 @@ func (c *Conversation) AddToolResult(name string, result string, isError bool) M
 		ID:   uuid.New().String(),
 		Role: RoleTool,
 		ToolCall: &ToolCall{
+			ID:      id,
 			Name:    name,
 			Result:  result,
 			IsError: isError,
@@ -155,6 +215,72 @@ func (c *Conversation) AddToolResult(name string, result string, isError bool) M
 	return msg
 }
 
+// AddToolResultContent appends an MCP tool result, keeping every content block.
+// The text rendering of the blocks is stored in Result.
+func (c *Conversation) AddToolResultContent(id, name string, result *mcp.CallToolResult) Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	msg := Message{
+		ID:   uuid.New().String(),
+		Role: RoleTool,
+		ToolCall: &ToolCall{
+			ID:      id,
+			Name:    name,
+			Result:  result.AsText(),
+			Content: result.Content,
+			IsError: result.IsError,
+		},
+		CreatedAt: time.Now(),
+	}
+	c.Messages = append(c.Messages, msg)
+	c.UpdatedAt = time.Now()
+	return msg
+}
+
+// AddSummary inserts a summary of the messages preceding the message with
+// the given ID, right before it. The LLM only sees the history from the
+// latest summary on. The summary is appended when no message has that ID.
+func (c *Conversation) AddSummary(content, beforeID string) Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	msg := Message{
+		ID:        uuid.New().String(),
+		Role:      RoleSummary,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}
+	i := len(c.Messages)
+	for j, m := range c.Messages {
+		if m.ID == beforeID {
+			i = j
+			break
+		}
+	}
+	c.Messages = slices.Insert(c.Messages, i, msg)
+	c.UpdatedAt = time.Now()
+	return msg
+}
+
+// Resource returns the cached text of a preloaded MCP resource.
+func (c *Conversation) Resource(uri string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	text, ok := c.Resources[uri]
+	return text, ok
+}
+
+// SetResource caches the text of a preloaded MCP resource.
+func (c *Conversation) SetResource(uri, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Resources == nil {
+		c.Resources = make(map[string]string)
+	}
+	c.Resources[uri] = text
+}
+
 // SetWaitingApproval marks the conversation as waiting for tool approval.
 func (c *Conversation) SetWaitingApproval(toolName string, toolArgs map[string]any, description string) *PendingApproval {
 	approval := &PendingApproval{
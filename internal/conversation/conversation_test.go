@@ -1,7 +1,10 @@
 package conversation
 
 import (
+	"strings"
 	"testing"
+
+	"agent-stop-and-go/internal/mcp"
 )
 
 func TestNew(t *testing.T) {
@@ -50,14 +53,33 @@ func TestAddMessage(t *testing.T) {
 	}
 }
 
+func TestAddUserMessage(t *testing.T) {
+	conv := New("", "")
+	attachments := []Attachment{{ID: "f1", Name: "screen.png", MimeType: "image/png", Size: 5}}
+	msg := conv.AddUserMessage("what is this?", attachments)
+
+	if msg.Role != RoleUser || msg.Content != "what is this?" {
+		t.Errorf("msg = %+v, want user message", msg)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].ID != "f1" {
+		t.Errorf("Attachments = %+v, want [f1]", msg.Attachments)
+	}
+	if len(conv.Messages) != 1 {
+		t.Errorf("Messages count = %d, want 1", len(conv.Messages))
+	}
+}
+
 func TestAddToolCall(t *testing.T) {
 	conv := New("", "")
 	args := map[string]any{"name": "test"}
-	msg := conv.AddToolCall("resources_add", args)
+	msg := conv.AddToolCall("call_1", "resources_add", args)
 
 	if msg.ToolCall == nil {
 		t.Fatal("expected ToolCall to be set")
 	}
+	if msg.ToolCall.ID != "call_1" {
+		t.Errorf("ToolCall.ID = %q, want %q", msg.ToolCall.ID, "call_1")
+	}
 	if msg.ToolCall.Name != "resources_add" {
 		t.Errorf("ToolCall.Name = %q, want %q", msg.ToolCall.Name, "resources_add")
 	}
@@ -68,11 +90,14 @@ func TestAddToolCall(t *testing.T) {
 
 func TestAddToolResult(t *testing.T) {
 	conv := New("", "")
-	msg := conv.AddToolResult("resources_list", "result data", false)
+	msg := conv.AddToolResult("call_1", "resources_list", "result data", false)
 
 	if msg.ToolCall == nil {
 		t.Fatal("expected ToolCall to be set")
 	}
+	if msg.ToolCall.ID != "call_1" {
+		t.Errorf("ToolCall.ID = %q, want %q", msg.ToolCall.ID, "call_1")
+	}
 	if msg.ToolCall.Result != "result data" {
 		t.Errorf("ToolCall.Result = %q, want %q", msg.ToolCall.Result, "result data")
 	}
@@ -84,6 +109,30 @@ func TestAddToolResult(t *testing.T) {
 	}
 }
 
+func TestAddSummary(t *testing.T) {
+	conv := New("prompt", "")
+	conv.AddMessage(RoleUser, "first")
+	second := conv.AddMessage(RoleUser, "second")
+
+	msg := conv.AddSummary("the user said first", second.ID)
+	if msg.Role != RoleSummary {
+		t.Errorf("Role = %q, want %q", msg.Role, RoleSummary)
+	}
+	var got []string
+	for _, m := range conv.Messages {
+		got = append(got, m.Content)
+	}
+	want := []string{"prompt", "first", "the user said first", "second"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Messages = %q, want %q", got, want)
+	}
+
+	conv.AddSummary("everything", "missing")
+	if last := conv.Messages[len(conv.Messages)-1]; last.Content != "everything" {
+		t.Errorf("last message = %q, want the summary appended", last.Content)
+	}
+}
+
 func TestSetWaitingApprovalAndResolve(t *testing.T) {
 	conv := New("", "")
 	args := map[string]any{"name": "test"}
@@ -120,3 +169,25 @@ func TestComplete(t *testing.T) {
 		t.Errorf("Status = %q, want %q", conv.Status, StatusCompleted)
 	}
 }
+
+func TestAddToolResultContent(t *testing.T) {
+	conv := New("", "")
+	msg := conv.AddToolResultContent("call_1", "read_file", &mcp.CallToolResult{
+		Content: []mcp.ContentBlock{
+			{Type: "text", Text: "line 1"},
+			{Type: "text", Text: "line 2"},
+			{Type: "image", Data: "aGVsbG8=", MimeType: "image/png"},
+		},
+	})
+
+	if msg.ToolCall == nil {
+		t.Fatal("expected ToolCall to be set")
+	}
+	if len(msg.ToolCall.Content) != 3 {
+		t.Errorf("Content blocks = %d, want 3", len(msg.ToolCall.Content))
+	}
+	want := "line 1\nline 2\n[image: image/png, 5 bytes]"
+	if msg.ToolCall.Result != want {
+		t.Errorf("ToolCall.Result = %q, want %q", msg.ToolCall.Result, want)
+	}
+}
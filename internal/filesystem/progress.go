@@ -0,0 +1,58 @@
+package filesystem
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// progressInterval is the minimum delay between two progress notifications.
+const progressInterval = 500 * time.Millisecond
+
+// progressReporter sends notifications/progress for a tool call whose
+// request carries a progress token. A nil reporter does nothing.
+type progressReporter struct {
+	ctx   context.Context
+	srv   *server.MCPServer
+	token mcp.ProgressToken
+	last  time.Time
+}
+
+// newProgressReporter returns a reporter for req, or nil if the client did not ask for progress.
+func newProgressReporter(ctx context.Context, req mcp.CallToolRequest) *progressReporter {
+	if req.Params.Meta == nil || req.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+	return &progressReporter{ctx: ctx, srv: srv, token: req.Params.Meta.ProgressToken}
+}
+
+// report sends the progress so far, at most once per progressInterval.
+// total is 0 when unknown. The final update (progress == total) is always sent.
+func (p *progressReporter) report(progress, total int, message string) {
+	if p == nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(p.last) < progressInterval && (total == 0 || progress < total) {
+		return
+	}
+	p.last = now
+
+	params := map[string]any{"progressToken": p.token, "progress": progress}
+	if total > 0 {
+		params["total"] = total
+	}
+	if message != "" {
+		params["message"] = message
+	}
+	if err := p.srv.SendNotificationToClient(p.ctx, "notifications/progress", params); err != nil {
+		slog.Debug("progress notification failed", "error", err)
+	}
+}
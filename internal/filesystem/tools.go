@@ -747,7 +747,7 @@ func (s *Server) PermissionsFile() server.ToolHandlerFunc {
 
 // Copy copies files/directories within or across roots.
 func (s *Server) Copy() server.ToolHandlerFunc {
-	return func(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		start := time.Now()
 
 		srcRootName, err := reqString(req, "source_root")
@@ -815,7 +815,15 @@ func (s *Server) Copy() server.ToolHandlerFunc {
 		}
 
 		if srcInfo.IsDir() {
-			if err := copyDir(srcResolved, destResolved, srcRoot, destRoot); err != nil {
+			var onFile func()
+			if progress := newProgressReporter(ctx, req); progress != nil {
+				total, copied := countFiles(srcResolved, srcRoot), 0
+				onFile = func() {
+					copied++
+					progress.report(copied, total, fmt.Sprintf("%d of %d files copied", copied, total))
+				}
+			}
+			if err := copyDir(srcResolved, destResolved, srcRoot, destRoot, onFile); err != nil {
 				logTool("copy", srcRootName, srcPathStr, time.Since(start), err)
 				return mcp.NewToolResultError(fmt.Sprintf("copy directory: %v", err)), nil
 			}
@@ -920,7 +928,7 @@ func (s *Server) Move() server.ToolHandlerFunc {
 				return mcp.NewToolResultError(fmt.Sprintf("stat source: %v", sErr)), nil
 			}
 			if srcInfo.IsDir() {
-				if cErr := copyDir(srcResolved, destResolved, srcRoot, destRoot); cErr != nil {
+				if cErr := copyDir(srcResolved, destResolved, srcRoot, destRoot, nil); cErr != nil {
 					logTool("move", srcRootName, srcPathStr, time.Since(start), cErr)
 					return mcp.NewToolResultError(fmt.Sprintf("copy for move: %v", cErr)), nil
 				}
@@ -1007,6 +1015,8 @@ func (s *Server) Grep() server.ToolHandlerFunc {
 		var matches []grepMatch
 		truncated := false
 		timedOut := false
+		scanned := 0
+		progress := newProgressReporter(ctx, req)
 
 		var globMatcher func(string) bool
 		if globFilter != "" {
@@ -1053,6 +1063,9 @@ func (s *Server) Grep() server.ToolHandlerFunc {
 				return nil
 			}
 
+			scanned++
+			progress.report(scanned, 0, fmt.Sprintf("%d files scanned, %d matches", scanned, len(matches)))
+
 			// Skip binary files
 			f, fErr := os.Open(path)
 			if fErr != nil {
@@ -1131,7 +1144,7 @@ func (s *Server) Grep() server.ToolHandlerFunc {
 
 // Glob searches for files by name pattern.
 func (s *Server) Glob() server.ToolHandlerFunc {
-	return func(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		start := time.Now()
 
 		rootName, err := reqString(req, "root")
@@ -1191,6 +1204,8 @@ func (s *Server) Glob() server.ToolHandlerFunc {
 		var matches []globMatch
 		truncated := false
 		timedOut := false
+		visited := 0
+		progress := newProgressReporter(ctx, req)
 
 		err = filepath.WalkDir(searchResolved, func(path string, d fs.DirEntry, walkErr error) error {
 			if walkErr != nil {
@@ -1212,6 +1227,9 @@ func (s *Server) Glob() server.ToolHandlerFunc {
 				return nil
 			}
 
+			visited++
+			progress.report(visited, 0, fmt.Sprintf("%d entries visited, %d matches", visited, len(matches)))
+
 			// Validate within root
 			if !isWithinRoot(path, root.RealPath) {
 				return nil
@@ -1365,8 +1383,8 @@ func copyFile(src, dst string) (int64, error) {
 	return n, nil
 }
 
-// copyDir copies a directory recursively.
-func copyDir(src, dst string, srcRoot, dstRoot *ResolvedRoot) error {
+// copyDir copies a directory recursively, calling onFile (if not nil) after each file.
+func copyDir(src, dst string, srcRoot, dstRoot *ResolvedRoot, onFile func()) error {
 	srcInfo, err := os.Stat(src)
 	if err != nil {
 		return err
@@ -1391,19 +1409,34 @@ func copyDir(src, dst string, srcRoot, dstRoot *ResolvedRoot) error {
 		}
 
 		if e.IsDir() {
-			if err := copyDir(srcPath, dstPath, srcRoot, dstRoot); err != nil {
+			if err := copyDir(srcPath, dstPath, srcRoot, dstRoot, onFile); err != nil {
 				return err
 			}
 		} else {
 			if _, err := copyFile(srcPath, dstPath); err != nil {
 				return err
 			}
+			if onFile != nil {
+				onFile()
+			}
 		}
 	}
 
 	return nil
 }
 
+// countFiles returns the number of files copyDir copies from dir.
+func countFiles(dir string, root *ResolvedRoot) int {
+	n := 0
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && isWithinRoot(path, root.RealPath) {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
 func formatSize(bytes int64) string {
 	const (
 		kb = 1024
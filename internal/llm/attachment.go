@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Attachment is a file sent with a user message: a screenshot, a PDF, a
+// source file...
+type Attachment struct {
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime_type"`
+	Data     string `json:"data"` // base64-encoded
+}
+
+// Attachment kinds, by how the providers accept them.
+const (
+	attachmentImage = "image" // native image part (vision models)
+	attachmentPDF   = "pdf"   // native document part
+	attachmentText  = "text"  // inlined as text
+)
+
+// textMimeTypes are the non-text/* types inlined as text.
+var textMimeTypes = []string{
+	"application/json", "application/xml", "application/yaml", "application/x-yaml",
+	"application/javascript", "application/x-sh", "application/sql", "application/toml",
+}
+
+// kind returns how the attachment is sent, or "" when no provider accepts it.
+func (a Attachment) kind() string {
+	mediaType, _, _ := mime.ParseMediaType(a.MimeType)
+	switch {
+	case mediaType == "image/jpeg", mediaType == "image/png", mediaType == "image/gif", mediaType == "image/webp":
+		return attachmentImage
+	case mediaType == "application/pdf":
+		return attachmentPDF
+	case strings.HasPrefix(mediaType, "text/"), slices.Contains(textMimeTypes, mediaType):
+		return attachmentText
+	}
+	return ""
+}
+
+// mediaType returns the MIME type without parameters.
+func (a Attachment) mediaType() string {
+	mediaType, _, err := mime.ParseMediaType(a.MimeType)
+	if err != nil {
+		return a.MimeType
+	}
+	return mediaType
+}
+
+// label names the attachment in text parts.
+func (a Attachment) label() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return "(unnamed)"
+}
+
+// text returns the content of a text attachment, headed by its name.
+func (a Attachment) text() string {
+	data, err := base64.StdEncoding.DecodeString(a.Data)
+	if err != nil || !utf8.Valid(data) {
+		return a.placeholder("not valid UTF-8 text")
+	}
+	return fmt.Sprintf("File %s:\n%s", a.label(), data)
+}
+
+// placeholder stands for an attachment the model does not receive.
+func (a Attachment) placeholder(reason string) string {
+	return fmt.Sprintf("[Attachment %s (%s) not sent: %s]", a.label(), a.MimeType, reason)
+}
+
+// dataURL returns the attachment as a data: URL.
+func (a Attachment) dataURL() string {
+	return "data:" + a.mediaType() + ";base64," + a.Data
+}
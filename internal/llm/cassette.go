@@ -0,0 +1,253 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"agent-stop-and-go/internal/mcp"
+)
+
+// cassetteEnv is the environment variable holding the cassette file of the
+// record and replay providers.
+const cassetteEnv = "LLM_CASSETTE"
+
+// uuidPattern matches the UUIDs (approvals, conversations...) that differ
+// from one run to the next and are left out of request keys.
+var uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// interaction is a recorded request and its response.
+type interaction struct {
+	Key          string    `json:"key"`
+	Model        string    `json:"model"`
+	SystemPrompt string    `json:"system_prompt,omitempty"`
+	Messages     []Message `json:"messages"`
+	Tools        []string  `json:"tools,omitempty"` // names only, the key covers the full definitions
+	Response     *Response `json:"response"`
+}
+
+// cassette is the file of the interactions recorded or replayed by the
+// clients of a process. Clients of the same file share it.
+type cassette struct {
+	mu           sync.Mutex
+	path         string
+	recording    bool
+	Interactions []interaction  `json:"interactions"`
+	served       map[string]int // replay: key -> responses served
+}
+
+// cassettes holds the cassettes opened by the process, by path.
+var cassettes = struct {
+	mu sync.Mutex
+	m  map[string]*cassette
+}{m: make(map[string]*cassette)}
+
+// openCassette returns the cassette at path. A cassette opened for recording
+// starts empty and replaces the file; one opened for replay is read from it.
+func openCassette(path string, recording bool) (*cassette, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%s environment variable not set", cassetteEnv)
+	}
+
+	cassettes.mu.Lock()
+	defer cassettes.mu.Unlock()
+
+	if c, ok := cassettes.m[path]; ok {
+		if c.recording != recording {
+			return nil, fmt.Errorf("cassette %s is used for both recording and replay", path)
+		}
+		return c, nil
+	}
+
+	c := &cassette{path: path, recording: recording}
+	if !recording {
+		var err error
+		if c, err = loadCassette(path); err != nil {
+			return nil, err
+		}
+	}
+	cassettes.m[path] = c
+	return c, nil
+}
+
+// loadCassette reads a cassette file for replay.
+func loadCassette(path string) (*cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	c := &cassette{path: path}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// record appends an interaction and rewrites the file.
+func (c *cassette) record(it interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Interactions = append(c.Interactions, it)
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// replay returns the next recorded response for key. Identical requests get
+// their recorded responses in order, then the last one again.
+func (c *cassette) replay(key string) (*Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matches []*Response
+	for _, it := range c.Interactions {
+		if it.Key == key {
+			matches = append(matches, it.Response)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	if c.served == nil {
+		c.served = make(map[string]int)
+	}
+	i := min(c.served[key], len(matches)-1)
+	c.served[key]++
+
+	resp := *matches[i]
+	return &resp, true
+}
+
+// requestKey hashes the system prompt, messages and tools of a request.
+// Tool call IDs and UUIDs are left out: they change on every run. Tools are
+// sorted by name: their order may vary between runs.
+func requestKey(systemPrompt string, messages []Message, tools []mcp.Tool) string {
+	tools = slices.Clone(tools)
+	slices.SortStableFunc(tools, func(a, b mcp.Tool) int { return strings.Compare(a.Name, b.Name) })
+
+	normalized := make([]Message, len(messages))
+	for i, m := range messages {
+		m.Content = uuidPattern.ReplaceAllString(m.Content, "<uuid>")
+		if len(m.ToolCalls) > 0 {
+			calls := make([]ToolCall, len(m.ToolCalls))
+			for j, tc := range m.ToolCalls {
+				tc.ID = ""
+				calls[j] = tc
+			}
+			m.ToolCalls = calls
+		}
+		if m.ToolResult != nil {
+			r := *m.ToolResult
+			r.ToolCallID = ""
+			r.Content = uuidPattern.ReplaceAllString(r.Content, "<uuid>")
+			r.Blocks = nil
+			m.ToolResult = &r
+		}
+		normalized[i] = m
+	}
+
+	data, _ := json.Marshal(struct {
+		SystemPrompt string     `json:"system_prompt"`
+		Messages     []Message  `json:"messages"`
+		Tools        []mcp.Tool `json:"tools"`
+	}{uuidPattern.ReplaceAllString(systemPrompt, "<uuid>"), normalized, tools})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordClient sends requests to a model and records them with their
+// responses in a cassette, for ReplayClient.
+type RecordClient struct {
+	model    string
+	client   Client
+	cassette *cassette
+}
+
+// NewRecordClient creates a client recording the requests of model
+// ("provider:model") in the cassette file named by LLM_CASSETTE.
+// The file is replaced: all the record clients of a process share it.
+func NewRecordClient(model string) (*RecordClient, error) {
+	client, err := NewClient(model)
+	if err != nil {
+		return nil, err
+	}
+	c, err := openCassette(os.Getenv(cassetteEnv), true)
+	if err != nil {
+		return nil, fmt.Errorf("record: %w", err)
+	}
+	return &RecordClient{model: model, client: client, cassette: c}, nil
+}
+
+// GenerateWithTools sends the request and records it with its response.
+// Failed requests are not recorded.
+func (c *RecordClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error) {
+	resp, err := c.client.GenerateWithTools(ctx, systemPrompt, messages, tools, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.Name
+	}
+	err = c.cassette.record(interaction{
+		Key:          requestKey(systemPrompt, messages, tools),
+		Model:        c.model,
+		SystemPrompt: systemPrompt,
+		Messages:     messages,
+		Tools:        names,
+		Response:     resp,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("record: %w", err)
+	}
+	return resp, nil
+}
+
+// ReplayClient answers requests with the responses recorded in a cassette,
+// without calling any provider.
+type ReplayClient struct {
+	cassette *cassette
+}
+
+// NewReplayClient creates a client replaying the cassette file named by LLM_CASSETTE.
+func NewReplayClient() (*ReplayClient, error) {
+	c, err := openCassette(os.Getenv(cassetteEnv), false)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	return &ReplayClient{cassette: c}, nil
+}
+
+// GenerateWithTools returns the recorded response to the request, or an
+// error if the request was not recorded.
+func (c *ReplayClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error) {
+	key := requestKey(systemPrompt, messages, tools)
+	resp, ok := c.cassette.replay(key)
+	if !ok {
+		last := ""
+		if len(messages) > 0 {
+			last = messages[len(messages)-1].Content
+		}
+		return nil, fmt.Errorf("replay: request %s not recorded in %s (last message %q): record it again with the record: provider", key[:12], c.cassette.path, last)
+	}
+	return resp, nil
+}
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-stop-and-go/internal/mcp"
+)
+
+// scriptedClient answers each request with the next of its responses.
+type scriptedClient struct {
+	responses []*Response
+	calls     int
+}
+
+func (c *scriptedClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error) {
+	if c.calls >= len(c.responses) {
+		return nil, fmt.Errorf("unexpected request %d", c.calls+1)
+	}
+	c.calls++
+	return c.responses[c.calls-1], nil
+}
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "pipeline.json")
+	tools := []mcp.Tool{{Name: "resources_list", Description: "List resources"}}
+	first := []Message{{Role: "user", Content: "list resources"}}
+	withResult := func(callID, uuid string) []Message {
+		return append(first,
+			Message{Role: "model", ToolCalls: []ToolCall{{ID: callID, Name: "resources_list", Arguments: map[string]any{}}}},
+			Message{Role: "tool", ToolResult: &ToolResult{ToolCallID: callID, Name: "resources_list", Content: "resource " + uuid}},
+		)
+	}
+
+	// Record a two-step pipeline
+	inner := &scriptedClient{responses: []*Response{
+		{ToolCall: &ToolCall{ID: "call_1", Name: "resources_list", Arguments: map[string]any{}}},
+		{Text: "There is one resource."},
+	}}
+	rec, err := openCassette(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &RecordClient{model: "fake:model", client: inner, cassette: rec}
+	ctx := context.Background()
+	if _, err := recorder.GenerateWithTools(ctx, "prompt", first, tools, RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.GenerateWithTools(ctx, "prompt", withResult("call_1", "0b8e5c2a-4f3e-4d8a-9c1b-2a3b4c5d6e7f"), tools, RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay from the file: tool call IDs and UUIDs may differ
+	c, err := loadCassette(path)
+	if err != nil {
+		t.Fatalf("loadCassette() error: %v", err)
+	}
+	if len(c.Interactions) != 2 || c.Interactions[0].Model != "fake:model" {
+		t.Fatalf("cassette = %+v, want 2 interactions of fake:model", c.Interactions)
+	}
+	replayer := &ReplayClient{cassette: c}
+
+	tests := []struct {
+		name     string
+		prompt   string
+		messages []Message
+		tools    []mcp.Tool
+		wantText string
+		wantTool string
+		wantErr  bool
+	}{
+		{"first request", "prompt", first, tools, "", "resources_list", false},
+		{"other IDs and UUID", "prompt", withResult("call_xyz", "11111111-2222-3333-4444-555555555555"), tools, "There is one resource.", "", false},
+		{"repeated request", "prompt", first, tools, "", "resources_list", false},
+		{"other prompt", "other prompt", first, tools, "", "", true},
+		{"other message", "prompt", []Message{{Role: "user", Content: "delete resources"}}, tools, "", "", true},
+		{"other tools", "prompt", first, nil, "", "", true},
+		{"other tool result", "prompt", append(withResult("call_1", "")[:2], Message{Role: "tool", ToolResult: &ToolResult{Name: "resources_list", Content: "none"}}), tools, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := replayer.GenerateWithTools(ctx, tt.prompt, tt.messages, tt.tools, RequestOptions{})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "not recorded") {
+					t.Fatalf("error = %v, want not recorded error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", resp.Text, tt.wantText)
+			}
+			gotTool := ""
+			if resp.ToolCall != nil {
+				gotTool = resp.ToolCall.Name
+			}
+			if gotTool != tt.wantTool {
+				t.Errorf("ToolCall = %q, want %q", gotTool, tt.wantTool)
+			}
+		})
+	}
+}
+
+func TestReplay_RepeatedRequests(t *testing.T) {
+	key := requestKey("", []Message{{Role: "user", Content: "next"}}, nil)
+	c := &cassette{Interactions: []interaction{
+		{Key: key, Response: &Response{Text: "one"}},
+		{Key: "other", Response: &Response{Text: "other"}},
+		{Key: key, Response: &Response{Text: "two"}},
+	}}
+
+	for _, want := range []string{"one", "two", "two"} {
+		resp, ok := c.replay(key)
+		if !ok || resp.Text != want {
+			t.Errorf("replay() = %+v, %v, want %q", resp, ok, want)
+		}
+	}
+}
+
+func TestRequestKey_ToolOrder(t *testing.T) {
+	messages := []Message{{Role: "user", Content: "list"}}
+	list := mcp.Tool{Name: "resources_list", Description: "List resources"}
+	add := mcp.Tool{Name: "resources_add", Description: "Add a resource"}
+	delegate := mcp.Tool{Name: "a2a_helper", Description: "Delegate task"}
+
+	key := requestKey("", messages, []mcp.Tool{list, add, delegate})
+	if got := requestKey("", messages, []mcp.Tool{delegate, list, add}); got != key {
+		t.Errorf("key depends on the tool order: %s != %s", got, key)
+	}
+	if got := requestKey("", messages, []mcp.Tool{list, add}); got == key {
+		t.Error("key does not depend on the tools")
+	}
+}
+
+func TestNewClient_RecordReplay(t *testing.T) {
+	t.Setenv(cassetteEnv, "")
+	if _, err := NewClient("replay:google:gemini-2.5-flash"); err == nil || !strings.Contains(err.Error(), cassetteEnv) {
+		t.Errorf("error = %v, want %s not set", err, cassetteEnv)
+	}
+
+	t.Setenv(cassetteEnv, filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := NewClient("replay:google:gemini-2.5-flash"); err == nil || !strings.Contains(err.Error(), "failed to read cassette") {
+		t.Errorf("error = %v, want missing cassette error", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "llm.json")
+	t.Setenv(cassetteEnv, path)
+	t.Setenv("OPENAI_API_KEY", "test")
+	client, err := NewClient("record:openai:gpt-4o")
+	if err != nil {
+		t.Fatalf("NewClient(record) error: %v", err)
+	}
+	if _, ok := client.(*RecordClient); !ok {
+		t.Errorf("NewClient(record) = %T, want *RecordClient", client)
+	}
+	if _, err := NewClient("replay:openai:gpt-4o"); err == nil || !strings.Contains(err.Error(), "both recording and replay") {
+		t.Errorf("error = %v, want recording and replay conflict", err)
+	}
+}
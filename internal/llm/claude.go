@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 
@@ -13,15 +12,18 @@ import (
 )
 
 const (
-	claudeBaseURL   = "https://api.anthropic.com/v1/messages"
-	claudeMaxTokens = 4096
+	claudeBaseURL        = "https://api.anthropic.com/v1/messages"
+	claudeMaxTokens      = 4096
+	claudeMaxTemperature = 1.0 // other providers accept up to 2
 )
 
 // ClaudeClient handles communication with the Anthropic Messages API.
 type ClaudeClient struct {
-	model  string
-	apiKey string
-	client *http.Client
+	model   string
+	apiKey  string
+	baseURL string
+	client  *http.Client
+	retry   retryPolicy
 }
 
 // NewClaudeClient creates a new Claude client.
@@ -32,42 +34,43 @@ func NewClaudeClient(model string) (*ClaudeClient, error) {
 	}
 
 	return &ClaudeClient{
-		model:  model,
-		apiKey: apiKey,
-		client: &http.Client{Timeout: httpClientTimeout},
+		model:   model,
+		apiKey:  apiKey,
+		baseURL: claudeBaseURL,
+		client:  &http.Client{Timeout: httpClientTimeout},
+		retry:   defaultRetryPolicy,
 	}, nil
 }
 
 // Claude API request/response types
 
 type claudeRequest struct {
-	Model     string          `json:"model"`
-	MaxTokens int             `json:"max_tokens"`
-	System    string          `json:"system,omitempty"`
-	Messages  []claudeMessage `json:"messages"`
-	Tools     []claudeTool    `json:"tools,omitempty"`
+	Model         string          `json:"model"`
+	MaxTokens     int             `json:"max_tokens"`
+	System        any             `json:"system,omitempty"` // string, or []claudeContentBlock with a cache breakpoint
+	Messages      []claudeMessage `json:"messages"`
+	Tools         []claudeTool    `json:"tools,omitempty"`
+	Temperature   *float64        `json:"temperature,omitempty"`
+	TopP          *float64        `json:"top_p,omitempty"`
+	StopSequences []string        `json:"stop_sequences,omitempty"`
 }
 
 type claudeMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string               `json:"role"`
+	Content []claudeContentBlock `json:"content"`
 }
 
 type claudeTool struct {
-	Name        string          `json:"name"`
-	Description string          `json:"description,omitempty"`
-	InputSchema json.RawMessage `json:"input_schema"`
+	Name         string              `json:"name"`
+	Description  string              `json:"description,omitempty"`
+	InputSchema  json.RawMessage     `json:"input_schema"`
+	CacheControl *claudeCacheControl `json:"cache_control,omitempty"`
 }
 
-type claudeInputSchema struct {
-	Type       string                    `json:"type"`
-	Properties map[string]claudeProperty `json:"properties,omitempty"`
-	Required   []string                  `json:"required,omitempty"`
-}
-
-type claudeProperty struct {
-	Type        string `json:"type"`
-	Description string `json:"description,omitempty"`
+// claudeCacheControl marks a prompt caching breakpoint: the request prefix up
+// to and including the marked block (tools, then system, then messages) is cached.
+type claudeCacheControl struct {
+	Type string `json:"type"` // "ephemeral"
 }
 
 type claudeResponse struct {
@@ -76,15 +79,35 @@ type claudeResponse struct {
 	Role       string               `json:"role"`
 	Content    []claudeContentBlock `json:"content"`
 	StopReason string               `json:"stop_reason"`
+	Usage      *claudeUsage         `json:"usage,omitempty"`
 	Error      *claudeError         `json:"error,omitempty"`
 }
 
+type claudeUsage struct {
+	InputTokens              int `json:"input_tokens"` // uncached input only
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
 type claudeContentBlock struct {
-	Type  string          `json:"type"`
-	Text  string          `json:"text,omitempty"`
-	ID    string          `json:"id,omitempty"`
-	Name  string          `json:"name,omitempty"`
-	Input json.RawMessage `json:"input,omitempty"`
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	Source    *claudeSource   `json:"source,omitempty"`      // image only
+	ToolUseID string          `json:"tool_use_id,omitempty"` // tool_result only
+	Content   any             `json:"content,omitempty"`     // tool_result only: string or []claudeContentBlock
+	IsError   bool            `json:"is_error,omitempty"`    // tool_result only
+
+	CacheControl *claudeCacheControl `json:"cache_control,omitempty"`
+}
+
+type claudeSource struct {
+	Type      string `json:"type"` // "base64"
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
 }
 
 type claudeError struct {
@@ -93,27 +116,16 @@ type claudeError struct {
 }
 
 // GenerateWithTools sends a request to Claude with tool use support.
-func (c *ClaudeClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool) (*Response, error) {
+func (c *ClaudeClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error) {
+	if opts.Temperature != nil && *opts.Temperature > claudeMaxTemperature {
+		return nil, fmt.Errorf("temperature %g is above the Anthropic maximum of %g", *opts.Temperature, claudeMaxTemperature)
+	}
+
 	// Convert MCP tools to Claude tool format
 	claudeTools := make([]claudeTool, 0, len(tools))
 	for _, tool := range tools {
-		schema := claudeInputSchema{
-			Type:       "object",
-			Properties: make(map[string]claudeProperty),
-			Required:   tool.InputSchema.Required,
-		}
-
-		for propName, propSchema := range tool.InputSchema.Properties {
-			prop := claudeProperty{
-				Type: propSchema.Type,
-			}
-			if propSchema.Description != "" {
-				prop.Description = propSchema.Description
-			}
-			schema.Properties[propName] = prop
-		}
-
-		schemaJSON, err := json.Marshal(schema)
+		// Claude accepts JSON Schema as-is
+		schemaJSON, err := json.Marshal(tool.InputSchema.ObjectSchema())
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal tool schema: %w", err)
 		}
@@ -125,12 +137,26 @@ func (c *ClaudeClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 		})
 	}
 
+	// Convert messages
+	claudeMessages, err := toClaudeMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build request
 	req := claudeRequest{
-		Model:     c.model,
-		MaxTokens: claudeMaxTokens,
-		System:    systemPrompt,
-		Messages:  make([]claudeMessage, 0, len(messages)),
+		Model:         c.model,
+		MaxTokens:     claudeMaxTokens,
+		Messages:      claudeMessages,
+		Temperature:   opts.Temperature,
+		TopP:          opts.TopP,
+		StopSequences: opts.StopSequences,
+	}
+	if opts.MaxTokens > 0 {
+		req.MaxTokens = opts.MaxTokens
+	}
+	if systemPrompt != "" {
+		req.System = systemPrompt
 	}
 
 	// Add tools if any
@@ -138,13 +164,16 @@ func (c *ClaudeClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 		req.Tools = claudeTools
 	}
 
-	// Convert messages
-	for _, msg := range messages {
-		role := ToClaudeRole(msg.Role)
-		req.Messages = append(req.Messages, claudeMessage{
-			Role:    role,
-			Content: msg.Content,
-		})
+	// Cache breakpoints on the tool list and the system prompt, which pipeline
+	// nodes resend unchanged on every call
+	if opts.CachePrompt {
+		ephemeral := &claudeCacheControl{Type: "ephemeral"}
+		if len(req.Tools) > 0 {
+			req.Tools[len(req.Tools)-1].CacheControl = ephemeral
+		}
+		if systemPrompt != "" {
+			req.System = []claudeContentBlock{{Type: "text", Text: systemPrompt, CacheControl: ephemeral}}
+		}
 	}
 
 	// Make API request
@@ -153,7 +182,7 @@ func (c *ClaudeClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", claudeBaseURL, bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -161,28 +190,34 @@ func (c *ClaudeClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 	httpReq.Header.Set("x-api-key", c.apiKey)
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
 
-	httpResp, err := c.client.Do(httpReq)
+	// Send request, retried on rate limits and server errors
+	httpResp, respBody, err := c.retry.do(c.client, httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer httpResp.Body.Close()
-
-	respBody, err := io.ReadAll(httpResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
 	var claudeResp claudeResponse
 	if err := json.Unmarshal(respBody, &claudeResp); err != nil {
+		if httpResp.StatusCode >= 300 {
+			return nil, newAPIError("Claude", httpResp.StatusCode, "")
+		}
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if claudeResp.Error != nil {
-		return nil, fmt.Errorf("Claude API error: %s", claudeResp.Error.Message)
+		return nil, newAPIError("Claude", httpResp.StatusCode, claudeResp.Error.Message)
 	}
 
 	// Parse response
 	response := &Response{}
+	if u := claudeResp.Usage; u != nil {
+		response.Usage = &Usage{
+			InputTokens:      u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens,
+			OutputTokens:     u.OutputTokens,
+			CacheReadTokens:  u.CacheReadInputTokens,
+			CacheWriteTokens: u.CacheCreationInputTokens,
+		}
+	}
 
 	for _, block := range claudeResp.Content {
 		if block.Type == "tool_use" {
@@ -191,6 +226,7 @@ func (c *ClaudeClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 				return nil, fmt.Errorf("failed to parse tool arguments: %w", err)
 			}
 			response.ToolCall = &ToolCall{
+				ID:        block.ID,
 				Name:      block.Name,
 				Arguments: args,
 			}
@@ -206,3 +242,102 @@ func (c *ClaudeClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 
 	return response, nil
 }
+
+// toClaudeMessages converts messages to Claude content blocks.
+// Tool calls become tool_use blocks on assistant turns and tool results become
+// tool_result blocks on user turns. Consecutive same-role messages are merged
+// because Claude requires alternating user/assistant turns.
+func toClaudeMessages(messages []Message) ([]claudeMessage, error) {
+	result := make([]claudeMessage, 0, len(messages))
+	for _, msg := range messages {
+		role := ToClaudeRole(msg.Role)
+		var blocks []claudeContentBlock
+
+		if msg.ToolResult != nil {
+			role = "user"
+			blocks = append(blocks, claudeContentBlock{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolResult.ToolCallID,
+				Content:   claudeToolResultContent(msg.ToolResult),
+				IsError:   msg.ToolResult.IsError,
+			})
+		}
+		blocks = append(blocks, claudeAttachmentBlocks(msg.Attachments)...)
+		if msg.Content != "" {
+			blocks = append(blocks, claudeContentBlock{Type: "text", Text: msg.Content})
+		}
+		for _, tc := range msg.ToolCalls {
+			args := tc.Arguments
+			if args == nil {
+				args = map[string]any{}
+			}
+			input, err := json.Marshal(args)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal tool arguments: %w", err)
+			}
+			blocks = append(blocks, claudeContentBlock{
+				Type:  "tool_use",
+				ID:    tc.ID,
+				Name:  tc.Name,
+				Input: input,
+			})
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		if len(result) > 0 && result[len(result)-1].Role == role {
+			result[len(result)-1].Content = append(result[len(result)-1].Content, blocks...)
+		} else {
+			result = append(result, claudeMessage{Role: role, Content: blocks})
+		}
+	}
+	return result, nil
+}
+
+// claudeAttachmentBlocks returns the blocks of a message's attachments:
+// images and PDF documents natively, text files as text.
+func claudeAttachmentBlocks(attachments []Attachment) []claudeContentBlock {
+	blocks := make([]claudeContentBlock, 0, len(attachments))
+	for _, a := range attachments {
+		switch a.kind() {
+		case attachmentImage:
+			blocks = append(blocks, claudeContentBlock{
+				Type:   "image",
+				Source: &claudeSource{Type: "base64", MediaType: a.mediaType(), Data: a.Data},
+			})
+		case attachmentPDF:
+			blocks = append(blocks, claudeContentBlock{
+				Type:   "document",
+				Source: &claudeSource{Type: "base64", MediaType: "application/pdf", Data: a.Data},
+			})
+		case attachmentText:
+			blocks = append(blocks, claudeContentBlock{Type: "text", Text: a.text()})
+		default:
+			blocks = append(blocks, claudeContentBlock{Type: "text", Text: a.placeholder("unsupported file type")})
+		}
+	}
+	return blocks
+}
+
+// claudeToolResultContent returns the tool_result content: plain text, or
+// text and image blocks when the result carries images.
+func claudeToolResultContent(r *ToolResult) any {
+	if !r.hasImage() {
+		return r.Content
+	}
+	blocks := make([]claudeContentBlock, 0, len(r.Blocks))
+	for _, b := range r.Blocks {
+		if b.Type == "image" {
+			blocks = append(blocks, claudeContentBlock{
+				Type:   "image",
+				Source: &claudeSource{Type: "base64", MediaType: b.MimeType, Data: b.Data},
+			})
+			continue
+		}
+		if text := b.AsText(); text != "" {
+			blocks = append(blocks, claudeContentBlock{Type: "text", Text: text})
+		}
+	}
+	return blocks
+}
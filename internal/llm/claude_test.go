@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"agent-stop-and-go/internal/mcp"
+)
+
+func TestToClaudeMessages(t *testing.T) {
+	msgs, err := toClaudeMessages(toolExchangeMessages())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// user, assistant(tool_use), user(tool_result + text)
+	if len(msgs) != 3 {
+		t.Fatalf("got %d messages, want 3", len(msgs))
+	}
+
+	if msgs[1].Role != "assistant" || len(msgs[1].Content) != 1 {
+		t.Fatalf("messages[1] = %+v, want assistant with 1 block", msgs[1])
+	}
+	use := msgs[1].Content[0]
+	if use.Type != "tool_use" || use.ID != "call_1" || use.Name != "resources_list" {
+		t.Errorf("tool_use block = %+v", use)
+	}
+	if string(use.Input) != `{"pattern":"db"}` {
+		t.Errorf("tool_use input = %s, want {\"pattern\":\"db\"}", use.Input)
+	}
+
+	if msgs[2].Role != "user" || len(msgs[2].Content) != 2 {
+		t.Fatalf("messages[2] = %+v, want user with 2 blocks", msgs[2])
+	}
+	result := msgs[2].Content[0]
+	if result.Type != "tool_result" || result.ToolUseID != "call_1" || result.Content != "db-1, db-2" {
+		t.Errorf("tool_result block = %+v", result)
+	}
+	if text := msgs[2].Content[1]; text.Type != "text" || text.Text != "thanks" {
+		t.Errorf("text block = %+v, want text/thanks", text)
+	}
+}
+
+func TestToClaudeMessagesEmptyArguments(t *testing.T) {
+	msgs, err := toClaudeMessages([]Message{
+		{Role: "model", ToolCalls: []ToolCall{{ID: "call_1", Name: "exit_loop"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(msgs[0].Content[0].Input); got != "{}" {
+		t.Errorf("input = %s, want {}", got)
+	}
+}
+
+func TestToClaudeMessagesImageToolResult(t *testing.T) {
+	msgs, err := toClaudeMessages([]Message{
+		{Role: "tool", ToolResult: &ToolResult{
+			ToolCallID: "call_1",
+			Name:       "screenshot",
+			Content:    "captured\n[image: image/png, 5 bytes]",
+			Blocks: []mcp.ContentBlock{
+				{Type: "text", Text: "captured"},
+				{Type: "image", Data: "aGVsbG8=", MimeType: "image/png"},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	blocks, ok := msgs[0].Content[0].Content.([]claudeContentBlock)
+	if !ok {
+		t.Fatalf("tool_result content = %T, want []claudeContentBlock", msgs[0].Content[0].Content)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2", len(blocks))
+	}
+	if blocks[0].Type != "text" || blocks[0].Text != "captured" {
+		t.Errorf("blocks[0] = %+v, want text/captured", blocks[0])
+	}
+	if blocks[1].Type != "image" || blocks[1].Source == nil || blocks[1].Source.MediaType != "image/png" || blocks[1].Source.Data != "aGVsbG8=" {
+		t.Errorf("blocks[1] = %+v, want base64 png image", blocks[1])
+	}
+}
+
+func TestClaudePromptCaching(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":10,"output_tokens":5,"cache_creation_input_tokens":0,"cache_read_input_tokens":2000}}`))
+	}))
+	defer srv.Close()
+
+	client := &ClaudeClient{model: "claude-sonnet-4-6", apiKey: "test", baseURL: srv.URL, client: srv.Client(), retry: fastRetry}
+	tools := []mcp.Tool{{Name: "list", Description: "List items"}, {Name: "add", Description: "Add an item"}}
+	messages := []Message{{Role: "user", Content: "Hi"}}
+	ephemeral := map[string]any{"type": "ephemeral"}
+
+	t.Run("disabled", func(t *testing.T) {
+		if _, err := client.GenerateWithTools(context.Background(), "You are helpful.", messages, tools, RequestOptions{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if body["system"] != "You are helpful." {
+			t.Errorf("system = %#v, want plain string", body["system"])
+		}
+		for _, tool := range body["tools"].([]any) {
+			if _, ok := tool.(map[string]any)["cache_control"]; ok {
+				t.Errorf("tool %v has cache_control", tool)
+			}
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		resp, err := client.GenerateWithTools(context.Background(), "You are helpful.", messages, tools, RequestOptions{CachePrompt: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wantSystem := []any{map[string]any{"type": "text", "text": "You are helpful.", "cache_control": ephemeral}}
+		if !reflect.DeepEqual(body["system"], wantSystem) {
+			t.Errorf("system = %#v, want %#v", body["system"], wantSystem)
+		}
+		toolList := body["tools"].([]any)
+		if _, ok := toolList[0].(map[string]any)["cache_control"]; ok {
+			t.Error("first tool has cache_control, want only the last one")
+		}
+		if got := toolList[1].(map[string]any)["cache_control"]; !reflect.DeepEqual(got, ephemeral) {
+			t.Errorf("last tool cache_control = %#v, want %#v", got, ephemeral)
+		}
+
+		want := &Usage{InputTokens: 2010, OutputTokens: 5, CacheReadTokens: 2000}
+		if !reflect.DeepEqual(resp.Usage, want) {
+			t.Errorf("Usage = %+v, want %+v", resp.Usage, want)
+		}
+	})
+}
+
+func TestClaudeTemperatureAboveMax(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"content":[{"type":"text","text":"ok"}]}`))
+	}))
+	defer srv.Close()
+
+	client := &ClaudeClient{model: "claude-sonnet-4-6", apiKey: "test", baseURL: srv.URL, client: srv.Client(), retry: fastRetry}
+	messages := []Message{{Role: "user", Content: "Hi"}}
+
+	tests := []struct {
+		temperature float64
+		wantErr     bool
+	}{
+		{temperature: 0},
+		{temperature: 1},
+		{temperature: 1.5, wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := client.GenerateWithTools(context.Background(), "", messages, nil, RequestOptions{Temperature: &tt.temperature})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("temperature %g: error = %v, wantErr %v", tt.temperature, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "above the Anthropic maximum of 1") {
+			t.Errorf("temperature %g: error = %v, want the Anthropic maximum", tt.temperature, err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want the rejected temperature not to be sent", requests)
+	}
+}
+
+func TestToClaudeMessagesAttachments(t *testing.T) {
+	msgs, err := toClaudeMessages([]Message{{Role: "user", Content: "What is this?", Attachments: testAttachments()}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	blocks := msgs[0].Content
+	if len(blocks) != 5 {
+		t.Fatalf("got %d blocks, want 5", len(blocks))
+	}
+	if blocks[0].Type != "image" || blocks[0].Source == nil || blocks[0].Source.MediaType != "image/png" || blocks[0].Source.Data != "aGVsbG8=" {
+		t.Errorf("blocks[0] = %+v, want base64 png image", blocks[0])
+	}
+	if blocks[1].Type != "document" || blocks[1].Source == nil || blocks[1].Source.MediaType != "application/pdf" {
+		t.Errorf("blocks[1] = %+v, want pdf document", blocks[1])
+	}
+	if blocks[2].Type != "text" || blocks[2].Text != "File notes.txt:\nhello" {
+		t.Errorf("blocks[2] = %+v, want inlined text file", blocks[2])
+	}
+	if blocks[3].Type != "text" || !strings.HasPrefix(blocks[3].Text, "[Attachment archive.zip (application/zip) not sent") {
+		t.Errorf("blocks[3] = %+v, want placeholder", blocks[3])
+	}
+	if blocks[4].Type != "text" || blocks[4].Text != "What is this?" {
+		t.Errorf("blocks[4] = %+v, want message text last", blocks[4])
+	}
+}
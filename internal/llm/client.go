@@ -3,33 +3,92 @@ package llm
 import (
 	"context"
 	"fmt"
-	"math"
 	"strings"
 
+	"github.com/google/uuid"
+
 	"agent-stop-and-go/internal/mcp"
 )
 
 // Client is the interface for LLM providers.
 type Client interface {
-	GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool) (*Response, error)
+	GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error)
+}
+
+// RequestOptions are the generation parameters of a request, mapped to each
+// provider's native fields. Zero values leave the provider's default.
+type RequestOptions struct {
+	Temperature   *float64 // nil: provider default (0 is a valid temperature)
+	TopP          *float64
+	MaxTokens     int // maximum output tokens
+	StopSequences []string
+	JSON          bool // ask for a JSON object when the provider has a JSON mode
+	CachePrompt   bool // mark the system prompt and tools as cacheable (see ClaudeClient)
 }
 
 // Message represents a conversation message.
+//
+// Model messages may carry the tool calls the LLM issued. Messages with the
+// "tool" role carry the result of a previous call, matched by tool call ID.
+// User messages may carry attachments (images, documents, text files).
 type Message struct {
-	Role    string `json:"role"` // "user" or "model"/"assistant"
-	Content string `json:"content"`
+	Role        string       `json:"role"` // "user", "model"/"assistant" or "tool"
+	Content     string       `json:"content"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	ToolCalls   []ToolCall   `json:"tool_calls,omitempty"`
+	ToolResult  *ToolResult  `json:"tool_result,omitempty"`
 }
 
 // ToolCall represents a function call from the LLM.
 type ToolCall struct {
+	ID        string         `json:"id,omitempty"`
 	Name      string         `json:"name"`
 	Arguments map[string]any `json:"arguments"`
 }
 
+// ToolResult is the outcome of a tool call, sent back to the LLM.
+// Content is the text rendering of the result. Blocks holds the full MCP
+// content; providers that accept images in tool results send them natively.
+type ToolResult struct {
+	ToolCallID string             `json:"tool_call_id"`
+	Name       string             `json:"name"`
+	Content    string             `json:"content"`
+	Blocks     []mcp.ContentBlock `json:"blocks,omitempty"`
+	IsError    bool               `json:"is_error,omitempty"`
+}
+
+// hasImage reports whether the tool result carries image content.
+func (r *ToolResult) hasImage() bool {
+	for _, b := range r.Blocks {
+		if b.Type == "image" {
+			return true
+		}
+	}
+	return false
+}
+
 // Response represents the LLM response.
+// Model is set by FallbackClient to the model that answered.
 type Response struct {
 	Text     string    `json:"text,omitempty"`
 	ToolCall *ToolCall `json:"tool_call,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Usage    *Usage    `json:"usage,omitempty"` // nil when the provider did not report it
+}
+
+// Usage holds the token counts reported by the provider. InputTokens counts
+// all input tokens, including the cached ones.
+type Usage struct {
+	InputTokens      int `json:"input_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`  // input served from the prompt cache
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"` // input written to the prompt cache (Claude)
+}
+
+// String formats the usage for logs.
+func (u *Usage) String() string {
+	return fmt.Sprintf("%d input tokens (%d cached, %d written to cache), %d output tokens",
+		u.InputTokens, u.CacheReadTokens, u.CacheWriteTokens, u.OutputTokens)
 }
 
 // NewClient creates an LLM client based on the model name.
@@ -42,6 +101,19 @@ type Response struct {
 //	"mistral:mistral-large-latest"  → OpenAICompatibleClient (Mistral)
 //	"ollama:llama3"                 → OpenAICompatibleClient (Ollama)
 //	"openrouter:anthropic/claude-3" → OpenAICompatibleClient (OpenRouter)
+//
+// Other OpenAI-compatible providers (vLLM, LM Studio, gateways...) are added
+// with RegisterProvider.
+//
+// The record and replay providers wrap a model for tests, with the cassette
+// file named by LLM_CASSETTE:
+//
+//	"record:google:gemini-2.5-flash" → RecordClient (calls Gemini, records the responses)
+//	"replay:google:gemini-2.5-flash" → ReplayClient (serves the recorded responses)
+//
+// The mock provider answers from a YAML script of rules, without any LLM:
+//
+//	"mock:testdata/script.yaml" → MockClient
 func NewClient(model string) (Client, error) {
 	provider, modelName, hasColon := strings.Cut(model, ":")
 	if !hasColon {
@@ -53,8 +125,14 @@ func NewClient(model string) (Client, error) {
 		return NewGeminiClient(modelName)
 	case "anthropic":
 		return NewClaudeClient(modelName)
+	case "record":
+		return NewRecordClient(modelName)
+	case "replay":
+		return NewReplayClient()
+	case "mock":
+		return NewMockClient(modelName)
 	default:
-		cfg, ok := providers[provider]
+		cfg, ok := lookupProvider(provider)
 		if !ok {
 			return nil, fmt.Errorf("unknown LLM provider: %q", provider)
 		}
@@ -62,6 +140,11 @@ func NewClient(model string) (Client, error) {
 	}
 }
 
+// newToolCallID generates an ID for providers that do not return one.
+func newToolCallID() string {
+	return "call_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:24]
+}
+
 // ToClaudeRole converts a role to Claude's format ("model" → "assistant").
 func ToClaudeRole(role string) string {
 	if role == "model" {
@@ -70,37 +153,21 @@ func ToClaudeRole(role string) string {
 	return role
 }
 
-// CoerceToolCallArgs coerces tool call arguments to match the schema types.
-// LLMs sometimes return numbers for string fields (e.g., IP "192.168.1.100"
-// returned as float64 3232235876). This function converts values to the
-// declared schema type.
+// CoerceToolCallArgs coerces tool call arguments to match the tool's input
+// schema, recursively through arrays and nested objects. LLMs sometimes
+// return numbers for string fields (e.g., IP "192.168.1.100" returned as
+// float64 3232235876), strings for numbers and booleans, or arrays and
+// objects encoded as JSON strings. See mcp.Property.Coerce.
 func CoerceToolCallArgs(tc *ToolCall, tools []mcp.Tool) {
-	if tc == nil {
+	if tc == nil || tc.Arguments == nil {
 		return
 	}
 	for _, tool := range tools {
 		if tool.Name != tc.Name {
 			continue
 		}
-		for propName, propSchema := range tool.InputSchema.Properties {
-			val, ok := tc.Arguments[propName]
-			if !ok {
-				continue
-			}
-			switch propSchema.Type {
-			case "string":
-				switch v := val.(type) {
-				case float64:
-					if v == math.Trunc(v) && !math.IsInf(v, 0) {
-						tc.Arguments[propName] = fmt.Sprintf("%.0f", v)
-					} else {
-						tc.Arguments[propName] = fmt.Sprintf("%g", v)
-					}
-				case bool:
-					tc.Arguments[propName] = fmt.Sprintf("%t", v)
-				}
-			}
-		}
+		schema := tool.InputSchema.ObjectSchema()
+		schema.Coerce(tc.Arguments)
 		break
 	}
 }
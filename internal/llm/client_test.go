@@ -24,6 +24,7 @@ func TestCoerceToolCallArgs(t *testing.T) {
 				Type: "object",
 				Properties: map[string]mcp.Property{
 					"count": {Type: "number"},
+					"force": {Type: "boolean"},
 				},
 			},
 		},
@@ -86,6 +87,14 @@ func TestCoerceToolCallArgs(t *testing.T) {
 			},
 			wantArgs: map[string]any{"count": float64(42)},
 		},
+		{
+			name: "string to number",
+			tc: &ToolCall{
+				Name:      "other_tool",
+				Arguments: map[string]any{"count": "42", "force": "true"},
+			},
+			wantArgs: map[string]any{"count": float64(42), "force": true},
+		},
 		{
 			name: "unknown tool leaves args unchanged",
 			tc: &ToolCall{
@@ -111,3 +120,44 @@ func TestCoerceToolCallArgs(t *testing.T) {
 		})
 	}
 }
+
+// toolExchangeMessages returns a user → tool call → tool result → user exchange.
+func toolExchangeMessages() []Message {
+	return []Message{
+		{Role: "user", Content: "list resources"},
+		{Role: "model", ToolCalls: []ToolCall{{ID: "call_1", Name: "resources_list", Arguments: map[string]any{"pattern": "db"}}}},
+		{Role: "tool", ToolResult: &ToolResult{ToolCallID: "call_1", Name: "resources_list", Content: "db-1, db-2"}},
+		{Role: "user", Content: "thanks"},
+	}
+}
+
+// testAttachments returns one attachment of each kind: image, PDF, text and
+// unsupported.
+func testAttachments() []Attachment {
+	return []Attachment{
+		{Name: "screen.png", MimeType: "image/png", Data: "aGVsbG8="},
+		{Name: "report.pdf", MimeType: "application/pdf", Data: "JVBERi0="},
+		{Name: "notes.txt", MimeType: "text/plain; charset=utf-8", Data: "aGVsbG8="},
+		{Name: "archive.zip", MimeType: "application/zip", Data: "UEsDBA=="},
+	}
+}
+
+func TestCoerceToolCallArgsNested(t *testing.T) {
+	tools := []mcp.Tool{{
+		Name: "copy",
+		InputSchema: mcp.InputSchema{
+			Type: "object",
+			Properties: map[string]mcp.Property{
+				"paths": {Type: "array", Items: &mcp.Property{Type: "string"}},
+			},
+		},
+	}}
+	tc := &ToolCall{Name: "copy", Arguments: map[string]any{"paths": `[1, "b"]`}}
+
+	CoerceToolCallArgs(tc, tools)
+
+	got, ok := tc.Arguments["paths"].([]any)
+	if !ok || len(got) != 2 || got[0] != "1" || got[1] != "b" {
+		t.Errorf("paths = %#v, want [\"1\" \"b\"]", tc.Arguments["paths"])
+	}
+}
@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"agent-stop-and-go/internal/mcp"
+)
+
+// APIError is an error returned by an LLM provider's API.
+type APIError struct {
+	Provider   string // provider name used in the message, e.g. "Gemini", "openai"
+	StatusCode int    // HTTP status code, 0 when the error came in a 2xx response
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s API error: %s", e.Provider, e.Message)
+	}
+	return fmt.Sprintf("%s API error (%d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// newAPIError returns the error of a failed response. message defaults to the status text.
+func newAPIError(provider string, statusCode int, message string) *APIError {
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	return &APIError{Provider: provider, StatusCode: statusCode, Message: message}
+}
+
+// IsRetryable reports whether a request that failed with err may succeed
+// later or with another provider: timeouts, rate limits, quota and server
+// errors (408, 429, 5xx) and network failures. Canceled requests and client
+// errors such as an invalid request or API key are not retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode != 0 && retryableStatus(apiErr.StatusCode)
+	}
+
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// fallbackModel is a model of a fallback chain.
+type fallbackModel struct {
+	name   string // "provider:model"
+	client Client
+}
+
+// FallbackClient tries an ordered list of models: when a model fails with a
+// retryable error, the request goes to the next one. Responses carry the
+// model that answered.
+type FallbackClient struct {
+	models []fallbackModel
+}
+
+// NewFallbackClient creates a client for an ordered list of models
+// ("provider:model"), whose clients are created by newClient, e.g. NewClient.
+func NewFallbackClient(models []string, newClient func(model string) (Client, error)) (*FallbackClient, error) {
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no model configured")
+	}
+	c := &FallbackClient{}
+	for _, model := range models {
+		client, err := newClient(model)
+		if err != nil {
+			return nil, fmt.Errorf("model %s: %w", model, err)
+		}
+		c.models = append(c.models, fallbackModel{name: model, client: client})
+	}
+	return c, nil
+}
+
+// GenerateWithTools sends the request to each model in turn until one answers
+// or fails with an error that is not retryable. The error of the last model
+// tried is returned, with the errors of the previous models.
+func (c *FallbackClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error) {
+	var failures []string
+	for i, m := range c.models {
+		resp, err := m.client.GenerateWithTools(ctx, systemPrompt, messages, tools, opts)
+		if err == nil {
+			resp.Model = m.name
+			return resp, nil
+		}
+
+		failures = append(failures, fmt.Sprintf("%s: %v", m.name, err))
+		if i == len(c.models)-1 || !IsRetryable(err) || ctx.Err() != nil {
+			if len(failures) == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("all models failed: %s: %w", strings.Join(failures, "; "), err)
+		}
+		log.Printf("WARN: model %s failed: %v, falling back to %s", m.name, err, c.models[i+1].name)
+	}
+	return nil, fmt.Errorf("no model configured")
+}
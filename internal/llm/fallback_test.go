@@ -0,0 +1,143 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"rate limited", newAPIError("openai", 429, "Rate limit exceeded"), true},
+		{"quota exhausted", newAPIError("Gemini", 429, "Resource has been exhausted"), true},
+		{"unavailable", newAPIError("Gemini", 503, "The model is overloaded"), true},
+		{"overloaded", newAPIError("Claude", 529, "Overloaded"), true},
+		{"timeout", newAPIError("openai", 408, ""), true},
+		{"unauthorized", newAPIError("openai", 401, "Invalid API key"), false},
+		{"bad request", newAPIError("Claude", 400, "invalid request"), false},
+		{"error in 2xx response", newAPIError("Claude", 0, "invalid request"), false},
+		{"wrapped", fmt.Errorf("node a: %w", newAPIError("mistral", 502, "")), true},
+		{"deadline", context.DeadlineExceeded, true},
+		{"canceled", context.Canceled, false},
+		{"parse error", errors.New("failed to parse response"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestProvider returns an OpenAI-compatible client named name whose API
+// answers with status and body, and counts its requests. It does not retry.
+func newTestProvider(t *testing.T, name string, status int, body string, calls *int) Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	client := NewOpenAICompatibleClient(providerConfig{name: name, baseURL: srv.URL}, "model")
+	client.retry.maxAttempts = 1
+	return client
+}
+
+func TestFallbackClient(t *testing.T) {
+	const ok = `{"choices":[{"message":{"role":"assistant","content":"hello"}}]}`
+	const unavailable = `{"error":{"message":"Service unavailable"}}`
+	const unauthorized = `{"error":{"message":"Invalid API key"}}`
+
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantModel string
+		wantCalls []int
+		wantErr   string
+	}{
+		{
+			name:      "first model answers",
+			statuses:  []int{200, 200, 200},
+			wantModel: "p0:model",
+			wantCalls: []int{1, 0, 0},
+		},
+		{
+			name:      "falls back on retryable errors",
+			statuses:  []int{503, 429, 200},
+			wantModel: "p2:model",
+			wantCalls: []int{1, 1, 1},
+		},
+		{
+			name:      "stops on non-retryable error",
+			statuses:  []int{503, 401, 200},
+			wantCalls: []int{1, 1, 0},
+			wantErr:   "Invalid API key",
+		},
+		{
+			name:      "all models fail",
+			statuses:  []int{503, 503, 503},
+			wantCalls: []int{1, 1, 1},
+			wantErr:   "all models failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := make([]int, len(tt.statuses))
+			client := &FallbackClient{}
+			for i, status := range tt.statuses {
+				body := ok
+				switch status {
+				case 401:
+					body = unauthorized
+				case 429, 503:
+					body = unavailable
+				}
+				name := fmt.Sprintf("p%d", i)
+				client.models = append(client.models, fallbackModel{
+					name:   name + ":model",
+					client: newTestProvider(t, name, status, body, &calls[i]),
+				})
+			}
+
+			resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "hi"}}, nil, RequestOptions{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if resp.Text != "hello" || resp.Model != tt.wantModel {
+					t.Errorf("response = %q from %q, want %q from %q", resp.Text, resp.Model, "hello", tt.wantModel)
+				}
+			}
+			for i, want := range tt.wantCalls {
+				if calls[i] != want {
+					t.Errorf("model %d called %d times, want %d", i, calls[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewFallbackClient_InvalidModel(t *testing.T) {
+	_, err := NewFallbackClient([]string{"ollama:llama3", "llama3"}, NewClient)
+	if err == nil || !strings.Contains(err.Error(), "model llama3") {
+		t.Fatalf("error = %v, want invalid model error", err)
+	}
+}
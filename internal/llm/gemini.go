@@ -5,9 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"agent-stop-and-go/internal/mcp"
@@ -23,6 +24,7 @@ type GeminiClient struct {
 	model  string
 	apiKey string
 	client *http.Client
+	retry  retryPolicy
 }
 
 // NewGeminiClient creates a new Gemini client.
@@ -36,16 +38,26 @@ func NewGeminiClient(model string) (*GeminiClient, error) {
 		model:  model,
 		apiKey: apiKey,
 		client: &http.Client{Timeout: httpClientTimeout},
+		retry:  defaultRetryPolicy,
 	}, nil
 }
 
 // Gemini API request/response types
 
 type geminiRequest struct {
-	Contents          []geminiContent   `json:"contents"`
-	Tools             []geminiTool      `json:"tools,omitempty"`
-	SystemInstruction *geminiContent    `json:"systemInstruction,omitempty"`
-	ToolConfig        *geminiToolConfig `json:"toolConfig,omitempty"`
+	Contents          []geminiContent         `json:"contents"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	ToolConfig        *geminiToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens  int      `json:"maxOutputTokens,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"topP,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	ResponseMIMEType string   `json:"responseMimeType,omitempty"`
 }
 
 type geminiContent struct {
@@ -54,34 +66,58 @@ type geminiContent struct {
 }
 
 type geminiPart struct {
-	Text         string              `json:"text,omitempty"`
-	FunctionCall *geminiFunctionCall `json:"functionCall,omitempty"`
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64-encoded
 }
 
 type geminiFunctionCall struct {
+	ID   string         `json:"id,omitempty"`
 	Name string         `json:"name"`
 	Args map[string]any `json:"args"`
 }
 
+type geminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
 type geminiTool struct {
 	FunctionDeclarations []geminiFunctionDecl `json:"functionDeclarations"`
 }
 
 type geminiFunctionDecl struct {
-	Name        string            `json:"name"`
-	Description string            `json:"description"`
-	Parameters  *geminiParameters `json:"parameters,omitempty"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Parameters  *geminiSchema `json:"parameters,omitempty"`
 }
 
-type geminiParameters struct {
-	Type       string                    `json:"type"`
-	Properties map[string]geminiProperty `json:"properties,omitempty"`
-	Required   []string                  `json:"required,omitempty"`
-}
-
-type geminiProperty struct {
-	Type        string `json:"type"`
-	Description string `json:"description,omitempty"`
+// geminiSchema is the OpenAPI 3.0 subset of JSON Schema accepted by Gemini.
+type geminiSchema struct {
+	Type        string                  `json:"type,omitempty"`
+	Format      string                  `json:"format,omitempty"`
+	Title       string                  `json:"title,omitempty"`
+	Description string                  `json:"description,omitempty"`
+	Nullable    bool                    `json:"nullable,omitempty"`
+	Enum        []string                `json:"enum,omitempty"`
+	Default     any                     `json:"default,omitempty"`
+	Pattern     string                  `json:"pattern,omitempty"`
+	Minimum     *float64                `json:"minimum,omitempty"`
+	Maximum     *float64                `json:"maximum,omitempty"`
+	MinLength   *int                    `json:"minLength,omitempty"`
+	MaxLength   *int                    `json:"maxLength,omitempty"`
+	MinItems    *int                    `json:"minItems,omitempty"`
+	MaxItems    *int                    `json:"maxItems,omitempty"`
+	Items       *geminiSchema           `json:"items,omitempty"`
+	Properties  map[string]geminiSchema `json:"properties,omitempty"`
+	Required    []string                `json:"required,omitempty"`
+	AnyOf       []geminiSchema          `json:"anyOf,omitempty"`
 }
 
 type geminiToolConfig struct {
@@ -93,8 +129,15 @@ type geminiFunctionCallingConfig struct {
 }
 
 type geminiResponse struct {
-	Candidates []geminiCandidate `json:"candidates"`
-	Error      *geminiError      `json:"error,omitempty"`
+	Candidates    []geminiCandidate    `json:"candidates"`
+	UsageMetadata *geminiUsageMetadata `json:"usageMetadata,omitempty"`
+	Error         *geminiError         `json:"error,omitempty"`
+}
+
+type geminiUsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"` // includes the cached tokens
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"` // implicit caching
 }
 
 type geminiCandidate struct {
@@ -107,7 +150,7 @@ type geminiError struct {
 }
 
 // GenerateWithTools sends a request to Gemini with function calling support.
-func (c *GeminiClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool) (*Response, error) {
+func (c *GeminiClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error) {
 	// Convert MCP tools to Gemini function declarations
 	funcDecls := make([]geminiFunctionDecl, 0, len(tools))
 	for _, tool := range tools {
@@ -118,23 +161,9 @@ func (c *GeminiClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 
 		// Convert input schema if present
 		if tool.InputSchema.Properties != nil {
-			params := &geminiParameters{
-				Type:       "object",
-				Properties: make(map[string]geminiProperty),
-				Required:   tool.InputSchema.Required,
-			}
-
-			for propName, propSchema := range tool.InputSchema.Properties {
-				prop := geminiProperty{
-					Type: propSchema.Type,
-				}
-				if propSchema.Description != "" {
-					prop.Description = propSchema.Description
-				}
-				params.Properties[propName] = prop
-			}
-
-			decl.Parameters = params
+			params := toGeminiSchema(tool.InputSchema)
+			params.Type = "object"
+			decl.Parameters = &params
 		}
 
 		funcDecls = append(funcDecls, decl)
@@ -142,7 +171,6 @@ func (c *GeminiClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 
 	// Build request
 	req := geminiRequest{
-		Contents: make([]geminiContent, 0, len(messages)),
 		ToolConfig: &geminiToolConfig{
 			FunctionCallingConfig: &geminiFunctionCallingConfig{
 				Mode: "AUTO",
@@ -150,6 +178,20 @@ func (c *GeminiClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 		},
 	}
 
+	// JSON output cannot be combined with function calling
+	jsonOutput := opts.JSON && len(tools) == 0
+	if opts.MaxTokens > 0 || opts.Temperature != nil || opts.TopP != nil || len(opts.StopSequences) > 0 || jsonOutput {
+		req.GenerationConfig = &geminiGenerationConfig{
+			MaxOutputTokens: opts.MaxTokens,
+			Temperature:     opts.Temperature,
+			TopP:            opts.TopP,
+			StopSequences:   opts.StopSequences,
+		}
+		if jsonOutput {
+			req.GenerationConfig.ResponseMIMEType = "application/json"
+		}
+	}
+
 	// Add system instruction
 	if systemPrompt != "" {
 		req.SystemInstruction = &geminiContent{
@@ -163,16 +205,7 @@ func (c *GeminiClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 	}
 
 	// Convert messages
-	for _, msg := range messages {
-		role := msg.Role
-		if role == "assistant" {
-			role = "model"
-		}
-		req.Contents = append(req.Contents, geminiContent{
-			Role:  role,
-			Parts: []geminiPart{{Text: msg.Content}},
-		})
-	}
+	req.Contents = toGeminiContents(messages)
 
 	// Make API request
 	url := fmt.Sprintf("%s/%s:generateContent?key=%s", baseURL, c.model, c.apiKey)
@@ -188,24 +221,26 @@ func (c *GeminiClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	httpResp, err := c.client.Do(httpReq)
+	// Send request, retried on rate limits and server errors
+	httpResp, respBody, err := c.retry.do(c.client, httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer httpResp.Body.Close()
-
-	respBody, err := io.ReadAll(httpResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
 	var geminiResp geminiResponse
 	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
+		if httpResp.StatusCode >= 300 {
+			return nil, newAPIError("Gemini", httpResp.StatusCode, "")
+		}
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if geminiResp.Error != nil {
-		return nil, fmt.Errorf("Gemini API error: %s", geminiResp.Error.Message)
+		statusCode := geminiResp.Error.Code
+		if httpResp.StatusCode >= 300 {
+			statusCode = httpResp.StatusCode
+		}
+		return nil, newAPIError("Gemini", statusCode, geminiResp.Error.Message)
 	}
 
 	if len(geminiResp.Candidates) == 0 {
@@ -215,10 +250,22 @@ func (c *GeminiClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 	// Parse response
 	candidate := geminiResp.Candidates[0]
 	response := &Response{}
+	if u := geminiResp.UsageMetadata; u != nil {
+		response.Usage = &Usage{
+			InputTokens:     u.PromptTokenCount,
+			OutputTokens:    u.CandidatesTokenCount,
+			CacheReadTokens: u.CachedContentTokenCount,
+		}
+	}
 
 	for _, part := range candidate.Content.Parts {
 		if part.FunctionCall != nil {
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = newToolCallID()
+			}
 			response.ToolCall = &ToolCall{
+				ID:        id,
 				Name:      part.FunctionCall.Name,
 				Arguments: part.FunctionCall.Args,
 			}
@@ -234,3 +281,180 @@ func (c *GeminiClient) GenerateWithTools(ctx context.Context, systemPrompt strin
 
 	return response, nil
 }
+
+// toGeminiContents converts messages to Gemini contents.
+// Tool calls become functionCall parts on model turns and tool results become
+// functionResponse parts on user turns. Gemini matches responses to calls by
+// name and order, so tool call IDs are not sent. Consecutive same-role
+// messages are merged because Gemini requires alternating user/model turns.
+func toGeminiContents(messages []Message) []geminiContent {
+	contents := make([]geminiContent, 0, len(messages))
+	for _, msg := range messages {
+		role := msg.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		var parts []geminiPart
+
+		if msg.ToolResult != nil {
+			role = "user"
+			key := "result"
+			if msg.ToolResult.IsError {
+				key = "error"
+			}
+			parts = append(parts, geminiPart{
+				FunctionResponse: &geminiFunctionResponse{
+					Name:     msg.ToolResult.Name,
+					Response: map[string]any{key: msg.ToolResult.Content},
+				},
+			})
+			// Images are sent as inline data next to the function response
+			for _, b := range msg.ToolResult.Blocks {
+				if b.Type == "image" {
+					parts = append(parts, geminiPart{InlineData: &geminiBlob{MimeType: b.MimeType, Data: b.Data}})
+				}
+			}
+		}
+		for _, a := range msg.Attachments {
+			switch a.kind() {
+			case attachmentImage, attachmentPDF:
+				parts = append(parts, geminiPart{InlineData: &geminiBlob{MimeType: a.mediaType(), Data: a.Data}})
+			case attachmentText:
+				parts = append(parts, geminiPart{Text: a.text()})
+			default:
+				parts = append(parts, geminiPart{Text: a.placeholder("unsupported file type")})
+			}
+		}
+		if msg.Content != "" {
+			parts = append(parts, geminiPart{Text: msg.Content})
+		}
+		for _, tc := range msg.ToolCalls {
+			args := tc.Arguments
+			if args == nil {
+				args = map[string]any{}
+			}
+			parts = append(parts, geminiPart{
+				FunctionCall: &geminiFunctionCall{Name: tc.Name, Args: args},
+			})
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		if len(contents) > 0 && contents[len(contents)-1].Role == role {
+			contents[len(contents)-1].Parts = append(contents[len(contents)-1].Parts, parts...)
+		} else {
+			contents = append(contents, geminiContent{Role: role, Parts: parts})
+		}
+	}
+	return contents
+}
+
+// toGeminiSchema translates a JSON Schema to Gemini's OpenAPI subset.
+// Keywords Gemini does not support are approximated or dropped: oneOf and
+// type unions become anyOf, exclusive bounds become inclusive ones, a string const
+// becomes a single-value enum, and enums of non-string values are described
+// in the description instead.
+func toGeminiSchema(p mcp.Property) geminiSchema {
+	s := geminiSchema{
+		Type:        p.Type,
+		Format:      p.Format,
+		Description: p.Description,
+		Nullable:    p.Nullable,
+		Default:     p.Default,
+		Pattern:     p.Pattern,
+		Minimum:     p.Minimum,
+		Maximum:     p.Maximum,
+		MinLength:   p.MinLength,
+		MaxLength:   p.MaxLength,
+		MinItems:    p.MinItems,
+		MaxItems:    p.MaxItems,
+		Required:    p.Required,
+	}
+	if s.Type == "" && p.Properties != nil {
+		s.Type = "object"
+	}
+	s.Title, _ = p.Extra["title"].(string)
+	if s.Minimum == nil {
+		s.Minimum = p.ExclusiveMinimum
+	}
+	if s.Maximum == nil {
+		s.Maximum = p.ExclusiveMaximum
+	}
+
+	enum := p.Enum
+	if c, ok := p.Extra["const"]; ok && len(enum) == 0 {
+		enum = []any{c}
+	}
+	if len(enum) > 0 {
+		values := make([]string, 0, len(enum))
+		allStrings := true
+		for _, v := range enum {
+			str, ok := v.(string)
+			if !ok {
+				allStrings = false
+				b, _ := json.Marshal(v)
+				str = string(b)
+			}
+			values = append(values, str)
+		}
+		if allStrings && (s.Type == "string" || s.Type == "") {
+			s.Type = "string"
+			s.Enum = values
+		} else {
+			allowed := "Allowed values: " + strings.Join(values, ", ")
+			if s.Description != "" {
+				allowed = s.Description + " (" + allowed + ")"
+			}
+			s.Description = allowed
+		}
+	}
+
+	if p.Items != nil {
+		items := toGeminiSchema(*p.Items)
+		s.Items = &items
+	}
+	if p.Properties != nil {
+		s.Properties = make(map[string]geminiSchema, len(p.Properties))
+		for name, prop := range p.Properties {
+			s.Properties[name] = toGeminiSchema(prop)
+		}
+	}
+	if len(p.Types) > 1 {
+		s.Type = ""
+		for _, t := range p.Types {
+			s.AnyOf = append(s.AnyOf, geminiSchema{Type: t})
+		}
+	}
+	for _, alt := range slices.Concat(p.AnyOf, p.OneOf) {
+		// A null alternative is expressed with nullable
+		if alt.Type == "null" {
+			s.Nullable = true
+			continue
+		}
+		s.AnyOf = append(s.AnyOf, toGeminiSchema(alt))
+	}
+	// A nullable union (type ["a", "b", "null"] or a null alternative) is
+	// nullable as a whole and in each alternative, like type ["a", "null"]
+	if s.Nullable && len(s.AnyOf) > 1 {
+		for i := range s.AnyOf {
+			s.AnyOf[i].Nullable = true
+		}
+	}
+	// anyOf with a single remaining alternative (e.g. Optional[X]) is X itself
+	if len(s.AnyOf) == 1 && s.Type == "" {
+		only := s.AnyOf[0]
+		only.Nullable = only.Nullable || s.Nullable
+		if only.Description == "" {
+			only.Description = s.Description
+		}
+		if only.Title == "" {
+			only.Title = s.Title
+		}
+		if only.Default == nil {
+			only.Default = s.Default
+		}
+		return only
+	}
+	return s
+}
@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"agent-stop-and-go/internal/mcp"
+)
+
+func TestToGeminiContents(t *testing.T) {
+	contents := toGeminiContents(toolExchangeMessages())
+
+	// user, model(functionCall), user(functionResponse + text)
+	if len(contents) != 3 {
+		t.Fatalf("got %d contents, want 3", len(contents))
+	}
+
+	if contents[1].Role != "model" || len(contents[1].Parts) != 1 {
+		t.Fatalf("contents[1] = %+v, want model with 1 part", contents[1])
+	}
+	call := contents[1].Parts[0].FunctionCall
+	if call == nil || call.Name != "resources_list" || call.Args["pattern"] != "db" {
+		t.Errorf("functionCall = %+v", call)
+	}
+	if call != nil && call.ID != "" {
+		t.Errorf("functionCall ID = %q, want empty", call.ID)
+	}
+
+	if contents[2].Role != "user" || len(contents[2].Parts) != 2 {
+		t.Fatalf("contents[2] = %+v, want user with 2 parts", contents[2])
+	}
+	resp := contents[2].Parts[0].FunctionResponse
+	if resp == nil || resp.Name != "resources_list" || resp.Response["result"] != "db-1, db-2" {
+		t.Errorf("functionResponse = %+v", resp)
+	}
+	if text := contents[2].Parts[1].Text; text != "thanks" {
+		t.Errorf("text part = %q, want %q", text, "thanks")
+	}
+}
+
+func TestToGeminiContentsErrorResult(t *testing.T) {
+	contents := toGeminiContents([]Message{
+		{Role: "tool", ToolResult: &ToolResult{ToolCallID: "call_1", Name: "read_file", Content: "not found", IsError: true}},
+	})
+	resp := contents[0].Parts[0].FunctionResponse
+	if resp.Response["error"] != "not found" {
+		t.Errorf("response = %v, want error key", resp.Response)
+	}
+}
+
+func TestToGeminiContentsImageToolResult(t *testing.T) {
+	contents := toGeminiContents([]Message{
+		{Role: "tool", ToolResult: &ToolResult{
+			ToolCallID: "call_1",
+			Name:       "screenshot",
+			Content:    "[image: image/png, 5 bytes]",
+			Blocks:     []mcp.ContentBlock{{Type: "image", Data: "aGVsbG8=", MimeType: "image/png"}},
+		}},
+	})
+
+	parts := contents[0].Parts
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parts))
+	}
+	if parts[0].FunctionResponse == nil {
+		t.Error("parts[0]: expected functionResponse")
+	}
+	if parts[1].InlineData == nil || parts[1].InlineData.MimeType != "image/png" || parts[1].InlineData.Data != "aGVsbG8=" {
+		t.Errorf("parts[1].InlineData = %+v, want base64 png", parts[1].InlineData)
+	}
+}
+
+func TestToGeminiSchema(t *testing.T) {
+	schema, err := mcp.ParseSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"mode": {"type": "string", "enum": ["fast", "safe"]},
+			"level": {"type": "integer", "enum": [1, 2, 3], "description": "Log level"},
+			"ratio": {"type": "number", "exclusiveMinimum": 0},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 3},
+			"owner": {"anyOf": [{"$ref": "#/$defs/Person"}, {"type": "null"}], "description": "Owner"},
+			"kind": {"const": "file"},
+			"port": {"type": ["string", "integer"]},
+			"limit": {"type": ["integer", "string", "null"]},
+			"retries": {"anyOf": [{"type": "integer"}, {"type": "string"}, {"type": "null"}]}
+		},
+		"required": ["mode"],
+		"additionalProperties": false,
+		"$defs": {"Person": {"type": "object", "properties": {"name": {"type": "string"}}}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := toGeminiSchema(schema)
+	if s.Type != "object" || len(s.Required) != 1 {
+		t.Errorf("root = %+v", s)
+	}
+	if mode := s.Properties["mode"]; len(mode.Enum) != 2 || mode.Enum[0] != "fast" {
+		t.Errorf("mode.enum = %v", mode.Enum)
+	}
+	if level := s.Properties["level"]; level.Enum != nil || level.Description != "Log level (Allowed values: 1, 2, 3)" {
+		t.Errorf("level = %+v, want non-string enum moved to description", level)
+	}
+	if ratio := s.Properties["ratio"]; ratio.Minimum == nil || *ratio.Minimum != 0 {
+		t.Errorf("ratio.minimum = %v, want exclusive bound as minimum", ratio.Minimum)
+	}
+	if tags := s.Properties["tags"]; tags.Items == nil || tags.Items.Type != "string" || *tags.MaxItems != 3 {
+		t.Errorf("tags = %+v", tags)
+	}
+	owner := s.Properties["owner"]
+	if owner.Type != "object" || !owner.Nullable || owner.Properties["name"].Type != "string" || owner.Description != "Owner" {
+		t.Errorf("owner = %+v, want nullable object from anyOf", owner)
+	}
+	if kind := s.Properties["kind"]; kind.Type != "string" || len(kind.Enum) != 1 || kind.Enum[0] != "file" {
+		t.Errorf("kind = %+v, want const as single-value enum", kind)
+	}
+	if port := s.Properties["port"]; port.Type != "" || len(port.AnyOf) != 2 || port.AnyOf[1].Type != "integer" {
+		t.Errorf("port = %+v, want type union as anyOf", port)
+	}
+	for _, name := range []string{"limit", "retries"} {
+		union := s.Properties[name]
+		if !union.Nullable || len(union.AnyOf) != 2 || !union.AnyOf[0].Nullable || !union.AnyOf[1].Nullable {
+			t.Errorf("%s = %+v, want nullable anyOf of nullable alternatives", name, union)
+		}
+	}
+
+	data, _ := json.Marshal(s)
+	for _, unsupported := range []string{"additionalProperties", "$defs", "$ref", "exclusiveMinimum", "const"} {
+		if strings.Contains(string(data), unsupported) {
+			t.Errorf("Gemini schema contains unsupported keyword %q: %s", unsupported, data)
+		}
+	}
+}
+
+func TestToGeminiContentsAttachments(t *testing.T) {
+	contents := toGeminiContents([]Message{{Role: "user", Content: "What is this?", Attachments: testAttachments()}})
+
+	parts := contents[0].Parts
+	if len(parts) != 5 {
+		t.Fatalf("got %d parts, want 5", len(parts))
+	}
+	if parts[0].InlineData == nil || parts[0].InlineData.MimeType != "image/png" || parts[0].InlineData.Data != "aGVsbG8=" {
+		t.Errorf("parts[0].InlineData = %+v, want base64 png", parts[0].InlineData)
+	}
+	if parts[1].InlineData == nil || parts[1].InlineData.MimeType != "application/pdf" {
+		t.Errorf("parts[1].InlineData = %+v, want pdf", parts[1].InlineData)
+	}
+	if parts[2].Text != "File notes.txt:\nhello" {
+		t.Errorf("parts[2].Text = %q, want inlined text file", parts[2].Text)
+	}
+	if !strings.HasPrefix(parts[3].Text, "[Attachment archive.zip") {
+		t.Errorf("parts[3].Text = %q, want placeholder", parts[3].Text)
+	}
+	if parts[4].Text != "What is this?" {
+		t.Errorf("parts[4].Text = %q, want message text last", parts[4].Text)
+	}
+}
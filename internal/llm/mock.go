@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"agent-stop-and-go/internal/mcp"
+)
+
+// mockScript is the YAML script of the mock provider: an ordered list of rules.
+type mockScript struct {
+	Rules []mockRule `yaml:"rules"`
+}
+
+// mockRule answers the requests whose system prompt and last message match
+// its patterns (an empty pattern matches anything) with a text or a tool call.
+// Text and string arguments may refer to the groups of the message pattern
+// ($1, ${name}).
+type mockRule struct {
+	System   string        `yaml:"system,omitempty"`  // regexp on the system prompt
+	Message  string        `yaml:"message,omitempty"` // regexp on the last message (text or tool result)
+	Times    int           `yaml:"times,omitempty"`   // number of requests answered, 0 for no limit
+	Text     string        `yaml:"text,omitempty"`
+	ToolCall *mockToolCall `yaml:"tool_call,omitempty"`
+
+	system, message *regexp.Regexp
+}
+
+type mockToolCall struct {
+	Name      string         `yaml:"name"`
+	Arguments map[string]any `yaml:"arguments,omitempty"`
+}
+
+// MockClient answers requests from a script of rules, without any LLM, to
+// develop and test agent trees locally. The first matching rule answers.
+type MockClient struct {
+	path  string
+	rules []mockRule
+
+	mu   sync.Mutex
+	used []int // requests answered by each rule
+}
+
+// NewMockClient creates a client answering from the script at path.
+func NewMockClient(path string) (*MockClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mock script: %w", err)
+	}
+	var script mockScript
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse mock script %s: %w", path, err)
+	}
+	if len(script.Rules) == 0 {
+		return nil, fmt.Errorf("mock script %s: no rules", path)
+	}
+
+	for i := range script.Rules {
+		r := &script.Rules[i]
+		if (r.Text == "") == (r.ToolCall == nil) {
+			return nil, fmt.Errorf("mock script %s: rules[%d]: exactly one of text and tool_call is required", path, i)
+		}
+		if r.ToolCall != nil {
+			if r.ToolCall.Name == "" {
+				return nil, fmt.Errorf("mock script %s: rules[%d]: tool_call.name is required", path, i)
+			}
+			// Arguments get the JSON types an LLM returns (numbers are float64)
+			args, err := json.Marshal(r.ToolCall.Arguments)
+			if err == nil {
+				err = json.Unmarshal(args, &r.ToolCall.Arguments)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("mock script %s: rules[%d].tool_call.arguments: %w", path, i, err)
+			}
+		}
+		if r.system, err = regexp.Compile(r.System); err != nil {
+			return nil, fmt.Errorf("mock script %s: rules[%d].system: %w", path, i, err)
+		}
+		if r.message, err = regexp.Compile(r.Message); err != nil {
+			return nil, fmt.Errorf("mock script %s: rules[%d].message: %w", path, i, err)
+		}
+	}
+
+	return &MockClient{path: path, rules: script.Rules, used: make([]int, len(script.Rules))}, nil
+}
+
+// GenerateWithTools answers with the first rule matching the request.
+func (c *MockClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error) {
+	last := ""
+	if len(messages) > 0 {
+		m := messages[len(messages)-1]
+		last = m.Content
+		if m.ToolResult != nil {
+			last = m.ToolResult.Content
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, r := range c.rules {
+		if r.Times > 0 && c.used[i] >= r.Times {
+			continue
+		}
+		if !r.system.MatchString(systemPrompt) {
+			continue
+		}
+		groups := r.message.FindStringSubmatchIndex(last)
+		if groups == nil {
+			continue
+		}
+		c.used[i]++
+
+		expand := func(s string) string {
+			return string(r.message.ExpandString(nil, s, last, groups))
+		}
+		if r.ToolCall == nil {
+			return &Response{Text: expand(r.Text)}, nil
+		}
+		args := make(map[string]any, len(r.ToolCall.Arguments))
+		for k, v := range r.ToolCall.Arguments {
+			if s, ok := v.(string); ok {
+				v = expand(s)
+			}
+			args[k] = v
+		}
+		return &Response{ToolCall: &ToolCall{ID: newToolCallID(), Name: r.ToolCall.Name, Arguments: args}}, nil
+	}
+
+	return nil, fmt.Errorf("mock: no rule of %s matches the request (last message %q)", c.path, last)
+}
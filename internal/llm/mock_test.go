@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeMockScript(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMockClient(t *testing.T) {
+	path := writeMockScript(t, `rules:
+  - system: (?i)critic
+    text: LGTM
+  - message: ^delete (?P<id>\w+)$
+    tool_call:
+      name: resources_remove
+      arguments:
+        id: ${id}
+        force: true
+        retries: 2
+  - message: '"count": 0'
+    times: 1
+    tool_call:
+      name: resources_add
+      arguments: {name: auto-1, value: 100}
+  - message: '"count"'
+    tool_call:
+      name: exit_loop
+  - message: hello
+    text: Hi! You said "$0".
+`)
+	client, err := NewClient("mock:" + path)
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		system   string
+		message  Message
+		wantText string
+		wantTool string
+		wantArgs map[string]any
+		wantErr  string
+	}{
+		{
+			name:     "system prompt match",
+			system:   "You are a critic.",
+			message:  Message{Role: "user", Content: "delete r1"},
+			wantText: "LGTM",
+		},
+		{
+			name:     "tool call with captured argument",
+			message:  Message{Role: "user", Content: "delete r1"},
+			wantTool: "resources_remove",
+			wantArgs: map[string]any{"id": "r1", "force": true, "retries": float64(2)},
+		},
+		{
+			name:     "tool result match, once",
+			message:  Message{Role: "tool", ToolResult: &ToolResult{Name: "resources_list", Content: `{"count": 0}`}},
+			wantTool: "resources_add",
+			wantArgs: map[string]any{"name": "auto-1", "value": float64(100)},
+		},
+		{
+			name:     "used rule falls through to the next one",
+			message:  Message{Role: "tool", ToolResult: &ToolResult{Name: "resources_list", Content: `{"count": 0}`}},
+			wantTool: "exit_loop",
+			wantArgs: map[string]any{},
+		},
+		{
+			name:     "text with whole match",
+			message:  Message{Role: "user", Content: "well, hello there"},
+			wantText: `Hi! You said "hello".`,
+		},
+		{
+			name:    "no rule matches",
+			message: Message{Role: "user", Content: "something else"},
+			wantErr: `no rule of ` + path + ` matches the request (last message "something else")`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.GenerateWithTools(context.Background(), tt.system, []Message{tt.message}, nil, RequestOptions{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", resp.Text, tt.wantText)
+			}
+			if tt.wantTool == "" {
+				if resp.ToolCall != nil {
+					t.Errorf("ToolCall = %+v, want nil", resp.ToolCall)
+				}
+				return
+			}
+			if resp.ToolCall == nil || resp.ToolCall.Name != tt.wantTool {
+				t.Fatalf("ToolCall = %+v, want %s", resp.ToolCall, tt.wantTool)
+			}
+			if resp.ToolCall.ID == "" {
+				t.Error("ToolCall.ID is empty")
+			}
+			if len(tt.wantArgs) > 0 || len(resp.ToolCall.Arguments) > 0 {
+				if !reflect.DeepEqual(resp.ToolCall.Arguments, tt.wantArgs) {
+					t.Errorf("Arguments = %#v, want %#v", resp.ToolCall.Arguments, tt.wantArgs)
+				}
+			}
+		})
+	}
+}
+
+func TestNewMockClient_InvalidScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{"no rules", "rules: []\n", "no rules"},
+		{"no answer", "rules:\n  - message: hi\n", "exactly one of text and tool_call"},
+		{"two answers", "rules:\n  - text: hi\n    tool_call: {name: t}\n", "exactly one of text and tool_call"},
+		{"tool without name", "rules:\n  - tool_call: {arguments: {a: 1}}\n", "tool_call.name is required"},
+		{"invalid regexp", "rules:\n  - message: '('\n    text: hi\n", "rules[0].message"},
+		{"invalid yaml", "rules: [", "failed to parse mock script"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMockClient(writeMockScript(t, tt.script))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewMockClient(filepath.Join(t.TempDir(), "missing.yaml")); err == nil || !strings.Contains(err.Error(), "failed to read mock script") {
+		t.Errorf("error = %v, want missing script error", err)
+	}
+}
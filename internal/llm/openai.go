@@ -3,11 +3,16 @@ package llm
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
+	"maps"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 
 	"agent-stop-and-go/internal/mcp"
 )
@@ -18,25 +23,39 @@ type providerConfig struct {
 	baseURL   string
 	apiKeyEnv string
 	headers   map[string]string
+	noTools   bool // the API does not support function calling: tools are not sent
+	jsonMode  bool // the API supports response_format json_object
+	// promptCache: the API accepts prompt_cache_key, which routes requests
+	// sharing a prompt prefix to the same cache
+	promptCache bool
+	vision      bool // the API accepts image and file content parts
 }
 
 // providers is the registry of OpenAI-compatible provider configurations.
-// Adding a new provider requires only a new entry here.
+// Adding a built-in provider requires only a new entry here; others are
+// added from the configuration with RegisterProvider.
 var providers = map[string]providerConfig{
 	"openai": {
-		name:      "openai",
-		baseURL:   "https://api.openai.com/v1",
-		apiKeyEnv: "OPENAI_API_KEY",
+		name:        "openai",
+		baseURL:     "https://api.openai.com/v1",
+		apiKeyEnv:   "OPENAI_API_KEY",
+		jsonMode:    true,
+		promptCache: true,
+		vision:      true,
 	},
 	"mistral": {
 		name:      "mistral",
 		baseURL:   "https://api.mistral.ai/v1",
 		apiKeyEnv: "MISTRAL_API_KEY",
+		jsonMode:  true,
+		vision:    true,
 	},
 	"ollama": {
 		name:      "ollama",
 		baseURL:   "http://localhost:11434/v1",
 		apiKeyEnv: "",
+		jsonMode:  true,
+		vision:    true,
 	},
 	"openrouter": {
 		name:      "openrouter",
@@ -46,14 +65,73 @@ var providers = map[string]providerConfig{
 			"HTTP-Referer": "https://github.com/agentic-platform",
 			"X-Title":      "Agent Stop and Go",
 		},
+		jsonMode: true,
+		vision:   true,
 	},
 }
 
+// providersMu protects providers.
+var providersMu sync.RWMutex
+
+// reservedProviders are the prefixes that are not OpenAI-compatible providers.
+var reservedProviders = []string{"google", "anthropic", "record", "replay", "mock"}
+
+// Provider describes an OpenAI-compatible API (vLLM, LM Studio, llama.cpp,
+// gateways...) declared in the configuration.
+type Provider struct {
+	Name      string            // model prefix: "<name>:<model>"
+	BaseURL   string            // e.g. "http://localhost:8000/v1"
+	APIKeyEnv string            // environment variable holding the API key (optional)
+	Headers   map[string]string // sent with every request
+	Tools     bool              // the API supports function calling
+	JSONMode  bool              // the API supports response_format json_object
+	// PromptCache: the API accepts prompt_cache_key (OpenAI prompt caching)
+	PromptCache bool
+	Vision      bool // the API accepts images and PDF files in user messages
+}
+
+// RegisterProvider adds an OpenAI-compatible provider, or replaces the
+// built-in provider of the same name.
+func RegisterProvider(p Provider) error {
+	if p.Name == "" || strings.Contains(p.Name, ":") {
+		return fmt.Errorf("invalid provider name %q", p.Name)
+	}
+	if slices.Contains(reservedProviders, p.Name) {
+		return fmt.Errorf("provider name %q is reserved", p.Name)
+	}
+	if p.BaseURL == "" {
+		return fmt.Errorf("provider %s: base URL is required", p.Name)
+	}
+
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name] = providerConfig{
+		name:        p.Name,
+		baseURL:     strings.TrimSuffix(p.BaseURL, "/"),
+		apiKeyEnv:   p.APIKeyEnv,
+		headers:     maps.Clone(p.Headers),
+		noTools:     !p.Tools,
+		jsonMode:    p.JSONMode,
+		promptCache: p.PromptCache,
+		vision:      p.Vision,
+	}
+	return nil
+}
+
+// lookupProvider returns the configuration of an OpenAI-compatible provider.
+func lookupProvider(name string) (providerConfig, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	cfg, ok := providers[name]
+	return cfg, ok
+}
+
 // OpenAICompatibleClient handles communication with OpenAI-compatible APIs.
 type OpenAICompatibleClient struct {
 	model  string
 	config providerConfig
 	client *http.Client
+	retry  retryPolicy
 }
 
 // NewOpenAICompatibleClient creates a new client for the given provider config and model name.
@@ -69,20 +147,49 @@ func NewOpenAICompatibleClient(cfg providerConfig, model string) *OpenAICompatib
 		model:  model,
 		config: cfg,
 		client: &http.Client{Timeout: httpClientTimeout},
+		retry:  defaultRetryPolicy,
 	}
 }
 
 // OpenAI Chat Completions request/response types
 
 type openaiRequest struct {
-	Model    string          `json:"model"`
-	Messages []openaiMessage `json:"messages"`
-	Tools    []openaiTool    `json:"tools,omitempty"`
+	Model          string                `json:"model"`
+	Messages       []openaiMessage       `json:"messages"`
+	Tools          []openaiTool          `json:"tools,omitempty"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	Temperature    *float64              `json:"temperature,omitempty"`
+	TopP           *float64              `json:"top_p,omitempty"`
+	Stop           []string              `json:"stop,omitempty"`
+	ResponseFormat *openaiResponseFormat `json:"response_format,omitempty"`
+	PromptCacheKey string                `json:"prompt_cache_key,omitempty"`
+}
+
+type openaiResponseFormat struct {
+	Type string `json:"type"` // "json_object"
 }
 
 type openaiMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string           `json:"role"`
+	Content    any              `json:"content"` // string, or []openaiContentPart with attachments
+	ToolCalls  []openaiToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openaiContentPart struct {
+	Type     string          `json:"type"` // "text", "image_url" or "file"
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openaiImageURL `json:"image_url,omitempty"`
+	File     *openaiFile     `json:"file,omitempty"`
+}
+
+type openaiImageURL struct {
+	URL string `json:"url"` // data: URL
+}
+
+type openaiFile struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data"` // data: URL
 }
 
 type openaiTool struct {
@@ -98,6 +205,15 @@ type openaiFunction struct {
 
 type openaiResponse struct {
 	Choices []openaiChoice `json:"choices"`
+	Usage   *openaiUsage   `json:"usage,omitempty"`
+}
+
+type openaiUsage struct {
+	PromptTokens        int `json:"prompt_tokens"` // includes the cached tokens
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details,omitempty"`
 }
 
 type openaiChoice struct {
@@ -132,44 +248,38 @@ type openaiError struct {
 }
 
 // GenerateWithTools sends a request to an OpenAI-compatible API with function calling support.
-func (c *OpenAICompatibleClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool) (*Response, error) {
+func (c *OpenAICompatibleClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error) {
 	// Build messages array
 	msgs := make([]openaiMessage, 0, len(messages)+1)
 	if systemPrompt != "" {
 		msgs = append(msgs, openaiMessage{Role: "system", Content: systemPrompt})
 	}
-	for _, msg := range messages {
-		role := msg.Role
-		if role == "model" {
-			role = "assistant"
-		}
-		msgs = append(msgs, openaiMessage{Role: role, Content: msg.Content})
+	converted, err := toOpenAIMessages(messages, c.config.vision)
+	if err != nil {
+		return nil, err
 	}
+	msgs = append(msgs, converted...)
 
 	// Build request
 	req := openaiRequest{
-		Model:    c.model,
-		Messages: msgs,
+		Model:       c.model,
+		Messages:    msgs,
+		MaxTokens:   opts.MaxTokens,
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+		Stop:        opts.StopSequences,
+	}
+	if opts.JSON && c.config.jsonMode {
+		req.ResponseFormat = &openaiResponseFormat{Type: "json_object"}
 	}
 
 	// Convert MCP tools to OpenAI function calling format
-	if len(tools) > 0 {
+	if len(tools) > 0 && !c.config.noTools {
 		oaiTools := make([]openaiTool, 0, len(tools))
 		for _, tool := range tools {
-			params := map[string]any{"type": "object"}
-			if tool.InputSchema.Properties != nil {
-				props := make(map[string]any, len(tool.InputSchema.Properties))
-				for propName, propSchema := range tool.InputSchema.Properties {
-					p := map[string]any{"type": propSchema.Type}
-					if propSchema.Description != "" {
-						p["description"] = propSchema.Description
-					}
-					props[propName] = p
-				}
-				params["properties"] = props
-			}
-			if tool.InputSchema.Required != nil {
-				params["required"] = tool.InputSchema.Required
+			params, err := toOpenAIParameters(tool.InputSchema)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert schema of tool %s: %w", tool.Name, err)
 			}
 			oaiTools = append(oaiTools, openaiTool{
 				Type: "function",
@@ -182,6 +292,13 @@ func (c *OpenAICompatibleClient) GenerateWithTools(ctx context.Context, systemPr
 		}
 		req.Tools = oaiTools
 	}
+	if opts.CachePrompt && c.config.promptCache {
+		key, err := promptCacheKey(systemPrompt, req.Tools)
+		if err != nil {
+			return nil, err
+		}
+		req.PromptCacheKey = key
+	}
 
 	// Marshal request body
 	body, err := json.Marshal(req)
@@ -210,25 +327,19 @@ func (c *OpenAICompatibleClient) GenerateWithTools(ctx context.Context, systemPr
 		httpReq.Header.Set(k, v)
 	}
 
-	// Send request
-	httpResp, err := c.client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer httpResp.Body.Close()
-
-	respBody, err := io.ReadAll(httpResp.Body)
+	// Send request, retried on rate limits and server errors
+	httpResp, respBody, err := c.retry.do(c.client, httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
 	// Handle non-2xx responses
 	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
 		var errResp openaiErrorResponse
 		if err := json.Unmarshal(respBody, &errResp); err == nil && errResp.Error != nil {
-			return nil, fmt.Errorf("%s API error (%d): %s", c.config.name, httpResp.StatusCode, errResp.Error.Message)
+			return nil, newAPIError(c.config.name, httpResp.StatusCode, errResp.Error.Message)
 		}
-		return nil, fmt.Errorf("%s API error (%d): %s", c.config.name, httpResp.StatusCode, http.StatusText(httpResp.StatusCode))
+		return nil, newAPIError(c.config.name, httpResp.StatusCode, "")
 	}
 
 	// Parse response
@@ -243,6 +354,12 @@ func (c *OpenAICompatibleClient) GenerateWithTools(ctx context.Context, systemPr
 
 	choice := oaiResp.Choices[0]
 	response := &Response{}
+	if u := oaiResp.Usage; u != nil {
+		response.Usage = &Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+		if u.PromptTokensDetails != nil {
+			response.Usage.CacheReadTokens = u.PromptTokensDetails.CachedTokens
+		}
+	}
 
 	// Tool call takes precedence over text
 	if len(choice.Message.ToolCalls) > 0 {
@@ -251,7 +368,12 @@ func (c *OpenAICompatibleClient) GenerateWithTools(ctx context.Context, systemPr
 		if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
 			return nil, fmt.Errorf("failed to parse tool arguments: %w", err)
 		}
+		id := tc.ID
+		if id == "" {
+			id = newToolCallID()
+		}
 		response.ToolCall = &ToolCall{
+			ID:        id,
 			Name:      tc.Function.Name,
 			Arguments: args,
 		}
@@ -264,3 +386,100 @@ func (c *OpenAICompatibleClient) GenerateWithTools(ctx context.Context, systemPr
 
 	return response, nil
 }
+
+// toOpenAIMessages converts messages to the Chat Completions format.
+// Tool calls become assistant tool_calls and each tool result becomes a
+// "tool" message referencing its tool_call_id.
+func toOpenAIMessages(messages []Message, vision bool) ([]openaiMessage, error) {
+	msgs := make([]openaiMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.ToolResult != nil {
+			msgs = append(msgs, openaiMessage{
+				Role:       "tool",
+				Content:    msg.ToolResult.Content,
+				ToolCallID: msg.ToolResult.ToolCallID,
+			})
+			continue
+		}
+
+		role := msg.Role
+		if role == "model" {
+			role = "assistant"
+		}
+		oaiMsg := openaiMessage{Role: role, Content: msg.Content}
+		if len(msg.Attachments) > 0 {
+			oaiMsg.Content = openaiContentParts(msg, vision)
+		}
+		for _, tc := range msg.ToolCalls {
+			args := tc.Arguments
+			if args == nil {
+				args = map[string]any{}
+			}
+			argsJSON, err := json.Marshal(args)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal tool arguments: %w", err)
+			}
+			oaiMsg.ToolCalls = append(oaiMsg.ToolCalls, openaiToolCall{
+				ID:       tc.ID,
+				Type:     "function",
+				Function: openaiToolCallFunc{Name: tc.Name, Arguments: string(argsJSON)},
+			})
+		}
+		msgs = append(msgs, oaiMsg)
+	}
+	return msgs, nil
+}
+
+// openaiContentParts returns the content parts of a message with
+// attachments: images as image_url and PDFs as file parts when the provider
+// accepts them, text files as text.
+func openaiContentParts(msg Message, vision bool) []openaiContentPart {
+	parts := make([]openaiContentPart, 0, len(msg.Attachments)+1)
+	for _, a := range msg.Attachments {
+		kind := a.kind()
+		switch {
+		case kind == attachmentText:
+			parts = append(parts, openaiContentPart{Type: "text", Text: a.text()})
+		case kind == "":
+			parts = append(parts, openaiContentPart{Type: "text", Text: a.placeholder("unsupported file type")})
+		case !vision:
+			parts = append(parts, openaiContentPart{Type: "text", Text: a.placeholder("the provider does not accept images or files")})
+		case kind == attachmentImage:
+			parts = append(parts, openaiContentPart{Type: "image_url", ImageURL: &openaiImageURL{URL: a.dataURL()}})
+		case kind == attachmentPDF:
+			parts = append(parts, openaiContentPart{Type: "file", File: &openaiFile{Filename: a.Name, FileData: a.dataURL()}})
+		}
+	}
+	if msg.Content != "" {
+		parts = append(parts, openaiContentPart{Type: "text", Text: msg.Content})
+	}
+	return parts
+}
+
+// toOpenAIParameters converts a tool input schema to function parameters.
+// OpenAI-compatible APIs accept JSON Schema as-is.
+func toOpenAIParameters(schema mcp.InputSchema) (map[string]any, error) {
+	data, err := json.Marshal(schema.ObjectSchema())
+	if err != nil {
+		return nil, err
+	}
+	var params map[string]any
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// promptCacheKey identifies the prompt prefix shared by requests with the same
+// system prompt and tools, so that the provider routes them to the same cache.
+func promptCacheKey(systemPrompt string, tools []openaiTool) (string, error) {
+	toolsJSON, err := json.Marshal(tools)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal tools: %w", err)
+	}
+	h := sha256.New()
+	h.Write([]byte(systemPrompt))
+	h.Write([]byte{0})
+	h.Write(toolsJSON)
+	return hex.EncodeToString(h.Sum(nil))[:32], nil
+}
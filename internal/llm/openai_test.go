@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -82,7 +83,7 @@ func TestOpenAITextResponse(t *testing.T) {
 
 	t.Setenv("OPENAI_API_KEY", "test-key-123")
 
-	resp, err := client.GenerateWithTools(context.Background(), "You are helpful", []Message{{Role: "user", Content: "Hi"}}, nil)
+	resp, err := client.GenerateWithTools(context.Background(), "You are helpful", []Message{{Role: "user", Content: "Hi"}}, nil, RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -144,7 +145,7 @@ func TestMistralTextResponse(t *testing.T) {
 
 	t.Setenv("MISTRAL_API_KEY", "mistral-test-key")
 
-	resp, err := client.GenerateWithTools(context.Background(), "Be concise", []Message{{Role: "user", Content: "Hello"}}, nil)
+	resp, err := client.GenerateWithTools(context.Background(), "Be concise", []Message{{Role: "user", Content: "Hello"}}, nil, RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -177,7 +178,7 @@ func TestOllamaTextResponse(t *testing.T) {
 	cfg := providerConfig{name: "ollama", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "llama3")
 
-	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "Hello"}}, nil)
+	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "Hello"}}, nil, RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -211,7 +212,7 @@ func TestOpenRouterTextResponse(t *testing.T) {
 
 	t.Setenv("OPENROUTER_API_KEY", "openrouter-test-key")
 
-	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "Hello"}}, nil)
+	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "Hello"}}, nil, RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -251,7 +252,7 @@ func TestToolSchemaConversion(t *testing.T) {
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
 	tools := testTools()
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, tools)
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, tools, RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -314,7 +315,7 @@ func TestToolCallResponseParsing(t *testing.T) {
 	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
 
-	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "add test"}}, testTools())
+	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "add test"}}, testTools(), RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -347,7 +348,7 @@ func TestCoerceToolCallArgsWithOpenAI(t *testing.T) {
 	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
 
-	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "add"}}, testTools())
+	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "add"}}, testTools(), RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -502,7 +503,7 @@ func TestOpenRouterCustomHeaders(t *testing.T) {
 	client := NewOpenAICompatibleClient(cfg, "anthropic/claude-3-opus")
 	t.Setenv("OPENROUTER_API_KEY", "test-key")
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil)
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil, RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -530,7 +531,7 @@ func TestAuthorizationOmittedWhenEmpty(t *testing.T) {
 	cfg := providerConfig{name: "ollama", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "llama3")
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil)
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil, RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -590,7 +591,7 @@ func TestAPIError401(t *testing.T) {
 	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil)
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil, RequestOptions{})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -619,8 +620,9 @@ func TestAPIError429(t *testing.T) {
 
 	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
+	client.retry = fastRetry
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil)
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil, RequestOptions{})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -630,8 +632,8 @@ func TestAPIError429(t *testing.T) {
 	if !strings.Contains(err.Error(), "Rate limit exceeded") {
 		t.Errorf("error should contain 'Rate limit exceeded': %v", err)
 	}
-	if requestCount != 1 {
-		t.Errorf("expected exactly 1 request (no retry), got %d", requestCount)
+	if requestCount != fastRetry.maxAttempts {
+		t.Errorf("expected %d requests (retried), got %d", fastRetry.maxAttempts, requestCount)
 	}
 }
 
@@ -648,7 +650,7 @@ func TestAPIError404(t *testing.T) {
 	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil)
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil, RequestOptions{})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -672,8 +674,9 @@ func TestAPIError500(t *testing.T) {
 
 	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
+	client.retry = fastRetry
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil)
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil, RequestOptions{})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -690,8 +693,9 @@ func TestAPIError500(t *testing.T) {
 func TestOllamaServerUnreachable(t *testing.T) {
 	cfg := providerConfig{name: "ollama", baseURL: "http://localhost:1/v1", apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "llama3")
+	client.retry = fastRetry
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil)
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil, RequestOptions{})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -712,7 +716,7 @@ func TestMalformedToolCallArguments(t *testing.T) {
 	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, testTools())
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, testTools(), RequestOptions{})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -733,7 +737,7 @@ func TestEmptyChoicesArray(t *testing.T) {
 	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil)
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, nil, RequestOptions{})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -766,7 +770,7 @@ func TestResponseWithBothTextAndToolCall(t *testing.T) {
 	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
 
-	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, testTools())
+	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, testTools(), RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -796,7 +800,7 @@ func TestSystemPromptHandling(t *testing.T) {
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
 
 	// With system prompt
-	client.GenerateWithTools(context.Background(), "You are helpful", []Message{{Role: "user", Content: "Hi"}}, nil)
+	client.GenerateWithTools(context.Background(), "You are helpful", []Message{{Role: "user", Content: "Hi"}}, nil, RequestOptions{})
 
 	var req1 openaiRequest
 	json.Unmarshal(capturedBodies[0], &req1)
@@ -811,7 +815,7 @@ func TestSystemPromptHandling(t *testing.T) {
 	}
 
 	// Without system prompt
-	client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "Hi"}}, nil)
+	client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "Hi"}}, nil, RequestOptions{})
 
 	var req2 openaiRequest
 	json.Unmarshal(capturedBodies[1], &req2)
@@ -846,7 +850,7 @@ func TestEmptyToolSchema(t *testing.T) {
 		},
 	}
 
-	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, tools)
+	_, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, tools, RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -865,6 +869,53 @@ func TestEmptyToolSchema(t *testing.T) {
 	}
 }
 
+func TestToolSchemaConversionPreservesKeywords(t *testing.T) {
+	var capturedBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(textResponse("ok")))
+	}))
+	defer srv.Close()
+
+	schema, err := mcp.ParseSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"mode": {"type": "string", "enum": ["a", "b"], "default": "a"},
+			"ids": {"type": "array", "items": {"type": "integer", "minimum": 1}},
+			"opts": {"type": "object", "properties": {"deep": {"type": "boolean"}}, "additionalProperties": false}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
+	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
+	_, err = client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, []mcp.Tool{{Name: "t", InputSchema: schema}}, RequestOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var reqBody map[string]any
+	json.Unmarshal(capturedBody, &reqBody)
+	params := reqBody["tools"].([]any)[0].(map[string]any)["function"].(map[string]any)["parameters"].(map[string]any)
+	props := params["properties"].(map[string]any)
+
+	mode := props["mode"].(map[string]any)
+	if len(mode["enum"].([]any)) != 2 || mode["default"] != "a" {
+		t.Errorf("mode = %v", mode)
+	}
+	items := props["ids"].(map[string]any)["items"].(map[string]any)
+	if items["type"] != "integer" || items["minimum"] != float64(1) {
+		t.Errorf("ids.items = %v", items)
+	}
+	if opts := props["opts"].(map[string]any); opts["additionalProperties"] != false {
+		t.Errorf("opts = %v", opts)
+	}
+}
+
 // --- E2E-024: Unknown Provider Returns Error ---
 
 func TestUnknownProviderReturnsError(t *testing.T) {
@@ -917,7 +968,7 @@ func TestNestedToolCallArguments(t *testing.T) {
 	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
 	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
 
-	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, tools)
+	resp, err := client.GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "test"}}, tools, RequestOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -959,3 +1010,294 @@ func TestLazyValidation(t *testing.T) {
 		t.Errorf("expected *OpenAICompatibleClient, got %T", client)
 	}
 }
+
+// --- E2E-027: Native Tool Call and Tool Result Messages ---
+
+func TestToolMessagesConversion(t *testing.T) {
+	var capturedBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(toolCallResponse("resources_add", `{"name":"a","value":"b"}`)))
+	}))
+	defer srv.Close()
+
+	cfg := providerConfig{name: "openai", baseURL: srv.URL, apiKeyEnv: ""}
+	client := NewOpenAICompatibleClient(cfg, "gpt-4o")
+
+	resp, err := client.GenerateWithTools(context.Background(), "", toolExchangeMessages(), testTools(), RequestOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ToolCall == nil || resp.ToolCall.ID != "call_1" {
+		t.Fatalf("expected tool call with ID call_1, got %+v", resp.ToolCall)
+	}
+
+	var reqBody openaiRequest
+	if err := json.Unmarshal(capturedBody, &reqBody); err != nil {
+		t.Fatalf("failed to parse request body: %v", err)
+	}
+	if len(reqBody.Messages) != 4 {
+		t.Fatalf("got %d messages, want 4", len(reqBody.Messages))
+	}
+
+	call := reqBody.Messages[1]
+	if call.Role != "assistant" || len(call.ToolCalls) != 1 {
+		t.Fatalf("messages[1] = %+v, want assistant with 1 tool call", call)
+	}
+	if call.ToolCalls[0].ID != "call_1" || call.ToolCalls[0].Function.Name != "resources_list" {
+		t.Errorf("tool call = %+v, want call_1/resources_list", call.ToolCalls[0])
+	}
+	if call.ToolCalls[0].Function.Arguments != `{"pattern":"db"}` {
+		t.Errorf("tool call arguments = %s, want {\"pattern\":\"db\"}", call.ToolCalls[0].Function.Arguments)
+	}
+
+	result := reqBody.Messages[2]
+	if result.Role != "tool" || result.ToolCallID != "call_1" || result.Content != "db-1, db-2" {
+		t.Errorf("messages[2] = %+v, want tool/call_1/db-1, db-2", result)
+	}
+}
+
+func TestOpenAIGenerationOptions(t *testing.T) {
+	var capturedBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(textResponse("ok")))
+	}))
+	defer srv.Close()
+
+	client := newTestClient(providers["ollama"], "llama3", srv.URL)
+	messages := []Message{{Role: "user", Content: "Hi"}}
+	zero, topP := 0.0, 0.9
+
+	for _, tt := range []struct {
+		name string
+		opts RequestOptions
+		want map[string]any // fields of the request body, nil when absent
+	}{
+		{
+			name: "defaults",
+			want: map[string]any{"max_tokens": nil, "temperature": nil, "top_p": nil, "stop": nil, "response_format": nil},
+		},
+		{
+			name: "all options",
+			opts: RequestOptions{Temperature: &zero, TopP: &topP, MaxTokens: 200, StopSequences: []string{"END"}},
+			want: map[string]any{"max_tokens": 200.0, "temperature": 0.0, "top_p": 0.9, "stop": []any{"END"}},
+		},
+		{
+			name: "json mode",
+			opts: RequestOptions{JSON: true},
+			want: map[string]any{"response_format": map[string]any{"type": "json_object"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.GenerateWithTools(context.Background(), "", messages, nil, tt.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(capturedBody, &body); err != nil {
+				t.Fatal(err)
+			}
+			for field, want := range tt.want {
+				if got := body[field]; !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %#v, want %#v", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterProvider(t *testing.T) {
+	var capturedReq *http.Request
+	var capturedBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedReq = r
+		capturedBody = nil
+		json.NewDecoder(r.Body).Decode(&capturedBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(textResponse("ok")))
+	}))
+	defer srv.Close()
+	t.Cleanup(func() {
+		providersMu.Lock()
+		delete(providers, "vllm")
+		delete(providers, "gateway")
+		providersMu.Unlock()
+	})
+	t.Setenv("VLLM_API_KEY", "vllm-key")
+
+	if err := RegisterProvider(Provider{Name: "vllm", BaseURL: srv.URL + "/", APIKeyEnv: "VLLM_API_KEY", Headers: map[string]string{"X-Team": "platform"}, Tools: true}); err != nil {
+		t.Fatalf("RegisterProvider(vllm) error: %v", err)
+	}
+	if err := RegisterProvider(Provider{Name: "gateway", BaseURL: srv.URL, JSONMode: true}); err != nil {
+		t.Fatalf("RegisterProvider(gateway) error: %v", err)
+	}
+
+	tools := []mcp.Tool{{Name: "list", Description: "List items"}}
+	messages := []Message{{Role: "user", Content: "Hi"}}
+	opts := RequestOptions{JSON: true}
+
+	t.Run("custom prefix with tools", func(t *testing.T) {
+		client, err := NewClient("vllm:qwen2.5-7b")
+		if err != nil {
+			t.Fatalf("NewClient() error: %v", err)
+		}
+		if _, err := client.GenerateWithTools(context.Background(), "", messages, tools, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if capturedReq.URL.Path != "/chat/completions" {
+			t.Errorf("path = %q, want /chat/completions", capturedReq.URL.Path)
+		}
+		if got := capturedReq.Header.Get("Authorization"); got != "Bearer vllm-key" {
+			t.Errorf("Authorization = %q, want Bearer vllm-key", got)
+		}
+		if got := capturedReq.Header.Get("X-Team"); got != "platform" {
+			t.Errorf("X-Team = %q, want platform", got)
+		}
+		if capturedBody["model"] != "qwen2.5-7b" || capturedBody["tools"] == nil {
+			t.Errorf("body = %v, want model qwen2.5-7b with tools", capturedBody)
+		}
+		if _, ok := capturedBody["response_format"]; ok {
+			t.Error("response_format sent to a provider without JSON mode")
+		}
+	})
+
+	t.Run("no tools, json mode", func(t *testing.T) {
+		client, err := NewClient("gateway:internal-model")
+		if err != nil {
+			t.Fatalf("NewClient() error: %v", err)
+		}
+		if _, err := client.GenerateWithTools(context.Background(), "", messages, tools, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := capturedBody["tools"]; ok {
+			t.Error("tools sent to a provider without tool support")
+		}
+		if got := capturedBody["response_format"]; !reflect.DeepEqual(got, map[string]any{"type": "json_object"}) {
+			t.Errorf("response_format = %v, want json_object", got)
+		}
+	})
+}
+
+func TestRegisterProvider_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		wantErr  string
+	}{
+		{"empty name", Provider{BaseURL: "http://localhost"}, "invalid provider name"},
+		{"colon in name", Provider{Name: "a:b", BaseURL: "http://localhost"}, "invalid provider name"},
+		{"reserved name", Provider{Name: "anthropic", BaseURL: "http://localhost"}, "reserved"},
+		{"no base URL", Provider{Name: "vllm"}, "base URL is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RegisterProvider(tt.provider); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenAIPromptCaching(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":1500,"completion_tokens":3,"prompt_tokens_details":{"cached_tokens":1024}}}`))
+	}))
+	defer srv.Close()
+
+	tools := []mcp.Tool{{Name: "list", Description: "List items"}}
+	messages := []Message{{Role: "user", Content: "Hi"}}
+	opts := RequestOptions{CachePrompt: true}
+
+	openai := newTestClient(providers["openai"], "gpt-4o", srv.URL)
+	resp, err := openai.GenerateWithTools(context.Background(), "prompt", messages, tools, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	key, _ := body["prompt_cache_key"].(string)
+	if key == "" {
+		t.Fatal("prompt_cache_key not sent")
+	}
+	want := &Usage{InputTokens: 1500, OutputTokens: 3, CacheReadTokens: 1024}
+	if !reflect.DeepEqual(resp.Usage, want) {
+		t.Errorf("Usage = %+v, want %+v", resp.Usage, want)
+	}
+
+	// Same prefix, same key; another system prompt, another key
+	if _, err := openai.GenerateWithTools(context.Background(), "prompt", append(messages, Message{Role: "model", Content: "ok"}), tools, opts); err != nil {
+		t.Fatal(err)
+	}
+	if body["prompt_cache_key"] != key {
+		t.Errorf("prompt_cache_key = %v, want %v for the same prefix", body["prompt_cache_key"], key)
+	}
+	if _, err := openai.GenerateWithTools(context.Background(), "other prompt", messages, tools, opts); err != nil {
+		t.Fatal(err)
+	}
+	if body["prompt_cache_key"] == key {
+		t.Error("prompt_cache_key unchanged for another system prompt")
+	}
+
+	// Providers without prompt_cache_key support do not get it
+	ollama := newTestClient(providers["ollama"], "llama3", srv.URL)
+	if _, err := ollama.GenerateWithTools(context.Background(), "prompt", messages, tools, opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["prompt_cache_key"]; ok {
+		t.Error("prompt_cache_key sent to ollama")
+	}
+}
+
+func TestToOpenAIMessagesAttachments(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "Hi"},
+		{Role: "user", Content: "What is this?", Attachments: testAttachments()},
+	}
+
+	tests := []struct {
+		name   string
+		vision bool
+		want   []openaiContentPart
+	}{
+		{
+			name:   "vision",
+			vision: true,
+			want: []openaiContentPart{
+				{Type: "image_url", ImageURL: &openaiImageURL{URL: "data:image/png;base64,aGVsbG8="}},
+				{Type: "file", File: &openaiFile{Filename: "report.pdf", FileData: "data:application/pdf;base64,JVBERi0="}},
+				{Type: "text", Text: "File notes.txt:\nhello"},
+				{Type: "text", Text: "[Attachment archive.zip (application/zip) not sent: unsupported file type]"},
+				{Type: "text", Text: "What is this?"},
+			},
+		},
+		{
+			name: "text only",
+			want: []openaiContentPart{
+				{Type: "text", Text: "[Attachment screen.png (image/png) not sent: the provider does not accept images or files]"},
+				{Type: "text", Text: "[Attachment report.pdf (application/pdf) not sent: the provider does not accept images or files]"},
+				{Type: "text", Text: "File notes.txt:\nhello"},
+				{Type: "text", Text: "[Attachment archive.zip (application/zip) not sent: unsupported file type]"},
+				{Type: "text", Text: "What is this?"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, err := toOpenAIMessages(messages, tt.vision)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if msgs[0].Content != "Hi" {
+				t.Errorf("messages[0].Content = %#v, want plain string", msgs[0].Content)
+			}
+			if got := msgs[1].Content; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages[1].Content = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"agent-stop-and-go/internal/mcp"
+)
+
+// tokenBucket holds up to capacity units and refills at rate units per second.
+// Its level goes negative when callers reserve more than it holds: they wait
+// in turn until the bucket has refilled.
+type tokenBucket struct {
+	capacity float64
+	rate     float64
+	level    float64
+	last     time.Time
+}
+
+// newTokenBucket returns a full bucket allowing perMinute units per minute,
+// or nil (no limit) if perMinute is 0.
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		level:    float64(perMinute),
+		last:     time.Now(),
+	}
+}
+
+// reserve takes n units and returns how long to wait until they are available.
+// n is capped at the capacity so that large requests are not blocked forever.
+func (b *tokenBucket) reserve(now time.Time, n float64) (float64, time.Duration) {
+	if b == nil {
+		return 0, 0
+	}
+	b.level = min(b.capacity, b.level+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	n = min(n, b.capacity)
+	b.level -= n
+	if b.level >= 0 {
+		return n, 0
+	}
+	return n, time.Duration(-b.level / b.rate * float64(time.Second))
+}
+
+// cancel returns the units of a reservation that was not used.
+func (b *tokenBucket) cancel(n float64) {
+	if b != nil {
+		b.level = min(b.capacity, b.level+n)
+	}
+}
+
+// RateLimiter limits the requests and tokens sent to a provider per minute.
+// Callers are served in the order they arrive.
+type RateLimiter struct {
+	mu       sync.Mutex
+	requests *tokenBucket
+	tokens   *tokenBucket
+}
+
+// NewRateLimiter returns a limiter allowing requestsPerMinute requests and
+// tokensPerMinute input tokens per minute. A limit of 0 is no limit.
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	return &RateLimiter{
+		requests: newTokenBucket(requestsPerMinute),
+		tokens:   newTokenBucket(tokensPerMinute),
+	}
+}
+
+// Wait blocks until a request of tokens input tokens may be sent, or ctx ends.
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	l.mu.Lock()
+	now := time.Now()
+	reqs, reqWait := l.requests.reserve(now, 1)
+	toks, tokWait := l.tokens.reserve(now, float64(tokens))
+	l.mu.Unlock()
+
+	wait := max(reqWait, tokWait)
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.requests.cancel(reqs)
+		l.tokens.cancel(toks)
+		l.mu.Unlock()
+		return fmt.Errorf("rate limit wait: %w", ctx.Err())
+	}
+}
+
+// RateLimitedClient waits for its provider's rate limiter before each request.
+type RateLimitedClient struct {
+	client  Client
+	limiter *RateLimiter
+}
+
+// NewRateLimitedClient returns client limited by limiter. Clients of the same
+// provider share its limiter.
+func NewRateLimitedClient(client Client, limiter *RateLimiter) *RateLimitedClient {
+	return &RateLimitedClient{client: client, limiter: limiter}
+}
+
+// GenerateWithTools waits for the limiter, then sends the request. Retries
+// of the request by the provider wait for the limiter again.
+func (c *RateLimitedClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error) {
+	tokens := EstimateTokens(systemPrompt, messages, tools)
+	if err := c.limiter.Wait(ctx, tokens); err != nil {
+		return nil, err
+	}
+	ctx = withRetryWait(ctx, func(ctx context.Context) error { return c.limiter.Wait(ctx, tokens) })
+	return c.client.GenerateWithTools(ctx, systemPrompt, messages, tools, opts)
+}
+
+type retryWaitKey struct{}
+
+// withRetryWait returns a context whose retried requests call wait before
+// they are sent.
+func withRetryWait(ctx context.Context, wait func(context.Context) error) context.Context {
+	return context.WithValue(ctx, retryWaitKey{}, wait)
+}
+
+// retryWaitFromContext returns the function set by withRetryWait, or nil.
+func retryWaitFromContext(ctx context.Context) func(context.Context) error {
+	wait, _ := ctx.Value(retryWaitKey{}).(func(context.Context) error)
+	return wait
+}
+
+// imageTokens is the estimated cost of an image: providers scale images
+// down to about 1,600 tokens at most.
+const imageTokens = 1600
+
+// EstimateTokens estimates the input tokens of a request at 4 characters per
+// token, which is close enough for rate limiting and context budgets. Images,
+// in attachments or tool results, count imageTokens each; other attachments
+// count their decoded size.
+func EstimateTokens(systemPrompt string, messages []Message, tools []mcp.Tool) int {
+	chars := len(systemPrompt)
+	images := 0
+	for _, m := range messages {
+		chars += len(m.Content)
+		for _, a := range m.Attachments {
+			if a.kind() == attachmentImage {
+				images++
+			} else {
+				chars += base64.StdEncoding.DecodedLen(len(a.Data))
+			}
+		}
+		for _, tc := range m.ToolCalls {
+			args, _ := json.Marshal(tc.Arguments)
+			chars += len(tc.Name) + len(args)
+		}
+		if m.ToolResult != nil {
+			// Content already renders the other blocks as text
+			chars += len(m.ToolResult.Content)
+			for _, b := range m.ToolResult.Blocks {
+				if b.Type == "image" {
+					images++
+				}
+			}
+		}
+	}
+	for _, t := range tools {
+		schema, _ := json.Marshal(t.InputSchema)
+		chars += len(t.Name) + len(t.Description) + len(schema)
+	}
+	return chars/4 + 1 + images*imageTokens
+}
@@ -0,0 +1,241 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"agent-stop-and-go/internal/mcp"
+)
+
+func TestTokenBucket_Reserve(t *testing.T) {
+	start := time.Now()
+	b := newTokenBucket(60) // 1 per second, burst of 60
+	b.last = start
+
+	tests := []struct {
+		name     string
+		at       time.Duration
+		n        float64
+		wantWait time.Duration
+	}{
+		{"within burst", 0, 59, 0},
+		{"last unit of burst", 0, 1, 0},
+		{"empty bucket", 0, 1, time.Second},
+		{"queued after previous reservation", 0, 2, 3 * time.Second},
+		{"refilled meanwhile", 5 * time.Second, 1, 0},
+		{"capped at capacity", 5 * time.Second, 1000, 59 * time.Second},
+	}
+	for _, tt := range tests {
+		_, wait := b.reserve(start.Add(tt.at), tt.n)
+		if diff := wait - tt.wantWait; diff < -time.Millisecond || diff > time.Millisecond {
+			t.Errorf("%s: wait = %s, want %s", tt.name, wait, tt.wantWait)
+		}
+	}
+
+	var none *tokenBucket
+	if _, wait := none.reserve(start, 1000); wait != 0 {
+		t.Errorf("nil bucket wait = %s, want 0", wait)
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	t.Run("requests per minute", func(t *testing.T) {
+		l := NewRateLimiter(600, 0) // one request every 100ms after a burst of 600
+		l.requests.level = 1
+
+		start := time.Now()
+		for range 3 {
+			if err := l.Wait(context.Background(), 1_000_000); err != nil {
+				t.Fatalf("Wait() error: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+			t.Errorf("3 requests took %s, want at least 200ms", elapsed)
+		}
+	})
+
+	t.Run("tokens per minute", func(t *testing.T) {
+		l := NewRateLimiter(0, 60_000) // 1000 tokens per second
+		l.tokens.level = 100
+
+		start := time.Now()
+		if err := l.Wait(context.Background(), 100); err != nil {
+			t.Fatalf("Wait() error: %v", err)
+		}
+		if err := l.Wait(context.Background(), 200); err != nil {
+			t.Fatalf("Wait() error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+			t.Errorf("waited %s, want at least 200ms", elapsed)
+		}
+	})
+
+	t.Run("canceled while queued", func(t *testing.T) {
+		l := NewRateLimiter(60, 0)
+		l.requests.level = 0
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := l.Wait(ctx, 1)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Wait() error = %v, want deadline exceeded", err)
+		}
+		// The canceled reservation is returned to the bucket
+		if l.requests.level < -0.01 {
+			t.Errorf("level = %g after cancellation, want about 0", l.requests.level)
+		}
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		l := NewRateLimiter(0, 0)
+		for range 1000 {
+			if err := l.Wait(context.Background(), 1_000_000); err != nil {
+				t.Fatalf("Wait() error: %v", err)
+			}
+		}
+	})
+}
+
+// countingClient counts its requests.
+type countingClient struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *countingClient) GenerateWithTools(ctx context.Context, systemPrompt string, messages []Message, tools []mcp.Tool, opts RequestOptions) (*Response, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	return &Response{Text: "ok"}, nil
+}
+
+func TestRateLimitedClient_SharedLimiter(t *testing.T) {
+	limiter := NewRateLimiter(600, 0)
+	limiter.requests.level = 2
+
+	a, b := &countingClient{}, &countingClient{}
+	clients := []Client{NewRateLimitedClient(a, limiter), NewRateLimitedClient(b, limiter)}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := clients[i%2].GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "hi"}}, nil, RequestOptions{}); err != nil {
+				t.Errorf("GenerateWithTools() error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if a.calls+b.calls != 4 {
+		t.Errorf("calls = %d, want 4", a.calls+b.calls)
+	}
+	// 2 requests from the burst, then one every 100ms across both clients
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("4 requests took %s, want at least 200ms", elapsed)
+	}
+}
+
+func TestRateLimitedClient_RetriesCharged(t *testing.T) {
+	var mu sync.Mutex
+	var sent []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, time.Now())
+		first := len(sent) == 1
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	client := NewOpenAICompatibleClient(providerConfig{name: "openai", baseURL: srv.URL}, "gpt-4o")
+	client.retry = fastRetry
+	limiter := NewRateLimiter(600, 0) // one request every 100ms
+	limiter.requests.level = 1
+
+	resp, err := NewRateLimitedClient(client, limiter).GenerateWithTools(context.Background(), "", []Message{{Role: "user", Content: "hi"}}, nil, RequestOptions{})
+	if err != nil {
+		t.Fatalf("GenerateWithTools() error: %v", err)
+	}
+	if resp.Text != "ok" || len(sent) != 2 {
+		t.Fatalf("response = %+v after %d requests, want ok after a retry", resp, len(sent))
+	}
+	// The burst allows the first attempt only: the retry waits for the limiter
+	if gap := sent[1].Sub(sent[0]); gap < 90*time.Millisecond {
+		t.Errorf("retry sent %s after the first attempt, want it to wait for the limiter", gap)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tools := []mcp.Tool{{Name: "list", Description: "List items"}}
+	messages := []Message{
+		{Role: "user", Content: "hello world"},
+		{Role: "model", ToolCalls: []ToolCall{{Name: "list", Arguments: map[string]any{"all": true}}}},
+		{Role: "tool", ToolResult: &ToolResult{Name: "list", Content: "a, b, c"}},
+	}
+
+	short := EstimateTokens("", messages[:1], nil)
+	long := EstimateTokens("You are a helpful assistant.", messages, tools)
+	if short < 1 || long <= short {
+		t.Errorf("EstimateTokens = %d (short), %d (long), want 0 < short < long", short, long)
+	}
+}
+
+func TestEstimateTokens_Media(t *testing.T) {
+	text := Message{Role: "user", Content: "describe"}
+	base := EstimateTokens("", []Message{text}, nil)
+
+	// 4 KiB of text, base64-encoded
+	file := base64.StdEncoding.EncodeToString(make([]byte, 4096))
+
+	tests := []struct {
+		name    string
+		message Message
+		want    int // tokens on top of base
+	}{
+		{
+			name:    "image attachment",
+			message: Message{Role: "user", Content: "describe", Attachments: []Attachment{{MimeType: "image/png", Data: file}}},
+			want:    imageTokens,
+		},
+		{
+			name:    "text attachment",
+			message: Message{Role: "user", Content: "describe", Attachments: []Attachment{{MimeType: "text/plain", Data: file}}},
+			want:    1024,
+		},
+		{
+			name:    "pdf attachment",
+			message: Message{Role: "user", Content: "describe", Attachments: []Attachment{{MimeType: "application/pdf", Data: file}}},
+			want:    1024,
+		},
+		{
+			name: "tool result images",
+			message: Message{Role: "tool", ToolResult: &ToolResult{Content: "describe", Blocks: []mcp.ContentBlock{
+				{Type: "text", Text: "describe"},
+				{Type: "image", MimeType: "image/png", Data: file},
+				{Type: "image", MimeType: "image/jpeg", Data: file},
+			}}},
+			want: 2 * imageTokens,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateTokens("", []Message{tt.message}, nil) - base; got != tt.want {
+				t.Errorf("EstimateTokens() = base + %d, want base + %d", got, tt.want)
+			}
+		})
+	}
+}
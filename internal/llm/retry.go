@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy controls how the requests of a provider are retried on rate
+// limits, server errors and network failures.
+type retryPolicy struct {
+	maxAttempts int           // attempts per call, including the first one
+	baseDelay   time.Duration // delay before the first retry, doubled on each retry
+	maxDelay    time.Duration // cap on the backoff delay (not on Retry-After)
+	maxElapsed  time.Duration // deadline of a call and its retries, unless the context ends earlier
+}
+
+// defaultRetryPolicy is the retry policy of all providers.
+var defaultRetryPolicy = retryPolicy{
+	maxAttempts: 4,
+	baseDelay:   500 * time.Millisecond,
+	maxDelay:    10 * time.Second,
+	maxElapsed:  2 * time.Minute,
+}
+
+// retryableStatus reports whether a response status may succeed later:
+// 408, 429 and 5xx (including Anthropic's 529 "overloaded").
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// do sends req and returns the response with its body, retrying with
+// exponential backoff and jitter while the request fails with a retryable
+// status or a network error. A Retry-After header replaces the backoff delay,
+// and retries then wait for the rate limiter set by withRetryWait, if any.
+// Retries stop at maxElapsed or the deadline of the request's context: the
+// last response or error is then returned. The returned response's body is
+// already read and closed. Callers handle non-2xx statuses.
+func (p retryPolicy) do(client *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), p.maxElapsed)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		r := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create request: %w", err)
+			}
+			r.Body = body
+		}
+
+		resp, body, err := send(client, r)
+		var wait time.Duration
+		var reason string
+		switch {
+		case err != nil:
+			if !IsRetryable(err) || ctx.Err() != nil || attempt == p.maxAttempts {
+				return nil, nil, err
+			}
+			wait, reason = p.backoff(attempt), err.Error()
+		case retryableStatus(resp.StatusCode) && attempt < p.maxAttempts:
+			wait, reason = p.backoff(attempt), resp.Status
+			if d, ok := retryAfter(resp.Header); ok {
+				wait = d
+			}
+		default:
+			return resp, body, nil
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			if err != nil {
+				return nil, nil, err
+			}
+			return resp, body, nil
+		}
+		log.Printf("WARN: LLM request to %s failed (%s), retrying in %s (attempt %d/%d)",
+			req.URL.Host, reason, wait.Round(time.Millisecond), attempt+1, p.maxAttempts)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if err != nil {
+				return nil, nil, err
+			}
+			return resp, body, nil
+		case <-timer.C:
+		}
+
+		// Retries are charged to the provider's rate limits like first attempts
+		if limit := retryWaitFromContext(ctx); limit != nil {
+			if limit(ctx) != nil {
+				if err != nil {
+					return nil, nil, err
+				}
+				return resp, body, nil
+			}
+		}
+	}
+}
+
+// send sends one attempt of a request and reads its response.
+func send(client *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp, body, nil
+}
+
+// backoff returns the delay before the retry following attempt: baseDelay
+// doubled on each attempt, capped at maxDelay, with up to 50% of jitter.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.baseDelay
+	for i := 1; i < attempt && d < p.maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.maxDelay)
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date.
+func retryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,220 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fastRetry is a retry policy with short delays for tests.
+var fastRetry = retryPolicy{
+	maxAttempts: 4,
+	baseDelay:   time.Millisecond,
+	maxDelay:    5 * time.Millisecond,
+	maxElapsed:  5 * time.Second,
+}
+
+// newRetryServer returns a server answering each request with the next of
+// statuses (the last one repeats) and the headers of that step, and
+// recording the request bodies it receives.
+func newRetryServer(t *testing.T, statuses []int, headers []http.Header) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		i := min(len(bodies), len(statuses)-1)
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+
+		if i < len(headers) {
+			for k, v := range headers[i] {
+				w.Header()[k] = v
+			}
+		}
+		w.WriteHeader(statuses[i])
+		w.Write([]byte(http.StatusText(statuses[i])))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), bodies...)
+	}
+}
+
+func newRetryRequest(t *testing.T, ctx context.Context, url string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader([]byte(`{"q":1}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		wantStatus int
+		wantCalls  int
+	}{
+		{"success", []int{200}, 200, 1},
+		{"rate limited then success", []int{429, 200}, 200, 2},
+		{"server errors then success", []int{500, 502, 503, 200}, 200, 4},
+		{"overloaded until max attempts", []int{529}, 529, 4},
+		{"request timeout then success", []int{408, 200}, 200, 2},
+		{"client error is not retried", []int{400}, 400, 1},
+		{"unauthorized is not retried", []int{401, 200}, 401, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, bodies := newRetryServer(t, tt.statuses, nil)
+
+			resp, body, err := fastRetry.do(srv.Client(), newRetryRequest(t, context.Background(), srv.URL))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus || string(body) != http.StatusText(tt.wantStatus) {
+				t.Errorf("response = %d %q, want %d", resp.StatusCode, body, tt.wantStatus)
+			}
+			got := bodies()
+			if len(got) != tt.wantCalls {
+				t.Fatalf("got %d requests, want %d", len(got), tt.wantCalls)
+			}
+			for i, b := range got {
+				if b != `{"q":1}` {
+					t.Errorf("request %d body = %q, want the original body", i, b)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Do_RetryAfter(t *testing.T) {
+	srv, bodies := newRetryServer(t, []int{429, 200}, []http.Header{{"Retry-After": {"1"}}})
+
+	start := time.Now()
+	resp, _, err := fastRetry.do(srv.Client(), newRetryRequest(t, context.Background(), srv.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 200 || len(bodies()) != 2 {
+		t.Fatalf("got %d after %d requests, want 200 after 2", resp.StatusCode, len(bodies()))
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the 1s of Retry-After", elapsed)
+	}
+}
+
+func TestRetryPolicy_Do_Deadline(t *testing.T) {
+	t.Run("Retry-After beyond the context deadline", func(t *testing.T) {
+		srv, bodies := newRetryServer(t, []int{429, 200}, []http.Header{{"Retry-After": {"60"}}})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		start := time.Now()
+		resp, _, err := fastRetry.do(srv.Client(), newRetryRequest(t, ctx, srv.URL))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.StatusCode != 429 || len(bodies()) != 1 {
+			t.Errorf("got %d after %d requests, want 429 after 1", resp.StatusCode, len(bodies()))
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("gave up after %s, want immediately", elapsed)
+		}
+	})
+
+	t.Run("backoff beyond maxElapsed", func(t *testing.T) {
+		srv, bodies := newRetryServer(t, []int{503}, nil)
+		policy := retryPolicy{maxAttempts: 10, baseDelay: 40 * time.Millisecond, maxDelay: time.Second, maxElapsed: 200 * time.Millisecond}
+
+		resp, _, err := policy.do(srv.Client(), newRetryRequest(t, context.Background(), srv.URL))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.StatusCode != 503 {
+			t.Errorf("status = %d, want 503", resp.StatusCode)
+		}
+		if n := len(bodies()); n < 2 || n >= 10 {
+			t.Errorf("got %d requests, want retries stopped by maxElapsed", n)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		srv, bodies := newRetryServer(t, []int{503}, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, _, err := fastRetry.do(srv.Client(), newRetryRequest(t, ctx, srv.URL)); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if n := len(bodies()); n != 0 {
+			t.Errorf("got %d requests, want 0", n)
+		}
+	})
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"absent", "", 0, false},
+		{"seconds", "3", 3 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"past date", "Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+		{"invalid", "soon", 0, false},
+		{"negative", "-1", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.value != "" {
+				h.Set("Retry-After", tt.value)
+			}
+			got, ok := retryAfter(h)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	h := http.Header{}
+	h.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if got, ok := retryAfter(h); !ok || got < 59*time.Minute || got > time.Hour {
+		t.Errorf("retryAfter(date in 1h) = %s, %v, want about 1h", got, ok)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := retryPolicy{baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{5, 500 * time.Millisecond, time.Second},
+		{70, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if d := p.backoff(tt.attempt); d < tt.min || d > tt.max {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
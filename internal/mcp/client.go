@@ -2,14 +2,30 @@ package mcp
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
+
+	"agent-stop-and-go/internal/auth"
 )
 
+// defaultShutdownTimeout is the default grace period of each stdio shutdown step.
+const defaultShutdownTimeout = 5 * time.Second
+
+// samplingTimeout bounds the completion of a stdio server's sampling request.
+const samplingTimeout = 2 * time.Minute
+
 // Client is the interface for MCP communication.
 type Client interface {
 	Start() error
@@ -17,13 +33,34 @@ type Client interface {
 	Tools() []Tool
 	GetTool(name string) *Tool
 	CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error)
+	ListResources(ctx context.Context) ([]Resource, error)
+	ReadResource(ctx context.Context, uri string) (*ReadResourceResult, error)
+	ListPrompts(ctx context.Context) ([]Prompt, error)
+	GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error)
+	// RefreshTools re-lists the server's tools.
+	RefreshTools(ctx context.Context) error
+	// OnToolsChanged registers fn to be called when the server reports a changed tool list.
+	// fn must not block: it may be called while a request is in flight.
+	OnToolsChanged(fn func())
+	// Ping checks that the server is reachable and responding.
+	Ping(ctx context.Context) error
+	// Restart reconnects to the server (respawning stdio processes) and re-lists its tools.
+	Restart() error
 }
 
 // ClientConfig holds configuration for creating an MCP client.
 type ClientConfig struct {
-	URL     string   // Streamable HTTP endpoint
-	Command string   // stdio subprocess command
-	Args    []string // stdio subprocess args
+	Name            string            // server name, prefixes the server's stderr lines and log messages in the log
+	URL             string            // Streamable HTTP endpoint
+	Auth            auth.Outbound     // HTTP headers and credentials
+	Command         string            // stdio subprocess command
+	Args            []string          // stdio subprocess args
+	Env             map[string]string // stdio subprocess environment, on top of the base environment
+	EnvPassthrough  []string          // agent environment variables passed to the stdio subprocess
+	Dir             string            // stdio subprocess working directory
+	ShutdownTimeout time.Duration     // grace period of each stdio shutdown step (default 5s)
+	Sampling        SamplingHandler   // answers the server's sampling requests; nil disables sampling
+	Optional        bool              // connect once on Start instead of retrying; reconnection is left to the caller
 }
 
 // NewClient creates a Client based on config.
@@ -31,10 +68,25 @@ type ClientConfig struct {
 // If neither is set, returns a no-op client (for agents that only use A2A).
 func NewClient(cfg ClientConfig) (Client, error) {
 	if cfg.URL != "" {
-		return NewHTTPClient(cfg.URL), nil
+		c := NewHTTPClient(cfg.URL)
+		c.name = cfg.Name
+		c.outbound = cfg.Auth
+		c.sampling = cfg.Sampling
+		if cfg.Optional {
+			c.connectAttempts = 1
+		}
+		return c, nil
 	}
 	if cfg.Command != "" {
-		return NewStdioClient(cfg.Command, cfg.Args), nil
+		c := NewStdioClient(cfg.Command, cfg.Args)
+		c.name = cfg.Name
+		c.env = stdioEnv(os.Environ(), cfg.EnvPassthrough, cfg.Env)
+		c.dir = cfg.Dir
+		c.sampling = cfg.Sampling
+		if cfg.ShutdownTimeout > 0 {
+			c.shutdownTimeout = cfg.ShutdownTimeout
+		}
+		return c, nil
 	}
 	return &NopClient{}, nil
 }
@@ -49,23 +101,54 @@ func (c *NopClient) GetTool(string) *Tool { return nil }
 func (c *NopClient) CallTool(_ context.Context, _ string, _ map[string]any) (*CallToolResult, error) {
 	return nil, fmt.Errorf("no MCP server configured")
 }
+func (c *NopClient) ListResources(context.Context) ([]Resource, error) { return nil, nil }
+func (c *NopClient) ReadResource(context.Context, string) (*ReadResourceResult, error) {
+	return nil, fmt.Errorf("no MCP server configured")
+}
+func (c *NopClient) ListPrompts(context.Context) ([]Prompt, error) { return nil, nil }
+func (c *NopClient) RefreshTools(context.Context) error            { return nil }
+func (c *NopClient) OnToolsChanged(func())                         {}
+func (c *NopClient) Ping(context.Context) error                    { return nil }
+func (c *NopClient) Restart() error                                { return nil }
+func (c *NopClient) GetPrompt(context.Context, string, map[string]string) (*GetPromptResult, error) {
+	return nil, fmt.Errorf("no MCP server configured")
+}
 
 // StdioClient manages communication with an MCP server over stdio.
+// Server notifications are read while waiting for responses, so a tool list
+// change is only noticed on the next request.
 type StdioClient struct {
-	cmd     *exec.Cmd
-	stdin   io.WriteCloser
-	stdout  *bufio.Reader
-	tools   []Tool
-	mu      sync.Mutex
-	nextID  int
-	started bool
+	command         string
+	args            []string
+	name            string
+	env             []string // nil inherits the agent's environment
+	dir             string
+	shutdownTimeout time.Duration
+	cmd             *exec.Cmd
+	exited          chan struct{} // closed when the current process exits
+	stdin           io.WriteCloser
+	stdout          *bufio.Reader
+	tools           []Tool
+	caps            ServerCapabilities
+	mu              sync.Mutex
+	rpcMu           sync.Mutex // serializes request/response exchanges on the pipes
+	writeMu         sync.Mutex // serializes writes to stdin
+	nextID          int
+	started         bool
+	notifyMu        sync.Mutex
+	onChange        []func()
+	progress        progressTracker
+	sampling        SamplingHandler
 }
 
-// NewStdioClient creates a new stdio MCP client.
+// NewStdioClient creates a new stdio MCP client. The process inherits the
+// agent's environment and working directory; use NewClient to restrict them.
 func NewStdioClient(command string, args []string) *StdioClient {
 	return &StdioClient{
-		cmd:    exec.Command(command, args...),
-		nextID: 1,
+		command:         command,
+		args:            args,
+		shutdownTimeout: defaultShutdownTimeout,
+		nextID:          1,
 	}
 }
 
@@ -78,33 +161,54 @@ func (c *StdioClient) Start() error {
 		return nil
 	}
 
-	stdin, err := c.cmd.StdinPipe()
+	// A process can only be started once: build a fresh command on every start
+	cmd := exec.Command(c.command, c.args...)
+	cmd.Env = c.env
+	cmd.Dir = c.dir
+	stderr := newStderrLogger(c.logPrefix())
+	cmd.Stderr = stderr
+	// Do not wait forever for stderr when a grandchild process keeps it open
+	cmd.WaitDelay = time.Second
+
+	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return fmt.Errorf("failed to get stdin pipe: %w", err)
 	}
-	c.stdin = stdin
 
-	stdout, err := c.cmd.StdoutPipe()
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return fmt.Errorf("failed to get stdout pipe: %w", err)
 	}
-	c.stdout = bufio.NewReader(stdout)
 
-	if err := c.cmd.Start(); err != nil {
+	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start MCP server: %w", err)
 	}
 
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		stderr.Flush()
+		close(exited)
+	}()
+
+	c.rpcMu.Lock()
+	c.cmd = cmd
+	c.stdin = stdin
+	c.stdout = bufio.NewReader(stdout)
+	c.exited = exited
+	c.rpcMu.Unlock()
+
 	c.started = true
 
 	// Initialize the connection
 	if err := c.initialize(); err != nil {
-		c.Stop()
+		c.stopLocked()
 		return fmt.Errorf("failed to initialize MCP connection: %w", err)
 	}
 
 	// Load available tools
 	if err := c.loadTools(); err != nil {
-		c.Stop()
+		c.stopLocked()
 		return fmt.Errorf("failed to load MCP tools: %w", err)
 	}
 
@@ -120,18 +224,98 @@ func (c *StdioClient) Stop() error {
 		return nil
 	}
 
+	c.stopLocked()
+	return nil
+}
+
+// stopLocked shuts the MCP server process down and waits for it to exit: its
+// stdin is closed, then it is sent SIGTERM and finally killed, each step
+// after shutdownTimeout. Must be called with mu held.
+func (c *StdioClient) stopLocked() {
 	c.started = false
 
 	if c.stdin != nil {
 		c.stdin.Close()
 	}
+	if c.cmd.Process == nil {
+		return
+	}
 
-	if c.cmd.Process != nil {
-		c.cmd.Process.Kill()
-		c.cmd.Wait()
+	select {
+	case <-c.exited:
+		return
+	case <-time.After(c.shutdownTimeout):
 	}
 
-	return nil
+	// Process.Signal does not support SIGTERM on Windows
+	if err := c.cmd.Process.Signal(syscall.SIGTERM); err == nil {
+		select {
+		case <-c.exited:
+			return
+		case <-time.After(c.shutdownTimeout):
+		}
+	}
+
+	log.Printf("WARN: %s did not exit after %v, killing it", c.logPrefix(), c.shutdownTimeout)
+	c.cmd.Process.Kill()
+	<-c.exited
+}
+
+// logPrefix identifies the server in log lines.
+func (c *StdioClient) logPrefix() string {
+	name := c.name
+	if name == "" {
+		name = filepath.Base(c.command)
+	}
+	return "[mcp:" + name + "]"
+}
+
+// Restart kills the MCP server process (if still running), launches a new one
+// and re-lists its tools.
+func (c *StdioClient) Restart() error {
+	c.mu.Lock()
+	if c.started {
+		c.stopLocked()
+	}
+	c.mu.Unlock()
+
+	return c.Start()
+}
+
+// Ping checks that the MCP server process is alive and responding before
+// ctx is done. A server busy with a request is only checked for liveness: it
+// cannot answer a ping before the request ends, which may take longer than
+// ctx allows.
+func (c *StdioClient) Ping(ctx context.Context) error {
+	c.mu.Lock()
+	started, exited := c.started, c.exited
+	c.mu.Unlock()
+
+	if !started {
+		return fmt.Errorf("MCP server not started")
+	}
+	select {
+	case <-exited:
+		return fmt.Errorf("MCP server process exited")
+	default:
+	}
+
+	if !c.rpcMu.TryLock() {
+		return nil
+	}
+	// The call blocks until the server answers or exits: a restart after a
+	// failed check kills the process, which ends the call
+	done := make(chan error, 1)
+	go func() {
+		defer c.rpcMu.Unlock()
+		done <- c.callLocked("ping", nil, nil)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("MCP server did not answer ping: %w", ctx.Err())
+	}
 }
 
 // Tools returns the available tools from the MCP server.
@@ -153,13 +337,20 @@ func (c *StdioClient) GetTool(name string) *Tool {
 	return nil
 }
 
-// CallTool executes a tool with the given arguments.
-// The context is accepted for interface compliance but not used by stdio transport.
-func (c *StdioClient) CallTool(_ context.Context, name string, args map[string]any) (*CallToolResult, error) {
+// CallTool executes a tool with the given arguments. When the context carries
+// a NotifyFunc (see WithNotify), the server's progress updates and log messages
+// are passed to it until the call returns. The context is not otherwise used
+// by stdio transport.
+func (c *StdioClient) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
 	params := CallToolParams{
 		Name:      name,
 		Arguments: args,
 	}
+	if fn := notifyFromContext(ctx); fn != nil {
+		token, done := c.progress.register(name, fn)
+		defer done()
+		params.Meta = &RequestMeta{ProgressToken: token}
+	}
 
 	var result CallToolResult
 	if err := c.call("tools/call", params, &result); err != nil {
@@ -169,7 +360,144 @@ func (c *StdioClient) CallTool(_ context.Context, name string, args map[string]a
 	return &result, nil
 }
 
+// capabilities returns the capabilities the server advertised on initialize.
+func (c *StdioClient) capabilities() ServerCapabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.caps
+}
+
+// ListResources returns the resources published by the server.
+// Servers that do not advertise the resources capability return an empty list.
+func (c *StdioClient) ListResources(_ context.Context) ([]Resource, error) {
+	if c.capabilities().Resources == nil {
+		return nil, nil
+	}
+
+	var result ListResourcesResult
+	if err := c.call("resources/list", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Resources, nil
+}
+
+// ReadResource reads the contents of the resource identified by uri.
+func (c *StdioClient) ReadResource(_ context.Context, uri string) (*ReadResourceResult, error) {
+	var result ReadResourceResult
+	if err := c.call("resources/read", ReadResourceParams{URI: uri}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListPrompts returns the prompt templates published by the server.
+// Servers that do not advertise the prompts capability return an empty list.
+func (c *StdioClient) ListPrompts(_ context.Context) ([]Prompt, error) {
+	if c.capabilities().Prompts == nil {
+		return nil, nil
+	}
+
+	var result ListPromptsResult
+	if err := c.call("prompts/list", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Prompts, nil
+}
+
+// GetPrompt renders the named prompt template with the given arguments.
+func (c *StdioClient) GetPrompt(_ context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	var result GetPromptResult
+	if err := c.call("prompts/get", GetPromptParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RefreshTools re-lists the tools of the MCP server.
+func (c *StdioClient) RefreshTools(_ context.Context) error {
+	var result ListToolsResult
+	if err := c.call("tools/list", nil, &result); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.tools = result.Tools
+	c.mu.Unlock()
+	return nil
+}
+
+// OnToolsChanged registers fn to be called on notifications/tools/list_changed.
+func (c *StdioClient) OnToolsChanged(fn func()) {
+	c.notifyMu.Lock()
+	defer c.notifyMu.Unlock()
+	c.onChange = append(c.onChange, fn)
+}
+
+// handleNotification dispatches a notification received from the server.
+func (c *StdioClient) handleNotification(method string, params json.RawMessage) {
+	if method != methodToolsListChanged {
+		c.progress.handle(c.logPrefix(), method, params)
+		return
+	}
+	c.notifyMu.Lock()
+	defer c.notifyMu.Unlock()
+	for _, fn := range c.onChange {
+		fn()
+	}
+}
+
+// handleRequest answers a request the server sent while a call is in flight.
+// Must be called with rpcMu held. Sampling requests are answered off the read
+// loop, within samplingTimeout, so that notifications keep being read while
+// the LLM works; the server is blocked on the answer meanwhile.
+func (c *StdioClient) handleRequest(id json.RawMessage, method string, params json.RawMessage) {
+	stdin := c.stdin
+	switch {
+	case method == "ping":
+		c.reply(stdin, id, method, struct{}{}, nil)
+	case method == methodCreateMessage && c.sampling != nil:
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), samplingTimeout)
+			defer cancel()
+			result, rpcErr := c.createMessage(ctx, params)
+			c.reply(stdin, id, method, result, rpcErr)
+		}()
+	default:
+		c.reply(stdin, id, method, nil, &RPCError{Code: codeMethodNotFound, Message: "method not found: " + method})
+	}
+}
+
+// createMessage answers a sampling request with the client's SamplingHandler.
+func (c *StdioClient) createMessage(ctx context.Context, params json.RawMessage) (any, *RPCError) {
+	var p CreateMessageParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &RPCError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	r, err := c.progress.sample(ctx, c.logPrefix(), c.sampling, p)
+	if err != nil {
+		return nil, &RPCError{Code: codeInternalError, Message: err.Error()}
+	}
+	return r, nil
+}
+
+// reply sends the answer to a server request to stdin, the pipe of the
+// process that sent it.
+func (c *StdioClient) reply(stdin io.Writer, id json.RawMessage, method string, result any, rpcErr *RPCError) {
+	reply := Response{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			reply.Error = &RPCError{Code: codeInternalError, Message: err.Error()}
+		}
+		reply.Result = data
+	}
+	if err := c.write(stdin, reply); err != nil {
+		log.Printf("WARN: %s failed to answer %s: %v", c.logPrefix(), method, err)
+	}
+}
+
 // initialize sends the initialize request to the MCP server.
+// Must be called with mu held.
 func (c *StdioClient) initialize() error {
 	params := InitializeParams{
 		ProtocolVersion: "2024-11-05",
@@ -179,17 +507,31 @@ func (c *StdioClient) initialize() error {
 		},
 		Capabilities: map[string]any{},
 	}
+	if c.sampling != nil {
+		params.Capabilities = map[string]any{"sampling": map[string]any{}}
+	}
 
 	var result InitializeResult
 	if err := c.call("initialize", params, &result); err != nil {
 		return err
 	}
+	c.caps = result.Capabilities
 
 	// Send initialized notification
-	return c.notify("notifications/initialized", nil)
+	if err := c.notify("notifications/initialized", nil); err != nil {
+		return err
+	}
+
+	if c.caps.Logging != nil {
+		if err := c.call("logging/setLevel", map[string]any{"level": serverLogLevel}, nil); err != nil {
+			log.Printf("WARN: %s logging/setLevel failed: %v", c.logPrefix(), err)
+		}
+	}
+	return nil
 }
 
 // loadTools fetches the available tools from the MCP server.
+// Must be called with mu held.
 func (c *StdioClient) loadTools() error {
 	var result ListToolsResult
 	if err := c.call("tools/list", nil, &result); err != nil {
@@ -201,6 +543,17 @@ func (c *StdioClient) loadTools() error {
 
 // call sends a JSON-RPC request and waits for the response.
 func (c *StdioClient) call(method string, params any, result any) error {
+	c.rpcMu.Lock()
+	defer c.rpcMu.Unlock()
+	return c.callLocked(method, params, result)
+}
+
+// callLocked is call with rpcMu held.
+func (c *StdioClient) callLocked(method string, params any, result any) error {
+	if c.stdin == nil {
+		return fmt.Errorf("MCP server not started")
+	}
+
 	id := c.nextID
 	c.nextID++
 
@@ -235,6 +588,9 @@ func (c *StdioClient) call(method string, params any, result any) error {
 
 // notify sends a JSON-RPC notification (no response expected).
 func (c *StdioClient) notify(method string, params any) error {
+	c.rpcMu.Lock()
+	defer c.rpcMu.Unlock()
+
 	req := Request{
 		JSONRPC: "2.0",
 		ID:      0,
@@ -245,31 +601,149 @@ func (c *StdioClient) notify(method string, params any) error {
 }
 
 // send writes a JSON-RPC message to the MCP server.
+// Must be called with rpcMu held.
 func (c *StdioClient) send(msg any) error {
+	return c.write(c.stdin, msg)
+}
+
+// write writes a JSON-RPC message to stdin. Writes are serialized by writeMu:
+// sampling answers are sent outside of rpcMu.
+func (c *StdioClient) write(stdin io.Writer, msg any) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
 	data = append(data, '\n')
-	if _, err := c.stdin.Write(data); err != nil {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := stdin.Write(data); err != nil {
 		return fmt.Errorf("failed to write to MCP server: %w", err)
 	}
 
 	return nil
 }
 
-// receive reads a JSON-RPC response from the MCP server.
+// receive reads the next JSON-RPC response from the MCP server.
+// Notifications and server requests received in the meantime are handled and skipped.
 func (c *StdioClient) receive() (*Response, error) {
-	line, err := c.stdout.ReadBytes('\n')
-	if err != nil {
-		return nil, fmt.Errorf("failed to read from MCP server: %w", err)
+	for {
+		line, err := c.stdout.ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from MCP server: %w", err)
+		}
+
+		var resp Response
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+
+		if resp.Method != "" {
+			if len(resp.ID) > 0 {
+				c.handleRequest(resp.ID, resp.Method, resp.Params)
+			} else {
+				c.handleNotification(resp.Method, resp.Params)
+			}
+			continue
+		}
+		return &resp, nil
+	}
+}
+
+// baseEnv lists the variables every stdio server receives from the agent's
+// environment, so that commands can be found and behave normally.
+var baseEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_ALL", "LC_CTYPE", "TZ", "TMPDIR", "TERM",
+	// Windows
+	"SYSTEMROOT", "SYSTEMDRIVE", "WINDIR", "COMSPEC", "PATHEXT", "TEMP", "TMP", "USERPROFILE", "APPDATA", "LOCALAPPDATA",
+}
+
+// stdioEnv builds the environment of a stdio server from the agent's
+// environment (as returned by os.Environ): the base variables, the variables
+// matching passthrough, then env. Passthrough entries are exact names,
+// prefixes ending in "*", or "*" for everything.
+func stdioEnv(environ []string, passthrough []string, env map[string]string) []string {
+	keep := func(name string) bool {
+		for _, base := range baseEnv {
+			if strings.EqualFold(name, base) {
+				return true
+			}
+		}
+		for _, p := range passthrough {
+			if prefix, ok := strings.CutSuffix(p, "*"); ok {
+				if strings.HasPrefix(name, prefix) {
+					return true
+				}
+			} else if name == p {
+				return true
+			}
+		}
+		return false
 	}
 
-	var resp Response
-	if err := json.Unmarshal(line, &resp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	var out []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, overridden := env[name]; !overridden && keep(name) {
+			out = append(out, kv)
+		}
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out = append(out, name+"="+env[name])
+	}
+	// An empty, non-nil environment prevents exec from inheriting the agent's
+	if out == nil {
+		out = []string{}
+	}
+	return out
+}
+
+// stderrLogger writes a stdio server's stderr to the log, line by line,
+// with the server name as a prefix.
+type stderrLogger struct {
+	prefix string
+	mu     sync.Mutex
+	buf    []byte
+}
+
+func newStderrLogger(prefix string) *stderrLogger {
+	return &stderrLogger{prefix: prefix}
+}
+
+func (l *stderrLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		l.logLine(l.buf[:i])
+		l.buf = l.buf[i+1:]
 	}
+	return len(p), nil
+}
 
-	return &resp, nil
+// Flush logs a trailing line without a newline.
+func (l *stderrLogger) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buf) > 0 {
+		l.logLine(l.buf)
+		l.buf = nil
+	}
+}
+
+func (l *stderrLogger) logLine(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) > 0 {
+		log.Printf("%s %s", l.prefix, line)
+	}
 }
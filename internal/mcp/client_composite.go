@@ -2,37 +2,155 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"slices"
 	"strings"
 	"sync"
+	"time"
+)
+
+const (
+	maxToolChanges    = 20 // number of tool list changes kept for inspection
+	reconnectMaxDelay = time.Minute
+)
+
+// reconnectMinDelay is the first reconnect backoff delay and healthCheckTimeout
+// bounds a health check ping (variables for tests).
+var (
+	reconnectMinDelay  = time.Second
+	healthCheckTimeout = 10 * time.Second
 )
 
 // NamedClient pairs a Client with its configured server name.
 type NamedClient struct {
 	Name   string
 	Client Client
+	// RefreshInterval re-lists the server's tools periodically, as a fallback
+	// for missed list_changed notifications. Zero disables it.
+	RefreshInterval time.Duration
+	// MaxConcurrency caps the number of in-flight requests to the server.
+	// Zero means unlimited.
+	MaxConcurrency int
+	// HealthInterval pings the server periodically; a failed ping triggers
+	// reconnection with backoff. Zero disables health checks.
+	HealthInterval time.Duration
+	// Optional servers that fail to start do not fail the composite: they are
+	// marked unavailable and retried in the background.
+	Optional bool
+	// ToolPrefix is prepended to the server's tool names, so that servers
+	// exposing the same tool names can be combined. The server still receives
+	// the original name.
+	ToolPrefix string
+}
+
+// NamespacePrefix returns the automatic tool prefix for a server: its name,
+// restricted to characters accepted by all LLM providers, followed by "__".
+func NamespacePrefix(server string) string {
+	var sb strings.Builder
+	for _, r := range server {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String() + "__"
+}
+
+// ServerHealth reports the connection state of one MCP server.
+type ServerHealth struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	LastCheck time.Time `json:"last_check,omitzero"`
+	Restarts  int       `json:"restarts"`
+}
+
+// ToolChange records a change in the tool list of one MCP server.
+type ToolChange struct {
+	Server  string    `json:"server"`
+	Added   []string  `json:"added,omitempty"`
+	Removed []string  `json:"removed,omitempty"`
+	Time    time.Time `json:"time"`
 }
 
 // CompositeClient implements Client by wrapping multiple sub-clients.
-// It aggregates tools from all sub-clients and routes CallTool to the correct one.
+// It aggregates tools, resources and prompts from all sub-clients and routes
+// CallTool, ReadResource and GetPrompt to the correct one.
 type CompositeClient struct {
-	clients []NamedClient
-	tools   []Tool
-	toolMap map[string]int // tool name → index into clients
-	mu      sync.Mutex     // serializes CallTool for thread safety
-	started bool
+	clients     []NamedClient
+	tools       []Tool
+	toolMap     map[string]toolRoute // exposed tool name → owning client
+	resourceMap map[string]int       // resource URI → index into clients
+	promptMap   map[string]int       // prompt name → index into clients
+	changes     []ToolChange         // most recent tool list changes, oldest first
+	onChange    []func()
+	slots       []chan struct{} // per-client request semaphores (nil = unlimited)
+	health      []ServerHealth  // per-client connection state
+	wake        []chan struct{} // per-client requests for an immediate health check
+	mu          sync.Mutex      // protects the fields above; not held during requests
+	started     bool
+	done        chan struct{} // closed on Stop to end periodic refreshes
+}
+
+// toolRoute locates a tool exposed by the composite on its sub-client.
+type toolRoute struct {
+	client int    // index into clients
+	name   string // tool name on the server
 }
 
 // NewCompositeClient creates a CompositeClient wrapping the given named clients.
+// It subscribes to tool list changes of every sub-client.
 func NewCompositeClient(clients []NamedClient) *CompositeClient {
-	return &CompositeClient{
-		clients: clients,
-		toolMap: make(map[string]int),
+	c := &CompositeClient{
+		clients:     clients,
+		toolMap:     make(map[string]toolRoute),
+		resourceMap: make(map[string]int),
+		promptMap:   make(map[string]int),
+		slots:       make([]chan struct{}, len(clients)),
+		health:      make([]ServerHealth, len(clients)),
+		wake:        make([]chan struct{}, len(clients)),
+	}
+	for i, nc := range clients {
+		c.health[i] = ServerHealth{Name: nc.Name}
+		c.wake[i] = make(chan struct{}, 1)
+		nc.Client.OnToolsChanged(func() { go c.refreshServer(i) })
+		if nc.MaxConcurrency > 0 {
+			c.slots[i] = make(chan struct{}, nc.MaxConcurrency)
+		}
+	}
+	return c
+}
+
+// acquire waits for a free request slot on client i.
+// The returned function releases the slot. Requests to a server that failed
+// its last health check fail fast until it is reconnected.
+func (c *CompositeClient) acquire(ctx context.Context, i int) (func(), error) {
+	c.mu.Lock()
+	h, started := c.health[i], c.started
+	c.mu.Unlock()
+	if started && !h.Healthy {
+		return nil, fmt.Errorf("MCP server %q is unavailable: %s", h.Name, h.Error)
+	}
+
+	slots := c.slots[i]
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for MCP server %q: %w", c.clients[i].Name, ctx.Err())
 	}
 }
 
 // Start starts all sub-clients and loads their tools.
-// If any sub-client fails, all previously started clients are stopped (all-or-nothing).
+// If a required sub-client fails, all previously started clients are stopped.
+// Optional sub-clients that fail are left out (their tools are not listed) and
+// retried in the background; their tools are added once they come up.
 // Duplicate tool names across sub-clients cause Start to fail.
 func (c *CompositeClient) Start() error {
 	c.mu.Lock()
@@ -43,37 +161,309 @@ func (c *CompositeClient) Start() error {
 	}
 
 	// Start all sub-clients
+	failed := make([]bool, len(c.clients))
 	for i, nc := range c.clients {
-		if err := nc.Client.Start(); err != nil {
-			// Rollback: stop all previously started clients
-			for j := i - 1; j >= 0; j-- {
-				c.clients[j].Client.Stop()
-			}
-			return fmt.Errorf("failed to start MCP server %q: %w", nc.Name, err)
+		err := nc.Client.Start()
+		if err == nil {
+			continue
 		}
+		if nc.Optional {
+			log.Printf("WARN: optional MCP server %q unavailable, retrying in background: %v", nc.Name, err)
+			failed[i] = true
+			c.health[i].Error = err.Error()
+			c.health[i].LastCheck = time.Now().UTC()
+			continue
+		}
+		// Rollback: stop all previously started clients
+		for j := i - 1; j >= 0; j-- {
+			c.clients[j].Client.Stop()
+		}
+		return fmt.Errorf("failed to start MCP server %q: %w", nc.Name, err)
 	}
 
 	// Aggregate tools and check for duplicates
+	tools, toolMap, err := c.aggregateTools()
+	if err != nil {
+		c.stopAllLocked()
+		return err
+	}
+
+	c.tools = tools
+	c.toolMap = toolMap
+	c.started = true
+	c.done = make(chan struct{})
+
+	for i, nc := range c.clients {
+		c.health[i].Healthy = !failed[i]
+		// Fallback for missed list_changed notifications
+		if nc.RefreshInterval > 0 {
+			go c.refreshPeriodically(i, nc.RefreshInterval, c.done)
+		}
+		go func(done <-chan struct{}) {
+			if failed[i] {
+				c.reconnect(i, done)
+			}
+			if nc.HealthInterval > 0 {
+				c.monitor(i, nc.HealthInterval, done)
+			}
+		}(c.done)
+	}
+	return nil
+}
+
+// monitor pings client i every interval (or when woken by a failed request)
+// and reconnects it when the ping fails, until done is closed.
+func (c *CompositeClient) monitor(i int, interval time.Duration, done <-chan struct{}) {
+	nc := c.clients[i]
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		case <-c.wake[i]:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+		err := nc.Client.Ping(ctx)
+		cancel()
+
+		c.setHealth(i, err)
+		if err != nil {
+			log.Printf("WARN: MCP server %q health check failed: %v", nc.Name, err)
+			c.reconnect(i, done)
+		}
+	}
+}
+
+// reconnect restarts client i with exponential backoff until it succeeds or done is closed.
+// On success the merged tool set is rebuilt from the re-listed tools.
+func (c *CompositeClient) reconnect(i int, done <-chan struct{}) {
+	nc := c.clients[i]
+	delay := reconnectMinDelay
+
+	for attempt := 1; ; attempt++ {
+		err := nc.Client.Restart()
+		if err == nil {
+			log.Printf("MCP server %q reconnected (attempt %d)", nc.Name, attempt)
+			c.mu.Lock()
+			c.health[i].Restarts++
+			c.mu.Unlock()
+			c.setHealth(i, nil)
+			// The server may publish other resources and prompts than before
+			c.rebuildTools(true)
+			return
+		}
+
+		log.Printf("WARN: MCP server %q reconnect attempt %d failed: %v, retrying in %v", nc.Name, attempt, err, delay)
+		c.setHealth(i, err)
+
+		select {
+		case <-done:
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, reconnectMaxDelay)
+	}
+}
+
+// setHealth records the outcome of a health check or reconnect attempt for client i.
+func (c *CompositeClient) setHealth(i int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h := &c.health[i]
+	h.LastCheck = time.Now().UTC()
+	h.Healthy = err == nil
+	h.Error = ""
+	if err != nil {
+		h.Error = err.Error()
+	}
+}
+
+// checkHealth asks the monitor of client i for an immediate health check.
+func (c *CompositeClient) checkHealth(i int) {
+	select {
+	case c.wake[i] <- struct{}{}:
+	default:
+	}
+}
+
+// Ping pings every sub-client and reports the first failure.
+func (c *CompositeClient) Ping(ctx context.Context) error {
+	for _, nc := range c.clients {
+		if err := nc.Client.Ping(ctx); err != nil {
+			return fmt.Errorf("MCP server %q: %w", nc.Name, err)
+		}
+	}
+	return nil
+}
+
+// Restart restarts every sub-client and rebuilds the merged tool set.
+func (c *CompositeClient) Restart() error {
+	for i, nc := range c.clients {
+		err := nc.Client.Restart()
+		c.setHealth(i, err)
+		if err != nil {
+			return fmt.Errorf("failed to restart MCP server %q: %w", nc.Name, err)
+		}
+	}
+	c.rebuildTools(true)
+	return nil
+}
+
+// Health returns the connection state of every MCP server.
+func (c *CompositeClient) Health() []ServerHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.health)
+}
+
+// aggregateTools merges the tools of all sub-clients, tagging each with its
+// server and applying the server's tool prefix. Duplicate (prefixed) tool
+// names across sub-clients are an error.
+func (c *CompositeClient) aggregateTools() ([]Tool, map[string]toolRoute, error) {
 	var allTools []Tool
-	toolOwner := make(map[string]string) // tool name → server name
+	toolMap := make(map[string]toolRoute)
 
 	for i, nc := range c.clients {
 		for _, tool := range nc.Client.Tools() {
-			if existingServer, ok := toolOwner[tool.Name]; ok {
-				// Duplicate detected: stop all clients and fail
-				c.stopAllLocked()
-				return fmt.Errorf("duplicate tool name %q found in MCP servers %q and %q", tool.Name, existingServer, nc.Name)
+			if nc.ToolPrefix != "" {
+				tool.OriginalName = tool.Name
+				tool.Name = nc.ToolPrefix + tool.Name
+			}
+			if route, ok := toolMap[tool.Name]; ok {
+				return nil, nil, fmt.Errorf("duplicate tool name %q found in MCP servers %q and %q (set tool_prefix to namespace them)", tool.Name, c.clients[route.client].Name, nc.Name)
 			}
-			toolOwner[tool.Name] = nc.Name
 			tool.Server = nc.Name
-			c.toolMap[tool.Name] = i
+			toolMap[tool.Name] = toolRoute{client: i, name: tool.BaseName()}
 			allTools = append(allTools, tool)
 		}
 	}
 
-	c.tools = allTools
-	c.started = true
-	return nil
+	return allTools, toolMap, nil
+}
+
+// refreshPeriodically re-lists the tools of client i every interval until done is closed.
+func (c *CompositeClient) refreshPeriodically(i int, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.refreshServer(i)
+		}
+	}
+}
+
+// refreshServer re-lists the tools of client i and rebuilds the merged tool set.
+func (c *CompositeClient) refreshServer(i int) {
+	nc := c.clients[i]
+
+	c.mu.Lock()
+	ready := c.started && c.health[i].Healthy
+	c.mu.Unlock()
+	if !ready {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpClientTimeout)
+	defer cancel()
+
+	if err := nc.Client.RefreshTools(ctx); err != nil {
+		log.Printf("WARN: failed to refresh tools of MCP server %q: %v", nc.Name, err)
+		return
+	}
+	c.rebuildTools(false)
+}
+
+// rebuildTools re-aggregates the tools of all sub-clients and swaps the merged
+// tool set in one step. If the new lists contain duplicate names, the change is
+// rejected and the previous tool set is kept. The OnToolsChanged callbacks are
+// called when the tool set changed, or always when reconnected is set.
+func (c *CompositeClient) rebuildTools(reconnected bool) {
+	c.mu.Lock()
+
+	if !c.started {
+		c.mu.Unlock()
+		return
+	}
+
+	tools, toolMap, err := c.aggregateTools()
+	if err != nil {
+		c.mu.Unlock()
+		log.Printf("ERROR: MCP tool list change rejected: %v", err)
+		return
+	}
+
+	changes := diffTools(c.clients, c.tools, tools)
+	for _, change := range changes {
+		log.Printf("MCP server %q tools changed: added %v, removed %v", change.Server, change.Added, change.Removed)
+	}
+	c.changes = append(c.changes, changes...)
+	if len(c.changes) > maxToolChanges {
+		c.changes = c.changes[len(c.changes)-maxToolChanges:]
+	}
+
+	c.tools = tools
+	c.toolMap = toolMap
+	callbacks := slices.Clone(c.onChange)
+	c.mu.Unlock()
+
+	if len(changes) > 0 || reconnected {
+		for _, fn := range callbacks {
+			fn()
+		}
+	}
+}
+
+// diffTools returns, per server, the tool names added and removed between two merged tool sets.
+func diffTools(clients []NamedClient, before, after []Tool) []ToolChange {
+	names := func(tools []Tool) map[string]map[string]bool {
+		m := make(map[string]map[string]bool)
+		for _, t := range tools {
+			if m[t.Server] == nil {
+				m[t.Server] = make(map[string]bool)
+			}
+			m[t.Server][t.Name] = true
+		}
+		return m
+	}
+	old, cur := names(before), names(after)
+
+	now := time.Now().UTC()
+	var changes []ToolChange
+	for _, nc := range clients {
+		change := ToolChange{Server: nc.Name, Time: now}
+		for name := range cur[nc.Name] {
+			if !old[nc.Name][name] {
+				change.Added = append(change.Added, name)
+			}
+		}
+		for name := range old[nc.Name] {
+			if !cur[nc.Name][name] {
+				change.Removed = append(change.Removed, name)
+			}
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			slices.Sort(change.Added)
+			slices.Sort(change.Removed)
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// ToolChanges returns the most recent tool list changes, oldest first.
+func (c *CompositeClient) ToolChanges() []ToolChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.changes)
 }
 
 // Stop stops all sub-clients, collecting errors.
@@ -86,6 +476,7 @@ func (c *CompositeClient) Stop() error {
 	}
 
 	c.started = false
+	close(c.done)
 	return c.stopAllLocked()
 }
 
@@ -122,17 +513,183 @@ func (c *CompositeClient) GetTool(name string) *Tool {
 	return nil
 }
 
-// CallTool routes the call to the sub-client that owns the tool.
-// It is serialized with a mutex for thread safety.
+// CallTool routes the call to the sub-client that owns the tool, under the
+// tool's original name on that server. Calls to different servers run
+// concurrently; calls to the same server are limited by its MaxConcurrency.
+// Notifications passed to the context's NotifyFunc are tagged with the server
+// name and the tool name as the caller knows it.
 func (c *CompositeClient) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
 	c.mu.Lock()
-	idx, ok := c.toolMap[name]
+	route, ok := c.toolMap[name]
 	if !ok {
 		c.mu.Unlock()
 		return nil, fmt.Errorf("tool not found: %s", name)
 	}
+	idx := route.client
 	client := c.clients[idx].Client
 	c.mu.Unlock()
 
-	return client.CallTool(ctx, name, args)
+	release, err := c.acquire(ctx, idx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if fn := notifyFromContext(ctx); fn != nil {
+		server := c.clients[idx].Name
+		ctx = WithNotify(ctx, func(n Notification) {
+			n.Server, n.Tool = server, name
+			fn(n)
+		})
+	}
+	result, err := client.CallTool(ctx, route.name, args)
+	var authErr *AuthRequiredError
+	if err != nil && !errors.As(err, &authErr) && ctx.Err() == nil {
+		// Transport-level failure: the server may be down
+		c.checkHealth(idx)
+	}
+	return result, err
+}
+
+// resourceOwner returns the index of the sub-client that published uri.
+func (c *CompositeClient) resourceOwner(uri string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	idx, ok := c.resourceMap[uri]
+	return idx, ok
+}
+
+// promptOwner returns the index of the sub-client that published the named prompt.
+func (c *CompositeClient) promptOwner(name string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	idx, ok := c.promptMap[name]
+	return idx, ok
+}
+
+// RefreshTools re-lists the tools of all available sub-clients and rebuilds the merged tool set.
+func (c *CompositeClient) RefreshTools(ctx context.Context) error {
+	health := c.Health()
+	for i, nc := range c.clients {
+		if !health[i].Healthy {
+			continue
+		}
+		if err := nc.Client.RefreshTools(ctx); err != nil {
+			return fmt.Errorf("failed to refresh tools of MCP server %q: %w", nc.Name, err)
+		}
+	}
+	c.rebuildTools(false)
+	return nil
+}
+
+// OnToolsChanged registers fn to be called after the merged tool set changed
+// or a server was reconnected.
+func (c *CompositeClient) OnToolsChanged(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = append(c.onChange, fn)
+}
+
+// ListResources returns the merged resource list from all sub-clients.
+// Each resource is tagged with the server that published it. A server that
+// fails to list its resources is logged and skipped.
+func (c *CompositeClient) ListResources(ctx context.Context) ([]Resource, error) {
+	var all []Resource
+	owners := make(map[string]int)
+
+	for i, nc := range c.clients {
+		resources, err := nc.Client.ListResources(ctx)
+		if err != nil {
+			log.Printf("WARN: failed to list resources of MCP server %q: %v", nc.Name, err)
+			continue
+		}
+		for _, r := range resources {
+			if _, ok := owners[r.URI]; ok {
+				continue
+			}
+			owners[r.URI] = i
+			r.Server = nc.Name
+			all = append(all, r)
+		}
+	}
+
+	c.mu.Lock()
+	c.resourceMap = owners
+	c.mu.Unlock()
+
+	return all, nil
+}
+
+// ReadResource routes the read to the sub-client that published the URI.
+// The resource list is refreshed once if the URI is not known yet.
+func (c *CompositeClient) ReadResource(ctx context.Context, uri string) (*ReadResourceResult, error) {
+	idx, ok := c.resourceOwner(uri)
+	if !ok {
+		if _, err := c.ListResources(ctx); err != nil {
+			return nil, err
+		}
+		if idx, ok = c.resourceOwner(uri); !ok {
+			return nil, fmt.Errorf("resource not found: %s", uri)
+		}
+	}
+
+	release, err := c.acquire(ctx, idx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return c.clients[idx].Client.ReadResource(ctx, uri)
+}
+
+// ListPrompts returns the merged prompt list from all sub-clients.
+// When several servers publish a prompt with the same name, the first one wins.
+func (c *CompositeClient) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var all []Prompt
+	owners := make(map[string]int)
+
+	for i, nc := range c.clients {
+		prompts, err := nc.Client.ListPrompts(ctx)
+		if err != nil {
+			log.Printf("WARN: failed to list prompts of MCP server %q: %v", nc.Name, err)
+			continue
+		}
+		for _, p := range prompts {
+			if j, ok := owners[p.Name]; ok {
+				log.Printf("WARN: prompt %q of MCP server %q shadowed by MCP server %q", p.Name, nc.Name, c.clients[j].Name)
+				continue
+			}
+			owners[p.Name] = i
+			p.Server = nc.Name
+			all = append(all, p)
+		}
+	}
+
+	c.mu.Lock()
+	c.promptMap = owners
+	c.mu.Unlock()
+
+	return all, nil
+}
+
+// GetPrompt routes the request to the sub-client that published the prompt.
+// The prompt list is refreshed once if the name is not known yet.
+func (c *CompositeClient) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	idx, ok := c.promptOwner(name)
+	if !ok {
+		if _, err := c.ListPrompts(ctx); err != nil {
+			return nil, err
+		}
+		if idx, ok = c.promptOwner(name); !ok {
+			return nil, fmt.Errorf("prompt not found: %s", name)
+		}
+	}
+
+	release, err := c.acquire(ctx, idx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return c.clients[idx].Client.GetPrompt(ctx, name, args)
 }
@@ -4,13 +4,23 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // mockClient is a mock MCP client for testing.
 type mockClient struct {
 	name      string
 	tools     []Tool
+	resources []Resource
+	prompts   []Prompt
+	listErr   error
+	refreshed []Tool // tools returned by the next RefreshTools
+	pingErr   error
+	restarts  int
+	onChange  []func()
+	mu        sync.Mutex
 	startErr  error
 	stopErr   error
 	callFunc  func(ctx context.Context, name string, args map[string]any) (*CallToolResult, error)
@@ -33,6 +43,8 @@ func (m *mockClient) Stop() error {
 }
 
 func (m *mockClient) Tools() []Tool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.tools
 }
 
@@ -46,7 +58,9 @@ func (m *mockClient) GetTool(name string) *Tool {
 }
 
 func (m *mockClient) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	m.mu.Lock()
 	m.callCount++
+	m.mu.Unlock()
 	if m.callFunc != nil {
 		return m.callFunc(ctx, name, args)
 	}
@@ -55,6 +69,81 @@ func (m *mockClient) CallTool(ctx context.Context, name string, args map[string]
 	}, nil
 }
 
+func (m *mockClient) ListResources(context.Context) ([]Resource, error) {
+	return m.resources, m.listErr
+}
+
+func (m *mockClient) ReadResource(_ context.Context, uri string) (*ReadResourceResult, error) {
+	return &ReadResourceResult{
+		Contents: []ResourceContents{{URI: uri, Text: fmt.Sprintf("content from %s", m.name)}},
+	}, nil
+}
+
+func (m *mockClient) ListPrompts(context.Context) ([]Prompt, error) {
+	return m.prompts, m.listErr
+}
+
+func (m *mockClient) GetPrompt(_ context.Context, name string, _ map[string]string) (*GetPromptResult, error) {
+	return &GetPromptResult{
+		Messages: []PromptMessage{{Role: "user", Content: ContentBlock{Type: "text", Text: fmt.Sprintf("%s from %s", name, m.name)}}},
+	}, nil
+}
+
+func (m *mockClient) RefreshTools(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.refreshed != nil {
+		m.tools = m.refreshed
+	}
+	return nil
+}
+
+func (m *mockClient) OnToolsChanged(fn func()) {
+	m.onChange = append(m.onChange, fn)
+}
+
+func (m *mockClient) Ping(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pingErr
+}
+
+func (m *mockClient) Restart() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.startErr != nil {
+		return m.startErr
+	}
+	m.restarts++
+	if m.refreshed != nil {
+		m.tools = m.refreshed
+	}
+	return nil
+}
+
+// notifyToolsChanged simulates a notifications/tools/list_changed from the server.
+func (m *mockClient) notifyToolsChanged(tools []Tool) {
+	m.mu.Lock()
+	m.refreshed = tools
+	m.mu.Unlock()
+	for _, fn := range m.onChange {
+		fn()
+	}
+}
+
+// waitForTools polls until the composite exposes n tools or the deadline passes.
+func waitForTools(t *testing.T, cc *CompositeClient, n int) []Tool {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		tools := cc.Tools()
+		if len(tools) == n || time.Now().After(deadline) {
+			return tools
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
 func TestCompositeClient_StartStop(t *testing.T) {
 	clientA := &mockClient{name: "a", tools: []Tool{{Name: "tool_a"}}}
 	clientB := &mockClient{name: "b", tools: []Tool{{Name: "tool_b"}}}
@@ -243,6 +332,80 @@ func TestCompositeClient_CallToolUnknown(t *testing.T) {
 	}
 }
 
+func TestCompositeClient_OptionalServerStartFailure(t *testing.T) {
+	defer func(d time.Duration) { reconnectMinDelay = d }(reconnectMinDelay)
+	reconnectMinDelay = 10 * time.Millisecond
+
+	clientA := &mockClient{name: "a", tools: []Tool{{Name: "tool_a"}}}
+	clientB := &mockClient{name: "b", startErr: fmt.Errorf("connection refused")}
+
+	cc := NewCompositeClient([]NamedClient{
+		{Name: "server-a", Client: clientA},
+		{Name: "server-b", Client: clientB, Optional: true},
+	})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() should succeed with an unavailable optional server: %v", err)
+	}
+	defer cc.Stop()
+
+	if tools := cc.Tools(); len(tools) != 1 || tools[0].Name != "tool_a" {
+		t.Fatalf("expected only tool_a, got %+v", tools)
+	}
+	if h := cc.Health()[1]; h.Healthy || !strings.Contains(h.Error, "connection refused") {
+		t.Errorf("expected server-b unavailable, got %+v", h)
+	}
+
+	// Server comes up: the background retry adds its tools
+	clientB.mu.Lock()
+	clientB.startErr = nil
+	clientB.refreshed = []Tool{{Name: "tool_b"}}
+	clientB.mu.Unlock()
+
+	if tools := waitForTools(t, cc, 2); len(tools) != 2 {
+		t.Fatalf("expected tool_b to be added, got %+v", tools)
+	}
+	if tool := cc.GetTool("tool_b"); tool == nil || tool.Server != "server-b" {
+		t.Errorf("expected tool_b from server-b, got %+v", tool)
+	}
+	if h := waitForHealth(t, cc, 1, true); !h.Healthy {
+		t.Errorf("expected server-b healthy, got %+v", h)
+	}
+}
+
+func TestCompositeClient_ReconnectNotifies(t *testing.T) {
+	defer func(d time.Duration) { reconnectMinDelay = d }(reconnectMinDelay)
+	reconnectMinDelay = 10 * time.Millisecond
+
+	// A server publishing only resources: its reconnection changes no tool
+	clientA := &mockClient{name: "a", tools: []Tool{{Name: "tool_a"}}}
+	clientB := &mockClient{name: "b", startErr: fmt.Errorf("connection refused")}
+	cc := NewCompositeClient([]NamedClient{
+		{Name: "server-a", Client: clientA},
+		{Name: "server-b", Client: clientB, Optional: true},
+	})
+	changed := make(chan struct{}, 1)
+	cc.OnToolsChanged(func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	clientB.mu.Lock()
+	clientB.startErr = nil
+	clientB.mu.Unlock()
+
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected OnToolsChanged callback after the optional server reconnected")
+	}
+}
+
 func TestCompositeClient_PartialStartFailure(t *testing.T) {
 	clientA := &mockClient{
 		name:  "a",
@@ -329,3 +492,535 @@ func TestCompositeClient_EmptyClients(t *testing.T) {
 		t.Fatal("expected error for CallTool on empty composite")
 	}
 }
+
+func TestCompositeClient_Resources(t *testing.T) {
+	clientA := &mockClient{name: "a", resources: []Resource{{URI: "file:///a.txt", Name: "a.txt"}}}
+	clientB := &mockClient{name: "b", resources: []Resource{{URI: "file:///b.txt", Name: "b.txt"}}}
+	clientC := &mockClient{name: "c", listErr: fmt.Errorf("boom")}
+
+	cc := NewCompositeClient([]NamedClient{
+		{Name: "server-a", Client: clientA},
+		{Name: "server-b", Client: clientB},
+		{Name: "server-c", Client: clientC},
+	})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	resources, err := cc.ListResources(context.Background())
+	if err != nil {
+		t.Fatalf("ListResources() error: %v", err)
+	}
+	if len(resources) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(resources))
+	}
+	if resources[1].Server != "server-b" {
+		t.Errorf("expected server-b, got %q", resources[1].Server)
+	}
+
+	result, err := cc.ReadResource(context.Background(), "file:///b.txt")
+	if err != nil {
+		t.Fatalf("ReadResource() error: %v", err)
+	}
+	if result.Contents[0].Text != "content from b" {
+		t.Errorf("expected read routed to b, got %q", result.Contents[0].Text)
+	}
+
+	if _, err := cc.ReadResource(context.Background(), "file:///missing.txt"); err == nil {
+		t.Error("expected error for unknown resource")
+	}
+}
+
+func TestCompositeClient_ReadResourceWithoutListing(t *testing.T) {
+	clientA := &mockClient{name: "a", resources: []Resource{{URI: "file:///a.txt"}}}
+
+	cc := NewCompositeClient([]NamedClient{{Name: "server-a", Client: clientA}})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	result, err := cc.ReadResource(context.Background(), "file:///a.txt")
+	if err != nil {
+		t.Fatalf("ReadResource() error: %v", err)
+	}
+	if result.Contents[0].Text != "content from a" {
+		t.Errorf("unexpected content %q", result.Contents[0].Text)
+	}
+}
+
+func TestCompositeClient_Prompts(t *testing.T) {
+	clientA := &mockClient{name: "a", prompts: []Prompt{{Name: "summarize"}}}
+	clientB := &mockClient{name: "b", prompts: []Prompt{{Name: "summarize"}, {Name: "review"}}}
+
+	cc := NewCompositeClient([]NamedClient{
+		{Name: "server-a", Client: clientA},
+		{Name: "server-b", Client: clientB},
+	})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	prompts, err := cc.ListPrompts(context.Background())
+	if err != nil {
+		t.Fatalf("ListPrompts() error: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("expected 2 prompts (duplicate shadowed), got %d", len(prompts))
+	}
+
+	tests := []struct {
+		prompt string
+		want   string
+	}{
+		{"summarize", "summarize from a"},
+		{"review", "review from b"},
+	}
+	for _, tt := range tests {
+		result, err := cc.GetPrompt(context.Background(), tt.prompt, nil)
+		if err != nil {
+			t.Fatalf("GetPrompt(%q) error: %v", tt.prompt, err)
+		}
+		if got := result.Messages[0].Content.Text; got != tt.want {
+			t.Errorf("GetPrompt(%q) = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+
+	if _, err := cc.GetPrompt(context.Background(), "missing", nil); err == nil {
+		t.Error("expected error for unknown prompt")
+	}
+}
+
+func TestCompositeClient_ToolsListChanged(t *testing.T) {
+	clientA := &mockClient{name: "a", tools: []Tool{{Name: "tool_a"}}}
+	clientB := &mockClient{name: "b", tools: []Tool{{Name: "tool_b"}}}
+
+	cc := NewCompositeClient([]NamedClient{
+		{Name: "server-a", Client: clientA},
+		{Name: "server-b", Client: clientB},
+	})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	changed := make(chan struct{}, 1)
+	cc.OnToolsChanged(func() { changed <- struct{}{} })
+
+	clientB.notifyToolsChanged([]Tool{{Name: "tool_c"}, {Name: "tool_d"}})
+
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for tool list change")
+	}
+
+	tools := cc.Tools()
+	if len(tools) != 3 {
+		t.Fatalf("expected 3 tools, got %d", len(tools))
+	}
+	if cc.GetTool("tool_b") != nil {
+		t.Error("expected tool_b to be removed")
+	}
+	if tool := cc.GetTool("tool_d"); tool == nil || tool.Server != "server-b" {
+		t.Errorf("expected tool_d from server-b, got %+v", tool)
+	}
+	if _, err := cc.CallTool(context.Background(), "tool_c", nil); err != nil {
+		t.Errorf("CallTool(tool_c) error: %v", err)
+	}
+
+	changes := cc.ToolChanges()
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d", len(changes))
+	}
+	want := ToolChange{Server: "server-b", Added: []string{"tool_c", "tool_d"}, Removed: []string{"tool_b"}}
+	got := changes[0]
+	if got.Server != want.Server || fmt.Sprint(got.Added) != fmt.Sprint(want.Added) || fmt.Sprint(got.Removed) != fmt.Sprint(want.Removed) {
+		t.Errorf("change = %+v, want %+v", got, want)
+	}
+}
+
+func TestCompositeClient_ToolsListChangedDuplicateRejected(t *testing.T) {
+	clientA := &mockClient{name: "a", tools: []Tool{{Name: "tool_a"}}}
+	clientB := &mockClient{name: "b", tools: []Tool{{Name: "tool_b"}}}
+
+	cc := NewCompositeClient([]NamedClient{
+		{Name: "server-a", Client: clientA},
+		{Name: "server-b", Client: clientB},
+	})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	clientB.notifyToolsChanged([]Tool{{Name: "tool_a"}})
+
+	// The refresh is asynchronous; give it time to be rejected
+	time.Sleep(50 * time.Millisecond)
+
+	if tool := cc.GetTool("tool_b"); tool == nil {
+		t.Error("expected previous tool set to be kept")
+	}
+	if tool := cc.GetTool("tool_a"); tool == nil || tool.Server != "server-a" {
+		t.Errorf("expected tool_a to stay routed to server-a, got %+v", tool)
+	}
+	if len(cc.ToolChanges()) != 0 {
+		t.Error("expected no recorded change")
+	}
+}
+
+func TestCompositeClient_PeriodicRefresh(t *testing.T) {
+	clientA := &mockClient{name: "a", tools: []Tool{{Name: "tool_a"}}}
+
+	cc := NewCompositeClient([]NamedClient{
+		{Name: "server-a", Client: clientA, RefreshInterval: 10 * time.Millisecond},
+	})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	// Server changes its tools without sending a notification
+	clientA.mu.Lock()
+	clientA.refreshed = []Tool{{Name: "tool_a"}, {Name: "tool_new"}}
+	clientA.mu.Unlock()
+
+	tools := waitForTools(t, cc, 2)
+	if len(tools) != 2 {
+		t.Fatalf("expected periodic refresh to pick up 2 tools, got %d", len(tools))
+	}
+}
+
+// slowClient returns a mock whose tool calls take d and that records peak concurrency.
+func slowClient(name string, d time.Duration, peak *int32) *mockClient {
+	var inFlight int32
+	var mu sync.Mutex
+	return &mockClient{
+		name:  name,
+		tools: []Tool{{Name: name + "_tool"}},
+		callFunc: func(context.Context, string, map[string]any) (*CallToolResult, error) {
+			mu.Lock()
+			inFlight++
+			if peak != nil && inFlight > *peak {
+				*peak = inFlight
+			}
+			mu.Unlock()
+
+			time.Sleep(d)
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return &CallToolResult{}, nil
+		},
+	}
+}
+
+func TestCompositeClient_MaxConcurrency(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxConcurrency int
+		wantPeak       int32
+	}{
+		{"serialized", 1, 1},
+		{"limited", 2, 2},
+		{"unlimited", 0, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var peak int32
+			client := slowClient("a", 20*time.Millisecond, &peak)
+			cc := NewCompositeClient([]NamedClient{{Name: "server-a", Client: client, MaxConcurrency: tt.maxConcurrency}})
+			if err := cc.Start(); err != nil {
+				t.Fatalf("Start() error: %v", err)
+			}
+			defer cc.Stop()
+
+			var wg sync.WaitGroup
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := cc.CallTool(context.Background(), "a_tool", nil); err != nil {
+						t.Errorf("CallTool error: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if peak != tt.wantPeak {
+				t.Errorf("peak concurrency = %d, want %d", peak, tt.wantPeak)
+			}
+		})
+	}
+}
+
+func TestCompositeClient_SlowServerDoesNotBlockOthers(t *testing.T) {
+	slow := slowClient("slow", 500*time.Millisecond, nil)
+	fast := slowClient("fast", 0, nil)
+
+	cc := NewCompositeClient([]NamedClient{
+		{Name: "slow", Client: slow, MaxConcurrency: 1},
+		{Name: "fast", Client: fast, MaxConcurrency: 1},
+	})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	go cc.CallTool(context.Background(), "slow_tool", nil)
+	time.Sleep(10 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := cc.CallTool(context.Background(), "fast_tool", nil); err != nil {
+		t.Fatalf("CallTool error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("fast call took %v, blocked by slow server", elapsed)
+	}
+}
+
+func TestCompositeClient_AcquireRespectsContext(t *testing.T) {
+	client := slowClient("a", 200*time.Millisecond, nil)
+	cc := NewCompositeClient([]NamedClient{{Name: "server-a", Client: client, MaxConcurrency: 1}})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	go cc.CallTool(context.Background(), "a_tool", nil)
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := cc.CallTool(ctx, "a_tool", nil)
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("expected deadline error while waiting for a slot, got %v", err)
+	}
+}
+
+// BenchmarkCompositeClient_ParallelCalls simulates parallel pipeline branches
+// calling tools on the same HTTP server (each call takes 1ms). With
+// max_concurrency 1 the branches run one after another; with higher limits
+// they overlap and ns/op drops accordingly.
+func BenchmarkCompositeClient_ParallelCalls(b *testing.B) {
+	for _, limit := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("max_concurrency=%d", limit), func(b *testing.B) {
+			client := slowClient("a", time.Millisecond, nil)
+			cc := NewCompositeClient([]NamedClient{{Name: "server-a", Client: client, MaxConcurrency: limit}})
+			if err := cc.Start(); err != nil {
+				b.Fatalf("Start() error: %v", err)
+			}
+			defer cc.Stop()
+
+			b.SetParallelism(16)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := cc.CallTool(context.Background(), "a_tool", nil); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkCompositeClient_MixedServers simulates a parallel pipeline where one
+// branch hits a slow server (10ms) and the other a fast one (100µs). Fast calls
+// are not held back by the slow server.
+func BenchmarkCompositeClient_MixedServers(b *testing.B) {
+	slow := slowClient("slow", 10*time.Millisecond, nil)
+	fast := slowClient("fast", 100*time.Microsecond, nil)
+	cc := NewCompositeClient([]NamedClient{
+		{Name: "slow", Client: slow, MaxConcurrency: 1},
+		{Name: "fast", Client: fast, MaxConcurrency: 1},
+	})
+	if err := cc.Start(); err != nil {
+		b.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				cc.CallTool(context.Background(), "slow_tool", nil)
+			}
+		}
+	}()
+	defer close(done)
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := cc.CallTool(context.Background(), "fast_tool", nil); err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+// waitForHealth polls until server i reports the wanted health or the deadline passes.
+func waitForHealth(t *testing.T, cc *CompositeClient, i int, healthy bool) ServerHealth {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h := cc.Health()[i]
+		if (h.Healthy == healthy && !h.LastCheck.IsZero()) || time.Now().After(deadline) {
+			return h
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCompositeClient_HealthCheckReconnects(t *testing.T) {
+	client := &mockClient{name: "a", tools: []Tool{{Name: "tool_a"}}}
+	cc := NewCompositeClient([]NamedClient{{Name: "server-a", Client: client, HealthInterval: 10 * time.Millisecond}})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	if h := waitForHealth(t, cc, 0, true); !h.Healthy {
+		t.Fatalf("expected healthy server, got %+v", h)
+	}
+
+	// Server comes back with a new tool after a restart
+	client.mu.Lock()
+	client.pingErr = fmt.Errorf("connection refused")
+	client.refreshed = []Tool{{Name: "tool_a"}, {Name: "tool_b"}}
+	client.mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for cc.Health()[0].Restarts == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	client.mu.Lock()
+	client.pingErr = nil
+	client.mu.Unlock()
+
+	h := cc.Health()[0]
+	if h.Restarts == 0 {
+		t.Fatal("expected server to be restarted")
+	}
+	if tools := waitForTools(t, cc, 2); len(tools) != 2 {
+		t.Errorf("expected tools to be re-listed after restart, got %d", len(tools))
+	}
+	if h := waitForHealth(t, cc, 0, true); !h.Healthy {
+		t.Errorf("expected healthy server after reconnect, got %+v", h)
+	}
+}
+
+func TestCompositeClient_SlowCallPassesHealthCheck(t *testing.T) {
+	defer func(d time.Duration) { healthCheckTimeout = d }(healthCheckTimeout)
+	healthCheckTimeout = 50 * time.Millisecond
+
+	// The tool takes 300ms: several health checks run, and time out, during the call
+	client := newHelperClient(t)
+	cc := NewCompositeClient([]NamedClient{{Name: "helper", Client: client, HealthInterval: 20 * time.Millisecond}})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	if _, err := cc.CallTool(context.Background(), "slow", nil); err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+	if h := waitForHealth(t, cc, 0, true); !h.Healthy || h.Restarts != 0 {
+		t.Errorf("health = %+v, want healthy without restarts", h)
+	}
+}
+
+func TestCompositeClient_UnhealthyServerFailsFast(t *testing.T) {
+	client := &mockClient{name: "a", tools: []Tool{{Name: "tool_a"}}}
+	cc := NewCompositeClient([]NamedClient{{Name: "server-a", Client: client}})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	cc.setHealth(0, fmt.Errorf("connection refused"))
+
+	_, err := cc.CallTool(context.Background(), "tool_a", nil)
+	if err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Fatalf("expected unavailable error, got %v", err)
+	}
+	if client.callCount != 0 {
+		t.Error("expected no call to reach the unhealthy server")
+	}
+
+	cc.setHealth(0, nil)
+	if _, err := cc.CallTool(context.Background(), "tool_a", nil); err != nil {
+		t.Errorf("CallTool after recovery error: %v", err)
+	}
+}
+
+func TestCompositeClient_FailedCallTriggersHealthCheck(t *testing.T) {
+	client := &mockClient{
+		name:  "a",
+		tools: []Tool{{Name: "tool_a"}},
+		callFunc: func(context.Context, string, map[string]any) (*CallToolResult, error) {
+			return nil, fmt.Errorf("connection reset")
+		},
+	}
+	// Long interval: only the failed call can trigger the check
+	cc := NewCompositeClient([]NamedClient{{Name: "server-a", Client: client, HealthInterval: time.Hour}})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer cc.Stop()
+
+	cc.CallTool(context.Background(), "tool_a", nil)
+
+	if h := waitForHealth(t, cc, 0, true); h.LastCheck.IsZero() {
+		t.Error("expected a health check after the failed call")
+	}
+}
+
+func TestCompositeClient_ToolPrefix(t *testing.T) {
+	var gotName string
+	clientA := &mockClient{name: "a", tools: []Tool{{Name: "read_file"}}}
+	clientB := &mockClient{
+		name:  "b",
+		tools: []Tool{{Name: "read_file"}},
+		callFunc: func(_ context.Context, name string, _ map[string]any) (*CallToolResult, error) {
+			gotName = name
+			return &CallToolResult{}, nil
+		},
+	}
+
+	cc := NewCompositeClient([]NamedClient{
+		{Name: "fs-dev", Client: clientA, ToolPrefix: NamespacePrefix("fs-dev")},
+		{Name: "fs.prod", Client: clientB, ToolPrefix: NamespacePrefix("fs.prod")},
+	})
+	if err := cc.Start(); err != nil {
+		t.Fatalf("Start() with prefixes should not report duplicates: %v", err)
+	}
+	defer cc.Stop()
+
+	tool := cc.GetTool("fs_prod__read_file")
+	if tool == nil {
+		t.Fatalf("expected namespaced tool, got %+v", cc.Tools())
+	}
+	if tool.OriginalName != "read_file" || tool.BaseName() != "read_file" || tool.Server != "fs.prod" {
+		t.Errorf("unexpected tool %+v", tool)
+	}
+	if cc.GetTool("read_file") != nil {
+		t.Error("unprefixed name should not be exposed")
+	}
+
+	if _, err := cc.CallTool(context.Background(), "fs_prod__read_file", nil); err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+	if gotName != "read_file" {
+		t.Errorf("server received %q, want original name %q", gotName, "read_file")
+	}
+	if clientA.callCount != 0 {
+		t.Error("call routed to the wrong server")
+	}
+}
@@ -2,6 +2,8 @@ package mcp
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -21,63 +23,110 @@ const (
 	connectMaxRetries = 20 // 20 * 500ms = 10s max wait
 )
 
+// errNotConnected is returned when the server was never reached.
+var errNotConnected = errors.New("MCP server not connected")
+
 // HTTPClient communicates with an MCP server over Streamable HTTP.
 type HTTPClient struct {
-	url     string
-	client  *mcpclient.Client
-	tools   []Tool
-	mu      sync.Mutex
-	started bool
+	url             string
+	name            string
+	outbound        auth.Outbound
+	connectAttempts int // connection attempts on Start
+	client          *mcpclient.Client
+	tools           []Tool
+	caps            mcpgo.ServerCapabilities
+	mu              sync.Mutex
+	started         bool
+	notifyMu        sync.Mutex
+	onChange        []func()
+	progress        progressTracker
+	sampling        SamplingHandler
 }
 
-// NewHTTPClient creates a new HTTP MCP client.
+// NewHTTPClient creates a new HTTP MCP client that forwards the caller's
+// Bearer token. Use NewClient to configure static headers and credentials.
 func NewHTTPClient(url string) *HTTPClient {
-	return &HTTPClient{url: url}
+	return &HTTPClient{url: url, connectAttempts: connectMaxRetries}
 }
 
 // Start connects to the MCP server and loads tools.
-// It retries the connection to handle startup race conditions (e.g., Docker Compose).
+// It retries the connection to handle startup race conditions (e.g., Docker
+// Compose), except for optional servers, which are retried in the background.
 func (c *HTTPClient) Start() error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if c.started {
+	started := c.started
+	c.mu.Unlock()
+	if started {
 		return nil
 	}
 
 	var lastErr error
-	for attempt := range connectMaxRetries {
-		if err := c.connect(); err != nil {
+	for attempt := range c.connectAttempts {
+		s, err := c.connect()
+		if err != nil {
 			lastErr = err
-			if attempt < connectMaxRetries-1 {
+			if attempt < c.connectAttempts-1 {
 				log.Printf("MCP connection attempt %d/%d failed: %v, retrying in %v",
-					attempt+1, connectMaxRetries, err, connectRetryDelay)
+					attempt+1, c.connectAttempts, err, connectRetryDelay)
 				time.Sleep(connectRetryDelay)
 			}
 			continue
 		}
-		c.started = true
+		c.mu.Lock()
+		c.setSessionLocked(s)
+		c.mu.Unlock()
 		return nil
 	}
 
-	return fmt.Errorf("failed to connect to MCP server after %d attempts: %w", connectMaxRetries, lastErr)
+	return fmt.Errorf("failed to connect to MCP server after %d attempts: %w", c.connectAttempts, lastErr)
 }
 
-// bearerHeaderFunc extracts the Bearer token from context and returns it as an Authorization header.
-func bearerHeaderFunc(ctx context.Context) map[string]string {
-	if token := auth.BearerToken(ctx); token != "" {
-		return map[string]string{"Authorization": "Bearer " + token}
-	}
-	return nil
+// httpSession is an initialized connection to the MCP server.
+type httpSession struct {
+	client *mcpclient.Client
+	caps   mcpgo.ServerCapabilities
+	tools  []Tool
 }
 
-// connect attempts a single connection to the MCP server.
-func (c *HTTPClient) connect() error {
-	t, err := transport.NewStreamableHTTP(c.url, transport.WithHTTPHeaderFunc(bearerHeaderFunc))
+// setSessionLocked makes s the current connection. Must be called with mu held.
+func (c *HTTPClient) setSessionLocked(s *httpSession) {
+	c.client = s.client
+	c.caps = s.caps
+	c.tools = s.tools
+	c.started = true
+}
+
+// connect attempts a single connection to the MCP server. It does not touch
+// the current connection, so callers are not blocked during the handshake.
+func (c *HTTPClient) connect() (*httpSession, error) {
+	t, err := transport.NewStreamableHTTP(c.url,
+		// Configured headers, and by default the Bearer token from context
+		transport.WithHTTPHeaderFunc(c.outbound.RequestHeaders),
+		transport.WithContinuousListening(),
+		transport.WithHTTPLogger(&transportLogger{url: c.url}),
+	)
 	if err != nil {
-		return fmt.Errorf("transport error: %w", err)
+		return nil, fmt.Errorf("transport error: %w", err)
+	}
+	var opts []mcpclient.ClientOption
+	if c.sampling != nil {
+		opts = append(opts, mcpclient.WithSamplingHandler(httpSampling{c}))
 	}
-	client := mcpclient.NewClient(t)
+	client := mcpclient.NewClient(t, opts...)
+
+	// Start the transport so server notifications (e.g. tools/list_changed) are delivered
+	if err := client.Start(context.Background()); err != nil {
+		return nil, fmt.Errorf("transport error: %w", err)
+	}
+	client.OnNotification(func(n mcpgo.JSONRPCNotification) {
+		if n.Method == mcpgo.MethodNotificationToolsListChanged {
+			c.toolsChanged()
+			return
+		}
+		if params, err := json.Marshal(n.Params); err == nil {
+			c.progress.handle(c.logPrefix(), n.Method, params)
+		}
+	})
 
 	ctx, cancel := context.WithTimeout(context.Background(), httpClientTimeout)
 	defer cancel()
@@ -90,19 +139,27 @@ func (c *HTTPClient) connect() error {
 	}
 	initReq.Params.Capabilities = mcpgo.ClientCapabilities{}
 
-	if _, err := client.Initialize(ctx, initReq); err != nil {
+	initResult, err := client.Initialize(ctx, initReq)
+	if err != nil {
 		client.Close()
-		return fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	s := &httpSession{client: client, caps: initResult.Capabilities}
+
+	if s.caps.Logging != nil {
+		levelReq := mcpgo.SetLevelRequest{}
+		levelReq.Params.Level = serverLogLevel
+		if err := client.SetLevel(ctx, levelReq); err != nil {
+			log.Printf("WARN: %s logging/setLevel failed: %v", c.logPrefix(), err)
+		}
 	}
 
 	// Load available tools
-	if err := c.loadToolsFrom(ctx, client); err != nil {
+	if s.tools, err = loadToolsFrom(ctx, client); err != nil {
 		client.Close()
-		return fmt.Errorf("failed to load tools: %w", err)
+		return nil, fmt.Errorf("failed to load tools: %w", err)
 	}
-
-	c.client = client
-	return nil
+	return s, nil
 }
 
 // Stop closes the connection to the MCP server.
@@ -118,6 +175,87 @@ func (c *HTTPClient) Stop() error {
 	return c.client.Close()
 }
 
+// Restart reconnects (single attempt), re-lists tools and closes the previous
+// connection. If the reconnection fails, the client is left disconnected.
+func (c *HTTPClient) Restart() error {
+	s, err := c.connect()
+
+	c.mu.Lock()
+	old := c.client
+	if err != nil {
+		c.client = nil
+		c.started = false
+	} else {
+		c.setSessionLocked(s)
+	}
+	c.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return err
+}
+
+// Ping checks that the MCP server is reachable and the session is still valid.
+// An HTTP 401 means the server is up but wants credentials, which counts as healthy.
+func (c *HTTPClient) Ping(ctx context.Context) error {
+	client, _ := c.session()
+	if client == nil {
+		return errNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpClientTimeout)
+	defer cancel()
+
+	if err := client.Ping(ctx); err != nil && !isHTTP401Error(err) {
+		return fmt.Errorf("MCP ping failed: %w", err)
+	}
+	return nil
+}
+
+// httpSampling answers the sampling requests of an HTTP server with the client's SamplingHandler.
+type httpSampling struct {
+	c *HTTPClient
+}
+
+// CreateMessage implements mcpclient.SamplingHandler.
+func (h httpSampling) CreateMessage(ctx context.Context, req mcpgo.CreateMessageRequest) (*mcpgo.CreateMessageResult, error) {
+	// Both sides speak MCP JSON: convert through it
+	data, err := json.Marshal(req.CreateMessageParams)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sampling request: %w", err)
+	}
+	var params CreateMessageParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, fmt.Errorf("invalid sampling request: %w", err)
+	}
+
+	result, err := h.c.progress.sample(ctx, h.c.logPrefix(), h.c.sampling, params)
+	if err != nil {
+		return nil, err
+	}
+	return &mcpgo.CreateMessageResult{
+		SamplingMessage: mcpgo.SamplingMessage{Role: mcpgo.Role(result.Role), Content: result.Content},
+		Model:           result.Model,
+		StopReason:      result.StopReason,
+	}, nil
+}
+
+// logPrefix identifies the server in log lines.
+func (c *HTTPClient) logPrefix() string {
+	if c.name != "" {
+		return "[mcp:" + c.name + "]"
+	}
+	return "[mcp:" + c.url + "]"
+}
+
+// session returns the current connection and the capabilities the server advertised on it.
+func (c *HTTPClient) session() (*mcpclient.Client, mcpgo.ServerCapabilities) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client, c.caps
+}
+
 // Tools returns the available tools.
 func (c *HTTPClient) Tools() []Tool {
 	c.mu.Lock()
@@ -139,6 +277,8 @@ func (c *HTTPClient) GetTool(name string) *Tool {
 
 // CallTool executes a tool with the given arguments.
 // The caller's context is used as a parent so Bearer tokens are available to the transport.
+// When it carries a NotifyFunc (see WithNotify), the server's progress updates
+// and log messages are passed to it until the call returns.
 // Returns AuthRequiredError if the MCP server responds with HTTP 401.
 func (c *HTTPClient) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
 	ctx, cancel := context.WithTimeout(ctx, httpClientTimeout)
@@ -147,8 +287,17 @@ func (c *HTTPClient) CallTool(ctx context.Context, name string, args map[string]
 	req := mcpgo.CallToolRequest{}
 	req.Params.Name = name
 	req.Params.Arguments = args
+	if fn := notifyFromContext(ctx); fn != nil {
+		token, done := c.progress.register(name, fn)
+		defer done()
+		req.Params.Meta = &mcpgo.Meta{ProgressToken: token}
+	}
 
-	result, err := c.client.CallTool(ctx, req)
+	client, _ := c.session()
+	if client == nil {
+		return nil, errNotConnected
+	}
+	result, err := client.CallTool(ctx, req)
 	if err != nil {
 		if isHTTP401Error(err) {
 			log.Printf("WARN: MCP server %s returned HTTP 401 for tool %s", c.url, name)
@@ -160,23 +309,208 @@ func (c *HTTPClient) CallTool(ctx context.Context, name string, args map[string]
 	return adaptCallToolResult(result), nil
 }
 
+// ListResources returns the resources published by the server.
+// Servers that do not advertise the resources capability return an empty list.
+func (c *HTTPClient) ListResources(ctx context.Context) ([]Resource, error) {
+	client, caps := c.session()
+	if client == nil || caps.Resources == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpClientTimeout)
+	defer cancel()
+
+	result, err := client.ListResources(ctx, mcpgo.ListResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("MCP resources/list failed: %w", err)
+	}
+
+	resources := make([]Resource, 0, len(result.Resources))
+	for _, r := range result.Resources {
+		resources = append(resources, Resource{
+			URI:         r.URI,
+			Name:        r.Name,
+			Description: r.Description,
+			MimeType:    r.MIMEType,
+		})
+	}
+	return resources, nil
+}
+
+// ReadResource reads the contents of the resource identified by uri.
+func (c *HTTPClient) ReadResource(ctx context.Context, uri string) (*ReadResourceResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, httpClientTimeout)
+	defer cancel()
+
+	req := mcpgo.ReadResourceRequest{}
+	req.Params.URI = uri
+
+	client, _ := c.session()
+	if client == nil {
+		return nil, errNotConnected
+	}
+	result, err := client.ReadResource(ctx, req)
+	if err != nil {
+		if isHTTP401Error(err) {
+			return nil, &AuthRequiredError{Server: c.url, Tool: uri}
+		}
+		return nil, fmt.Errorf("MCP resources/read failed: %w", err)
+	}
+
+	r := &ReadResourceResult{}
+	for _, content := range result.Contents {
+		switch rc := content.(type) {
+		case mcpgo.TextResourceContents:
+			r.Contents = append(r.Contents, ResourceContents{URI: rc.URI, MimeType: rc.MIMEType, Text: rc.Text})
+		case mcpgo.BlobResourceContents:
+			r.Contents = append(r.Contents, ResourceContents{URI: rc.URI, MimeType: rc.MIMEType, Blob: rc.Blob})
+		}
+	}
+	return r, nil
+}
+
+// ListPrompts returns the prompt templates published by the server.
+// Servers that do not advertise the prompts capability return an empty list.
+func (c *HTTPClient) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	client, caps := c.session()
+	if client == nil || caps.Prompts == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpClientTimeout)
+	defer cancel()
+
+	result, err := client.ListPrompts(ctx, mcpgo.ListPromptsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("MCP prompts/list failed: %w", err)
+	}
+
+	prompts := make([]Prompt, 0, len(result.Prompts))
+	for _, p := range result.Prompts {
+		prompt := Prompt{Name: p.Name, Description: p.Description}
+		for _, a := range p.Arguments {
+			prompt.Arguments = append(prompt.Arguments, PromptArgument{
+				Name:        a.Name,
+				Description: a.Description,
+				Required:    a.Required,
+			})
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts, nil
+}
+
+// GetPrompt renders the named prompt template with the given arguments.
+func (c *HTTPClient) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, httpClientTimeout)
+	defer cancel()
+
+	req := mcpgo.GetPromptRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+
+	client, _ := c.session()
+	if client == nil {
+		return nil, errNotConnected
+	}
+	result, err := client.GetPrompt(ctx, req)
+	if err != nil {
+		if isHTTP401Error(err) {
+			return nil, &AuthRequiredError{Server: c.url, Tool: name}
+		}
+		return nil, fmt.Errorf("MCP prompts/get failed: %w", err)
+	}
+
+	r := &GetPromptResult{Description: result.Description}
+	for _, m := range result.Messages {
+		if block, ok := adaptContent(m.Content); ok {
+			r.Messages = append(r.Messages, PromptMessage{Role: string(m.Role), Content: block})
+		}
+	}
+	return r, nil
+}
+
+// RefreshTools re-lists the tools of the MCP server.
+func (c *HTTPClient) RefreshTools(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, httpClientTimeout)
+	defer cancel()
+
+	client, _ := c.session()
+	if client == nil {
+		return errNotConnected
+	}
+	result, err := client.ListTools(ctx, mcpgo.ListToolsRequest{})
+	if err != nil {
+		return fmt.Errorf("MCP tools/list failed: %w", err)
+	}
+
+	tools := make([]Tool, 0, len(result.Tools))
+	for _, t := range result.Tools {
+		tools = append(tools, adaptTool(t))
+	}
+
+	c.mu.Lock()
+	c.tools = tools
+	c.mu.Unlock()
+	return nil
+}
+
+// OnToolsChanged registers fn to be called on notifications/tools/list_changed.
+func (c *HTTPClient) OnToolsChanged(fn func()) {
+	c.notifyMu.Lock()
+	defer c.notifyMu.Unlock()
+	c.onChange = append(c.onChange, fn)
+}
+
+// toolsChanged invokes the registered tool list change callbacks.
+func (c *HTTPClient) toolsChanged() {
+	c.notifyMu.Lock()
+	defer c.notifyMu.Unlock()
+	for _, fn := range c.onChange {
+		fn()
+	}
+}
+
+// transportLogger routes mcp-go transport logs to the standard logger.
+// Informational messages are dropped and consecutive identical errors are
+// logged once, so a server that rejects the notification stream does not
+// flood the log.
+type transportLogger struct {
+	url     string
+	mu      sync.Mutex
+	lastErr string
+}
+
+func (l *transportLogger) Infof(string, ...any) {}
+
+func (l *transportLogger) Errorf(format string, v ...any) {
+	msg := fmt.Sprintf(format, v...)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if msg == l.lastErr {
+		return
+	}
+	l.lastErr = msg
+	log.Printf("WARN: MCP server %s: %s", l.url, msg)
+}
+
 // isHTTP401Error checks if an error from mcp-go indicates an HTTP 401 Unauthorized response.
 func isHTTP401Error(err error) bool {
 	return strings.Contains(err.Error(), "status 401")
 }
 
 // loadToolsFrom fetches tools from the given client and converts them.
-func (c *HTTPClient) loadToolsFrom(ctx context.Context, client *mcpclient.Client) error {
+func loadToolsFrom(ctx context.Context, client *mcpclient.Client) ([]Tool, error) {
 	result, err := client.ListTools(ctx, mcpgo.ListToolsRequest{})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	c.tools = make([]Tool, 0, len(result.Tools))
+	tools := make([]Tool, 0, len(result.Tools))
 	for _, t := range result.Tools {
-		c.tools = append(c.tools, adaptTool(t))
+		tools = append(tools, adaptTool(t))
 	}
-	return nil
+	return tools, nil
 }
 
 // adaptTool converts an mcp-go Tool to our internal Tool type.
@@ -187,7 +521,7 @@ func adaptTool(t mcpgo.Tool) Tool {
 	}
 
 	// Convert input schema
-	tool.InputSchema = adaptInputSchema(t.InputSchema)
+	tool.InputSchema = adaptInputSchema(t)
 
 	// Extract destructiveHint from annotations.
 	// ReadOnlyHint=true always means non-destructive.
@@ -202,30 +536,22 @@ func adaptTool(t mcpgo.Tool) Tool {
 	return tool
 }
 
-// adaptInputSchema converts an mcp-go ToolInputSchema to our InputSchema.
-func adaptInputSchema(schema mcpgo.ToolInputSchema) InputSchema {
-	is := InputSchema{
-		Type:     schema.Type,
-		Required: schema.Required,
-	}
-
-	if schema.Properties != nil {
-		is.Properties = make(map[string]Property)
-		for name, prop := range schema.Properties {
-			p := Property{}
-			if propMap, ok := prop.(map[string]any); ok {
-				if t, ok := propMap["type"].(string); ok {
-					p.Type = t
-				}
-				if d, ok := propMap["description"].(string); ok {
-					p.Description = d
-				}
-			}
-			is.Properties[name] = p
+// adaptInputSchema converts an mcp-go tool input schema to our InputSchema.
+// The schema is re-decoded from JSON so that every keyword is preserved.
+func adaptInputSchema(t mcpgo.Tool) InputSchema {
+	data := []byte(t.RawInputSchema)
+	if data == nil {
+		var err error
+		if data, err = json.Marshal(t.InputSchema); err != nil {
+			return InputSchema{Type: t.InputSchema.Type, Required: t.InputSchema.Required}
 		}
 	}
-
-	return is
+	schema, err := ParseSchema(data)
+	if err != nil {
+		log.Printf("WARN: tool %s: %v", t.Name, err)
+		return InputSchema{Type: t.InputSchema.Type, Required: t.InputSchema.Required}
+	}
+	return schema
 }
 
 // adaptCallToolResult converts an mcp-go CallToolResult to our internal type.
@@ -235,13 +561,34 @@ func adaptCallToolResult(result *mcpgo.CallToolResult) *CallToolResult {
 	}
 
 	for _, content := range result.Content {
-		if tc, ok := mcpgo.AsTextContent(content); ok {
-			r.Content = append(r.Content, ContentBlock{
-				Type: "text",
-				Text: tc.Text,
-			})
+		if block, ok := adaptContent(content); ok {
+			r.Content = append(r.Content, block)
 		}
 	}
 
 	return r
 }
+
+// adaptContent converts an mcp-go Content to our ContentBlock.
+// Returns false for content types the agent does not know.
+func adaptContent(content mcpgo.Content) (ContentBlock, bool) {
+	switch c := content.(type) {
+	case mcpgo.TextContent:
+		return ContentBlock{Type: "text", Text: c.Text}, true
+	case mcpgo.ImageContent:
+		return ContentBlock{Type: "image", Data: c.Data, MimeType: c.MIMEType}, true
+	case mcpgo.AudioContent:
+		return ContentBlock{Type: "audio", Data: c.Data, MimeType: c.MIMEType}, true
+	case mcpgo.ResourceLink:
+		return ContentBlock{Type: "resource_link", URI: c.URI, Name: c.Name, Description: c.Description, MimeType: c.MIMEType}, true
+	case mcpgo.EmbeddedResource:
+		switch rc := c.Resource.(type) {
+		case mcpgo.TextResourceContents:
+			return ContentBlock{Type: "resource", Resource: &ResourceContents{URI: rc.URI, MimeType: rc.MIMEType, Text: rc.Text}}, true
+		case mcpgo.BlobResourceContents:
+			return ContentBlock{Type: "resource", Resource: &ResourceContents{URI: rc.URI, MimeType: rc.MIMEType, Blob: rc.Blob}}, true
+		}
+	}
+	log.Printf("WARN: dropping unsupported MCP content %T", content)
+	return ContentBlock{}, false
+}
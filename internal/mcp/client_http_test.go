@@ -0,0 +1,227 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"agent-stop-and-go/internal/auth"
+
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newHeaderRecordingServer starts a Streamable HTTP MCP server with an "echo"
+// tool and returns it with a function returning the headers of the last tool call.
+func newHeaderRecordingServer(t *testing.T) (*httptest.Server, func() http.Header) {
+	t.Helper()
+
+	mcpServer := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true))
+	mcpServer.AddTool(mcpgo.NewTool("echo", mcpgo.WithString("text")),
+		func(_ context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+			return mcpgo.NewToolResultText(req.GetString("text", "")), nil
+		})
+	handler := server.NewStreamableHTTPServer(mcpServer)
+
+	var mu sync.Mutex
+	var last http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			mu.Lock()
+			last = r.Header.Clone()
+			mu.Unlock()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+func TestHTTPClient_Auth(t *testing.T) {
+	tests := []struct {
+		name     string
+		outbound auth.Outbound
+		wantAuth string
+	}{
+		{name: "forward", outbound: auth.Outbound{}, wantAuth: "Bearer user-token"},
+		{name: "static", outbound: auth.Outbound{Mode: auth.ModeStatic, Token: "server-token"}, wantAuth: "Bearer server-token"},
+		{name: "none", outbound: auth.Outbound{Mode: auth.ModeNone}, wantAuth: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, lastHeaders := newHeaderRecordingServer(t)
+
+			tt.outbound.Headers = map[string]string{"X-API-Key": "k1"}
+			client, err := NewClient(ClientConfig{URL: srv.URL, Auth: tt.outbound})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := client.Start(); err != nil {
+				t.Fatalf("Start() error: %v", err)
+			}
+			defer client.Stop()
+
+			ctx := auth.WithBearerToken(context.Background(), "user-token")
+			if _, err := client.CallTool(ctx, "echo", map[string]any{"text": "hi"}); err != nil {
+				t.Fatalf("CallTool() error: %v", err)
+			}
+
+			headers := lastHeaders()
+			if got := headers.Get("Authorization"); got != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", got, tt.wantAuth)
+			}
+			if got := headers.Get("X-API-Key"); got != "k1" {
+				t.Errorf("X-API-Key = %q, want %q", got, "k1")
+			}
+		})
+	}
+}
+
+func TestHTTPClient_Notify(t *testing.T) {
+	mcpServer := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true), server.WithLogging())
+	mcpServer.AddTool(mcpgo.NewTool("scan"),
+		func(ctx context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+			srv := server.ServerFromContext(ctx)
+			if req.Params.Meta != nil && req.Params.Meta.ProgressToken != nil {
+				srv.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+					"progressToken": req.Params.Meta.ProgressToken, "progress": 3, "total": 4,
+				})
+			}
+			srv.SendLogMessageToClient(ctx, mcpgo.NewLoggingMessageNotification(mcpgo.LoggingLevelInfo, "scanner", "scanning"))
+			srv.SendLogMessageToClient(ctx, mcpgo.NewLoggingMessageNotification(mcpgo.LoggingLevelDebug, "scanner", "too verbose"))
+			// mcp-go writes notifications from another goroutine: let them precede the result
+			time.Sleep(50 * time.Millisecond)
+			return mcpgo.NewToolResultText("done"), nil
+		})
+	srv := httptest.NewServer(server.NewStreamableHTTPServer(mcpServer))
+	t.Cleanup(srv.Close)
+
+	client, err := NewClient(ClientConfig{Name: "scanner", URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer client.Stop()
+
+	var mu sync.Mutex
+	var got []Notification
+	ctx := WithNotify(context.Background(), func(n Notification) {
+		mu.Lock()
+		got = append(got, n)
+		mu.Unlock()
+	})
+	if _, err := client.CallTool(ctx, "scan", nil); err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []Notification{
+		{Type: "progress", Tool: "scan", Progress: 3, Total: 4},
+		{Type: "log", Tool: "scan", Level: "info", Logger: "scanner", Message: "scanning"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("notifications = %+v, want %+v", got, want)
+	}
+}
+
+func TestHTTPClient_Sampling(t *testing.T) {
+	mcpServer := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true))
+	mcpServer.EnableSampling()
+	mcpServer.AddTool(mcpgo.NewTool("summarize", mcpgo.WithString("text")),
+		func(ctx context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+			sreq := mcpgo.CreateMessageRequest{}
+			sreq.Messages = []mcpgo.SamplingMessage{{Role: mcpgo.RoleUser, Content: mcpgo.NewTextContent(req.GetString("text", ""))}}
+			sreq.MaxTokens = 50
+			result, err := server.ServerFromContext(ctx).RequestSampling(ctx, sreq)
+			if err != nil {
+				return mcpgo.NewToolResultError(err.Error()), nil
+			}
+			text, _ := result.Content.(mcpgo.TextContent)
+			return mcpgo.NewToolResultText(result.Model + ": " + text.Text), nil
+		})
+	srv := httptest.NewServer(server.NewStreamableHTTPServer(mcpServer))
+	t.Cleanup(srv.Close)
+
+	client, err := NewClient(ClientConfig{
+		URL: srv.URL,
+		Sampling: func(_ context.Context, p CreateMessageParams) (*CreateMessageResult, error) {
+			text := fmt.Sprintf("short %s (max %d)", p.Messages[0].Content.Text, p.MaxTokens)
+			return &CreateMessageResult{Role: "assistant", Content: ContentBlock{Type: "text", Text: text}, Model: "test"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer client.Stop()
+
+	result, err := client.CallTool(context.Background(), "summarize", map[string]any{"text": "report"})
+	if err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+	if got, want := result.AsText(), "test: short report (max 50)"; got != want {
+		t.Errorf("result = %q, want %q", got, want)
+	}
+}
+
+func TestHTTPClient_Restart(t *testing.T) {
+	srv, _ := newHeaderRecordingServer(t)
+	client := NewHTTPClient(srv.URL)
+	if err := client.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer client.Stop()
+
+	if err := client.Restart(); err != nil {
+		t.Fatalf("Restart() error: %v", err)
+	}
+	if _, err := client.CallTool(context.Background(), "echo", map[string]any{"text": "hi"}); err != nil {
+		t.Fatalf("CallTool() after restart error: %v", err)
+	}
+
+	// Close would wait for the notification stream of the client
+	srv.CloseClientConnections()
+	srv.Close()
+	if err := client.Restart(); err == nil {
+		t.Fatal("expected Restart() to fail once the server is gone")
+	}
+	if _, err := client.CallTool(context.Background(), "echo", nil); err != errNotConnected {
+		t.Errorf("CallTool() after failed restart error = %v, want %v", err, errNotConnected)
+	}
+	if err := client.Ping(context.Background()); err != errNotConnected {
+		t.Errorf("Ping() after failed restart error = %v, want %v", err, errNotConnected)
+	}
+}
+
+func TestHTTPClient_OptionalStartFailsFast(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	client, err := NewClient(ClientConfig{URL: srv.URL, Optional: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := client.Start(); err == nil {
+		t.Fatal("expected Start() to fail when the server is down")
+	}
+	if elapsed := time.Since(start); elapsed >= connectRetryDelay {
+		t.Errorf("Start() took %v, want a single connection attempt", elapsed)
+	}
+}
@@ -0,0 +1,409 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is not a real test: it is re-executed by the stdio client
+// tests as a minimal MCP server speaking JSON-RPC over stdin/stdout.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	fmt.Fprintln(os.Stderr, "helper started")
+	out := json.NewEncoder(os.Stdout)
+	mute := false
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+
+		var result any
+		switch req.Method {
+		case "notifications/initialized":
+			continue
+		case "initialize":
+			result = map[string]any{"protocolVersion": "2024-11-05", "serverInfo": map[string]any{"name": "helper"}, "capabilities": map[string]any{}}
+		case "tools/list":
+			result = map[string]any{"tools": []map[string]any{{"name": "crash"}, {"name": "echo"}, {"name": "env"}, {"name": "slow"}}}
+		case "ping":
+			if mute {
+				continue
+			}
+			result = map[string]any{}
+		case "tools/call":
+			var params CallToolParams
+			json.Unmarshal(req.Params, &params)
+			switch params.Name {
+			case "crash":
+				os.Exit(1)
+			case "hang":
+				// Never answer, like a stuck server
+				select {}
+			case "slow":
+				// Answer after a delay, like a long-running tool
+				time.Sleep(300 * time.Millisecond)
+			case "mute":
+				// Stop answering pings, like a server stuck between requests
+				mute = true
+			case "env":
+				// Report an environment variable and the working directory
+				wd, _ := os.Getwd()
+				text := fmt.Sprintf("%s|%s", os.Getenv(fmt.Sprint(params.Arguments["name"])), wd)
+				out.Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": map[string]any{"content": []map[string]any{{"type": "text", "text": text}}}})
+				continue
+			}
+			if prompt, ok := params.Arguments["sample"]; ok {
+				// Ask the client's LLM and answer with its completion
+				out.Encode(map[string]any{"jsonrpc": "2.0", "id": "sample-1", "method": "sampling/createMessage", "params": map[string]any{
+					"messages":    []map[string]any{{"role": "user", "content": map[string]any{"type": "text", "text": prompt}}},
+					"maxTokens":   100,
+					"temperature": 0,
+				}})
+				scanner.Scan()
+				var reply Response
+				json.Unmarshal(scanner.Bytes(), &reply)
+				text := string(reply.Result)
+				if reply.Error != nil {
+					text = "error: " + reply.Error.Message
+				}
+				out.Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": map[string]any{"content": []map[string]any{{"type": "text", "text": text}}}})
+				continue
+			}
+			if params.Meta != nil {
+				// Report progress and log a message for callers that asked for updates
+				out.Encode(map[string]any{"jsonrpc": "2.0", "method": "notifications/progress", "params": map[string]any{"progressToken": params.Meta.ProgressToken, "progress": 1, "total": 2, "message": "halfway"}})
+				out.Encode(map[string]any{"jsonrpc": "2.0", "method": "notifications/message", "params": map[string]any{"level": "info", "data": "echoing"}})
+			}
+			// Announce a tool list change before answering
+			out.Encode(map[string]any{"jsonrpc": "2.0", "method": "notifications/tools/list_changed"})
+			result = map[string]any{"content": []map[string]any{{"type": "text", "text": fmt.Sprint(params.Arguments["text"])}}}
+		}
+		out.Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}
+	if os.Getenv("HELPER_IGNORE_EOF") == "1" {
+		// Simulate a server that does not exit when its stdin is closed
+		select {}
+	}
+}
+
+// newHelperClient returns a StdioClient running TestHelperProcess.
+func newHelperClient(t *testing.T) *StdioClient {
+	t.Helper()
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	c := NewStdioClient(os.Args[0], []string{"-test.run=TestHelperProcess"})
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	t.Cleanup(func() { c.Stop() })
+	return c
+}
+
+func TestStdioClient_NotificationDuringCall(t *testing.T) {
+	c := newHelperClient(t)
+
+	changed := make(chan struct{}, 1)
+	c.OnToolsChanged(func() { changed <- struct{}{} })
+
+	result, err := c.CallTool(context.Background(), "echo", map[string]any{"text": "hello"})
+	if err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+	if got := result.AsText(); got != "hello" {
+		t.Errorf("result = %q, want %q", got, "hello")
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Error("expected tools/list_changed callback")
+	}
+}
+
+func TestStdioClient_Notify(t *testing.T) {
+	c := newHelperClient(t)
+
+	var got []Notification
+	ctx := WithNotify(context.Background(), func(n Notification) { got = append(got, n) })
+	if _, err := c.CallTool(ctx, "echo", map[string]any{"text": "hello"}); err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+
+	want := []Notification{
+		{Type: "progress", Tool: "echo", Progress: 1, Total: 2, Message: "halfway"},
+		{Type: "log", Tool: "echo", Level: "info", Message: "echoing"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("notifications = %+v, want %+v", got, want)
+	}
+
+	// Calls without a NotifyFunc do not request progress
+	got = nil
+	if _, err := c.CallTool(context.Background(), "echo", map[string]any{"text": "hello"}); err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("unexpected notifications: %+v", got)
+	}
+}
+
+func TestStdioClient_Sampling(t *testing.T) {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	c, err := NewClient(ClientConfig{
+		Command:        os.Args[0],
+		Args:           []string{"-test.run=TestHelperProcess"},
+		EnvPassthrough: []string{"GO_WANT_*"},
+		Sampling: func(ctx context.Context, p CreateMessageParams) (*CreateMessageResult, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				return nil, fmt.Errorf("sampling context has no deadline")
+			}
+			if p.Temperature == nil || *p.Temperature != 0 {
+				return nil, fmt.Errorf("temperature = %v, want 0", p.Temperature)
+			}
+			text := fmt.Sprintf("%s (max %d)", p.Messages[0].Content.Text, p.MaxTokens)
+			return &CreateMessageResult{Role: "assistant", Content: ContentBlock{Type: "text", Text: text}, Model: "test"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer c.Stop()
+
+	// Sampling runs off the read loop: notifications arrive from another goroutine
+	var mu sync.Mutex
+	var got []Notification
+	ctx := WithNotify(context.Background(), func(n Notification) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, n)
+	})
+	result, err := c.CallTool(ctx, "echo", map[string]any{"sample": "summarize"})
+	if err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	want := `{"role":"assistant","content":{"type":"text","text":"summarize (max 100)"},"model":"test"}`
+	if text := result.AsText(); text != want {
+		t.Errorf("sampling result = %s, want %s", text, want)
+	}
+	if len(got) != 1 || got[0].Type != "sampling" || got[0].Message != "summarize (max 100)" {
+		t.Errorf("notifications = %+v, want one sampling notification", got)
+	}
+
+	// Servers without a sampling handler get an error
+	result, err = newHelperClient(t).CallTool(context.Background(), "echo", map[string]any{"sample": "summarize"})
+	if err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+	if text := result.AsText(); !strings.HasPrefix(text, "error: method not found") {
+		t.Errorf("result without handler = %q, want method not found error", text)
+	}
+}
+
+func TestStdioClient_RestartAfterCrash(t *testing.T) {
+	c := newHelperClient(t)
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() on running server error: %v", err)
+	}
+
+	if _, err := c.CallTool(context.Background(), "crash", nil); err == nil {
+		t.Fatal("expected error when the server crashes")
+	}
+	if err := c.Ping(context.Background()); err == nil {
+		t.Fatal("expected Ping() to fail after crash")
+	}
+
+	if err := c.Restart(); err != nil {
+		t.Fatalf("Restart() error: %v", err)
+	}
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() after restart error: %v", err)
+	}
+	if len(c.Tools()) != 4 {
+		t.Errorf("expected tools to be re-listed, got %d", len(c.Tools()))
+	}
+	if _, err := c.CallTool(context.Background(), "echo", map[string]any{"text": "again"}); err != nil {
+		t.Errorf("CallTool() after restart error: %v", err)
+	}
+}
+
+func TestStdioClient_PingTimeout(t *testing.T) {
+	c := newHelperClient(t)
+	c.shutdownTimeout = 100 * time.Millisecond
+
+	if _, err := c.CallTool(context.Background(), "mute", nil); err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := c.Ping(ctx); err == nil {
+		t.Fatal("expected Ping() to fail while the server is stuck")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Ping() took %v, want it to give up at the context deadline", elapsed)
+	}
+
+	// A restart kills the stuck process and releases the pending ping
+	if err := c.Restart(); err != nil {
+		t.Fatalf("Restart() error: %v", err)
+	}
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() after restart error: %v", err)
+	}
+}
+
+func TestStdioClient_PingWhileBusy(t *testing.T) {
+	c := newHelperClient(t)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.CallTool(context.Background(), "slow", nil)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond) // let the call take the pipes
+
+	// The server cannot answer before the tool ends, but it is alive
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.Ping(ctx); err != nil {
+		t.Errorf("Ping() during a slow call error: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("slow CallTool() error: %v", err)
+	}
+}
+
+func TestStdioClient_EnvAndDir(t *testing.T) {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	t.Setenv("AGENT_SECRET_KEY", "leaked")
+	t.Setenv("HELPER_PASSED", "passed")
+	dir := t.TempDir()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	client, err := NewClient(ClientConfig{
+		Name:           "helper",
+		Command:        os.Args[0],
+		Args:           []string{"-test.run=TestHelperProcess"},
+		Env:            map[string]string{"HELPER_TOKEN": "s3cret"},
+		EnvPassthrough: []string{"GO_WANT_*", "HELPER_PASSED"},
+		Dir:            dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer client.Stop()
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"HELPER_TOKEN", "s3cret"},
+		{"HELPER_PASSED", "passed"},
+		{"AGENT_SECRET_KEY", ""},
+	}
+	for _, tt := range tests {
+		result, err := client.CallTool(context.Background(), "env", map[string]any{"name": tt.name})
+		if err != nil {
+			t.Fatalf("CallTool() error: %v", err)
+		}
+		value, wd, _ := strings.Cut(result.AsText(), "|")
+		if value != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, value, tt.want)
+		}
+		if resolved, _ := filepath.EvalSymlinks(dir); wd != dir && wd != resolved {
+			t.Errorf("working directory = %q, want %q", wd, dir)
+		}
+	}
+
+	client.Stop()
+	if !strings.Contains(logs.String(), "[mcp:helper] helper started") {
+		t.Errorf("stderr not logged with server prefix, log:\n%s", logs.String())
+	}
+}
+
+func TestStdioClient_StopEscalates(t *testing.T) {
+	t.Setenv("HELPER_IGNORE_EOF", "1")
+	c := newHelperClient(t)
+	c.shutdownTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if err := c.Stop(); err != nil {
+		t.Fatalf("Stop() error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Stop() took %v, want the process terminated after the shutdown timeout", elapsed)
+	}
+	select {
+	case <-c.exited:
+	default:
+		t.Error("process still running after Stop()")
+	}
+}
+
+func TestStdioEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/root", "OPENAI_API_KEY=sk", "AWS_REGION=eu", "AWS_SECRET=x", "DEBUG=1"}
+
+	tests := []struct {
+		name        string
+		passthrough []string
+		env         map[string]string
+		want        []string
+	}{
+		{name: "base only", want: []string{"PATH=/bin", "HOME=/root"}},
+		{name: "exact name", passthrough: []string{"DEBUG"}, want: []string{"PATH=/bin", "HOME=/root", "DEBUG=1"}},
+		{name: "prefix", passthrough: []string{"AWS_*"}, want: []string{"PATH=/bin", "HOME=/root", "AWS_REGION=eu", "AWS_SECRET=x"}},
+		{name: "all", passthrough: []string{"*"}, want: environ},
+		{
+			name: "env overrides",
+			env:  map[string]string{"PATH": "/opt/bin", "API_KEY": "k"},
+			want: []string{"HOME=/root", "API_KEY=k", "PATH=/opt/bin"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stdioEnv(environ, tt.passthrough, tt.env)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := stdioEnv(nil, nil, nil); got == nil {
+		t.Error("empty environment must be non-nil so that it is not inherited")
+	}
+}
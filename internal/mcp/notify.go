@@ -0,0 +1,183 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+)
+
+// Server notifications forwarded to the caller of a tool.
+const (
+	methodProgress   = "notifications/progress"
+	methodLogMessage = "notifications/message"
+)
+
+// serverLogLevel is the minimum level of the log messages requested from
+// servers that advertise the logging capability.
+const serverLogLevel = "info"
+
+// Notification is a progress update, log message or sampling request of an
+// MCP server while one of its tools is running.
+type Notification struct {
+	Type     string  `json:"type"` // "progress", "log" or "sampling"
+	Server   string  `json:"server,omitempty"`
+	Tool     string  `json:"tool,omitempty"`
+	Progress float64 `json:"progress,omitempty"` // progress: increases with each update
+	Total    float64 `json:"total,omitempty"`    // progress: 0 when unknown
+	Level    string  `json:"level,omitempty"`    // log: debug, info, notice, warning, error...
+	Logger   string  `json:"logger,omitempty"`   // log: optional logger name
+	Message  string  `json:"message,omitempty"`  // sampling: the completion
+}
+
+// NotifyFunc receives the notifications of the tool calls made with its context.
+// It must not block: it is called from the transport's read loop.
+type NotifyFunc func(Notification)
+
+type notifyKey struct{}
+
+// WithNotify returns a context whose tool calls request progress updates from
+// the server and pass them, with the server's log messages, to fn.
+func WithNotify(ctx context.Context, fn NotifyFunc) context.Context {
+	return context.WithValue(ctx, notifyKey{}, fn)
+}
+
+// notifyFromContext returns the NotifyFunc set by WithNotify, or nil.
+func notifyFromContext(ctx context.Context) NotifyFunc {
+	fn, _ := ctx.Value(notifyKey{}).(NotifyFunc)
+	return fn
+}
+
+// ProgressParams are the params of notifications/progress.
+type ProgressParams struct {
+	ProgressToken any     `json:"progressToken"`
+	Progress      float64 `json:"progress"`
+	Total         float64 `json:"total,omitempty"`
+	Message       string  `json:"message,omitempty"`
+}
+
+// LogMessageParams are the params of notifications/message.
+type LogMessageParams struct {
+	Level  string `json:"level"`
+	Logger string `json:"logger,omitempty"`
+	Data   any    `json:"data"`
+}
+
+// text renders the log data as a message.
+func (p LogMessageParams) text() string {
+	if s, ok := p.Data.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(p.Data)
+	if err != nil {
+		return fmt.Sprint(p.Data)
+	}
+	return string(data)
+}
+
+// inflightCall is a tool call waiting for its result with a progress token.
+type inflightCall struct {
+	tool string
+	fn   NotifyFunc
+}
+
+// progressTracker hands out progress tokens to tool calls and routes the
+// server's notifications to the calls in flight.
+type progressTracker struct {
+	mu    sync.Mutex
+	next  int
+	calls map[string]inflightCall
+}
+
+// register returns a progress token for a call of tool whose notifications go
+// to fn, and a function to call once the call has returned.
+func (t *progressTracker) register(tool string, fn NotifyFunc) (string, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.next++
+	token := "progress-" + strconv.Itoa(t.next)
+	if t.calls == nil {
+		t.calls = make(map[string]inflightCall)
+	}
+	t.calls[token] = inflightCall{tool: tool, fn: fn}
+
+	return token, func() {
+		t.mu.Lock()
+		delete(t.calls, token)
+		t.mu.Unlock()
+	}
+}
+
+// progress logs a progress notification and passes it to the call owning its token.
+// Tokens are compared as text: servers may echo them back as numbers.
+func (t *progressTracker) progress(prefix string, p ProgressParams) {
+	t.mu.Lock()
+	call, ok := t.calls[fmt.Sprint(p.ProgressToken)]
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	line := fmt.Sprintf("%s %s progress %g", prefix, call.tool, p.Progress)
+	if p.Total > 0 {
+		line += fmt.Sprintf("/%g", p.Total)
+	}
+	if p.Message != "" {
+		line += ": " + p.Message
+	}
+	log.Print(line)
+	call.fn(Notification{
+		Type:     "progress",
+		Tool:     call.tool,
+		Progress: p.Progress,
+		Total:    p.Total,
+		Message:  p.Message,
+	})
+}
+
+// logMessage logs a server log message and passes it to every call in flight,
+// since log messages are not tied to a request.
+func (t *progressTracker) logMessage(prefix string, p LogMessageParams) {
+	msg := p.text()
+	if p.Logger != "" {
+		log.Printf("%s %s: %s: %s", prefix, p.Level, p.Logger, msg)
+	} else {
+		log.Printf("%s %s: %s", prefix, p.Level, msg)
+	}
+
+	t.broadcast(Notification{Type: "log", Level: p.Level, Logger: p.Logger, Message: msg})
+}
+
+// broadcast passes a notification that is not tied to a request to every call in flight.
+func (t *progressTracker) broadcast(n Notification) {
+	t.mu.Lock()
+	calls := make([]inflightCall, 0, len(t.calls))
+	for _, call := range t.calls {
+		calls = append(calls, call)
+	}
+	t.mu.Unlock()
+
+	for _, call := range calls {
+		n.Tool = call.tool
+		call.fn(n)
+	}
+}
+
+// handle dispatches a progress or log notification. Other methods are ignored.
+func (t *progressTracker) handle(prefix, method string, params json.RawMessage) {
+	switch method {
+	case methodProgress:
+		var p ProgressParams
+		if err := json.Unmarshal(params, &p); err == nil {
+			t.progress(prefix, p)
+		}
+	case methodLogMessage:
+		var p LogMessageParams
+		if err := json.Unmarshal(params, &p); err == nil {
+			t.logMessage(prefix, p)
+		}
+	}
+}
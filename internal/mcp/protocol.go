@@ -1,8 +1,10 @@
 package mcp
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // JSON-RPC 2.0 protocol types for MCP communication.
@@ -16,13 +18,27 @@ type Request struct {
 }
 
 // Response represents a JSON-RPC 2.0 response.
+// Method and Params are set instead when the server sends a notification, or
+// a request (with an ID) such as sampling/createMessage.
 type Response struct {
 	JSONRPC string          `json:"jsonrpc"`
-	ID      int             `json:"id"`
+	ID      json.RawMessage `json:"id,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   *RPCError       `json:"error,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
 }
 
+// methodToolsListChanged is the notification sent by servers whose tool list changed.
+const methodToolsListChanged = "notifications/tools/list_changed"
+
+// JSON-RPC 2.0 error codes returned to server requests.
+const (
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
 // RPCError represents a JSON-RPC 2.0 error.
 type RPCError struct {
 	Code    int    `json:"code"`
@@ -47,9 +63,9 @@ type ClientInfo struct {
 
 // InitializeResult is returned after initialization.
 type InitializeResult struct {
-	ProtocolVersion string     `json:"protocolVersion"`
-	ServerInfo      ServerInfo `json:"serverInfo"`
-	Capabilities    any        `json:"capabilities"`
+	ProtocolVersion string             `json:"protocolVersion"`
+	ServerInfo      ServerInfo         `json:"serverInfo"`
+	Capabilities    ServerCapabilities `json:"capabilities"`
 }
 
 // ServerInfo identifies the MCP server.
@@ -65,19 +81,15 @@ type Tool struct {
 	InputSchema     InputSchema `json:"inputSchema"`
 	DestructiveHint bool        `json:"destructiveHint,omitempty"`
 	Server          string      `json:"server,omitempty"`
+	OriginalName    string      `json:"originalName,omitempty"` // name on the server when namespaced
 }
 
-// InputSchema defines the JSON schema for tool inputs.
-type InputSchema struct {
-	Type       string              `json:"type"`
-	Properties map[string]Property `json:"properties,omitempty"`
-	Required   []string            `json:"required,omitempty"`
-}
-
-// Property defines a property in the input schema.
-type Property struct {
-	Type        string `json:"type"`
-	Description string `json:"description,omitempty"`
+// BaseName returns the tool's name on its MCP server (without any namespace prefix).
+func (t Tool) BaseName() string {
+	if t.OriginalName != "" {
+		return t.OriginalName
+	}
+	return t.Name
 }
 
 // ListToolsResult is returned by tools/list.
@@ -89,6 +101,13 @@ type ListToolsResult struct {
 type CallToolParams struct {
 	Name      string         `json:"name"`
 	Arguments map[string]any `json:"arguments,omitempty"`
+	Meta      *RequestMeta   `json:"_meta,omitempty"`
+}
+
+// RequestMeta is the metadata of a request. ProgressToken asks the server to
+// send notifications/progress carrying that token while it handles the request.
+type RequestMeta struct {
+	ProgressToken string `json:"progressToken,omitempty"`
 }
 
 // CallToolResult is returned after calling a tool.
@@ -97,10 +116,134 @@ type CallToolResult struct {
 	IsError bool           `json:"isError,omitempty"`
 }
 
+// AsText renders the whole result as text, joining every content block.
+func (r *CallToolResult) AsText() string {
+	parts := make([]string, 0, len(r.Content))
+	for _, block := range r.Content {
+		parts = append(parts, block.AsText())
+	}
+	return strings.Join(parts, "\n")
+}
+
 // ContentBlock represents a piece of content in the result.
 type ContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+	Type        string            `json:"type"`                  // "text", "image", "audio", "resource" or "resource_link"
+	Text        string            `json:"text,omitempty"`        // text
+	Data        string            `json:"data,omitempty"`        // image, audio: base64-encoded bytes
+	MimeType    string            `json:"mimeType,omitempty"`    // image, audio, resource_link
+	Resource    *ResourceContents `json:"resource,omitempty"`    // resource: embedded resource contents
+	URI         string            `json:"uri,omitempty"`         // resource_link
+	Name        string            `json:"name,omitempty"`        // resource_link
+	Description string            `json:"description,omitempty"` // resource_link
+}
+
+// ResourceContents holds the contents of an embedded resource (text or base64 blob).
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// AsText renders the block as text. Text blocks and embedded text resources are
+// returned verbatim; binary content is replaced by a short summary.
+func (b ContentBlock) AsText() string {
+	switch b.Type {
+	case "text":
+		return b.Text
+	case "image", "audio":
+		return fmt.Sprintf("[%s: %s, %d bytes]", b.Type, b.MimeType, decodedSize(b.Data))
+	case "resource":
+		if b.Resource == nil {
+			return "[resource]"
+		}
+		if b.Resource.Blob != "" {
+			return fmt.Sprintf("[resource %s: %s, %d bytes]", b.Resource.URI, b.Resource.MimeType, decodedSize(b.Resource.Blob))
+		}
+		return fmt.Sprintf("[resource %s]\n%s", b.Resource.URI, b.Resource.Text)
+	case "resource_link":
+		if b.Description != "" {
+			return fmt.Sprintf("[resource link %s: %s — %s]", b.URI, b.Name, b.Description)
+		}
+		return fmt.Sprintf("[resource link %s: %s]", b.URI, b.Name)
+	default:
+		return fmt.Sprintf("[unsupported content type %q]", b.Type)
+	}
+}
+
+// decodedSize returns the number of bytes encoded in a padded base64 string.
+func decodedSize(data string) int {
+	padding := strings.Count(data[max(0, len(data)-2):], "=")
+	return base64.StdEncoding.DecodedLen(len(data)) - padding
+}
+
+// Resource represents an MCP resource published by a server.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+	Server      string `json:"server,omitempty"`
+}
+
+// ListResourcesResult is returned by resources/list.
+type ListResourcesResult struct {
+	Resources []Resource `json:"resources"`
+}
+
+// ReadResourceParams is sent to read a resource.
+type ReadResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// ReadResourceResult is returned by resources/read.
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// Prompt represents an MCP prompt template published by a server.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+	Server      string           `json:"server,omitempty"`
+}
+
+// PromptArgument describes an argument accepted by a prompt template.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// ListPromptsResult is returned by prompts/list.
+type ListPromptsResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+// GetPromptParams is sent to render a prompt.
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// GetPromptResult is returned by prompts/get.
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// PromptMessage is a single message of a rendered prompt.
+type PromptMessage struct {
+	Role    string       `json:"role"` // "user" or "assistant"
+	Content ContentBlock `json:"content"`
+}
+
+// ServerCapabilities records which optional MCP features a server advertised at initialization.
+type ServerCapabilities struct {
+	Resources *struct{} `json:"resources,omitempty"`
+	Prompts   *struct{} `json:"prompts,omitempty"`
+	Logging   *struct{} `json:"logging,omitempty"`
 }
 
 // AuthRequiredError is returned when an MCP server responds with HTTP 401 Unauthorized.
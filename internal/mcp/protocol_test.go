@@ -0,0 +1,38 @@
+package mcp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCallToolResultAsText(t *testing.T) {
+	result := &CallToolResult{
+		Content: []ContentBlock{
+			{Type: "text", Text: "first"},
+			{Type: "text", Text: "second"},
+			{Type: "image", Data: "aGVsbG8=", MimeType: "image/png"},
+			{Type: "resource", Resource: &ResourceContents{URI: "file:///README.md", Text: "# Readme"}},
+			{Type: "resource", Resource: &ResourceContents{URI: "file:///logo.png", MimeType: "image/png", Blob: "aGVsbG8="}},
+			{Type: "resource_link", URI: "file:///docs", Name: "docs"},
+		},
+	}
+
+	got := result.AsText()
+	for _, want := range []string{
+		"first\nsecond",
+		"[image: image/png, 5 bytes]",
+		"[resource file:///README.md]\n# Readme",
+		"[resource file:///logo.png: image/png, 5 bytes]",
+		"[resource link file:///docs: docs]",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("AsText() = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestCallToolResultAsTextEmpty(t *testing.T) {
+	if got := (&CallToolResult{}).AsText(); got != "" {
+		t.Errorf("AsText() = %q, want empty", got)
+	}
+}
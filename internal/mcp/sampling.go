@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"context"
+	"log"
+)
+
+// methodCreateMessage is the request sent by servers that want an LLM completion.
+const methodCreateMessage = "sampling/createMessage"
+
+// SamplingMessage is a message of a sampling request.
+type SamplingMessage struct {
+	Role    string       `json:"role"` // "user" or "assistant"
+	Content ContentBlock `json:"content"`
+}
+
+// CreateMessageParams is a server's request for an LLM completion (sampling/createMessage).
+// Model preferences and context inclusion are not supported and ignored.
+type CreateMessageParams struct {
+	Messages      []SamplingMessage `json:"messages"`
+	SystemPrompt  string            `json:"systemPrompt,omitempty"`
+	MaxTokens     int               `json:"maxTokens"`
+	Temperature   *float64          `json:"temperature,omitempty"` // nil when not requested
+	StopSequences []string          `json:"stopSequences,omitempty"`
+}
+
+// CreateMessageResult is the completion returned to the server.
+type CreateMessageResult struct {
+	Role       string       `json:"role"` // always "assistant"
+	Content    ContentBlock `json:"content"`
+	Model      string       `json:"model"`
+	StopReason string       `json:"stopReason,omitempty"`
+}
+
+// SamplingHandler answers the sampling requests of a server. Clients only
+// advertise the sampling capability when they have one.
+type SamplingHandler func(ctx context.Context, params CreateMessageParams) (*CreateMessageResult, error)
+
+// sample answers a sampling request with handler, logs it and reports the
+// completion to the tool calls in flight on the server.
+func (t *progressTracker) sample(ctx context.Context, prefix string, handler SamplingHandler, params CreateMessageParams) (*CreateMessageResult, error) {
+	log.Printf("%s sampling request: %d messages, maxTokens %d", prefix, len(params.Messages), params.MaxTokens)
+
+	result, err := handler(ctx, params)
+	if err != nil {
+		log.Printf("WARN: %s sampling failed: %v", prefix, err)
+		return nil, err
+	}
+	text := result.Content.AsText()
+	log.Printf("%s sampling completed by %s: %d characters", prefix, result.Model, len(text))
+
+	t.broadcast(Notification{Type: "sampling", Message: text})
+	return result, nil
+}
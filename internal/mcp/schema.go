@@ -0,0 +1,569 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxRefDepth bounds the inlining of recursive $ref definitions.
+const maxRefDepth = 8
+
+// Property is a JSON Schema describing a tool argument (or, as InputSchema,
+// all of a tool's arguments). The keywords used by argument coercion,
+// validation and provider translation are decoded into typed fields; any
+// other keyword is kept verbatim in Extra so that the schema survives a
+// round trip. Local $ref pointers (#/$defs/... and #/definitions/...) are
+// inlined when the schema is decoded.
+type Property struct {
+	Type string `json:"type,omitempty"`
+	// Types lists the non-null types of a type union such as
+	// ["string", "integer"]; Type is then the first one.
+	Types       []string `json:"-"`
+	Nullable    bool     `json:"-"` // type ["<type>", "null"] or nullable: true
+	Description string   `json:"description,omitempty"`
+	Enum        []any    `json:"enum,omitempty"`
+	Default     any      `json:"default,omitempty"`
+	Format      string   `json:"format,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Minimum     *float64 `json:"minimum,omitempty"`
+	Maximum     *float64 `json:"maximum,omitempty"`
+	// Exclusive bounds use the numeric form of JSON Schema 2019-09+.
+	ExclusiveMinimum *float64            `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64            `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int                `json:"minLength,omitempty"`
+	MaxLength        *int                `json:"maxLength,omitempty"`
+	MinItems         *int                `json:"minItems,omitempty"`
+	MaxItems         *int                `json:"maxItems,omitempty"`
+	Items            *Property           `json:"items,omitempty"`
+	Properties       map[string]Property `json:"properties,omitempty"`
+	Required         []string            `json:"required,omitempty"`
+	AnyOf            []Property          `json:"anyOf,omitempty"`
+	OneOf            []Property          `json:"oneOf,omitempty"`
+	Extra            map[string]any      `json:"-"`
+}
+
+// InputSchema is the root JSON Schema of a tool's arguments.
+type InputSchema = Property
+
+// typedKeywords are the keywords decoded into Property fields.
+var typedKeywords = []string{
+	"type", "nullable", "description", "enum", "default", "format", "pattern",
+	"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum",
+	"minLength", "maxLength", "minItems", "maxItems",
+	"items", "properties", "required", "anyOf", "oneOf",
+	"$ref", "$defs", "definitions", "$schema",
+}
+
+// MarshalJSON encodes the schema as standard JSON Schema, including the
+// keywords kept in Extra.
+func (p Property) MarshalJSON() ([]byte, error) {
+	type plain Property
+	data, err := json.Marshal(plain(p))
+	if err != nil {
+		return nil, err
+	}
+	if !p.Nullable && len(p.Types) == 0 && len(p.Extra) == 0 {
+		return data, nil
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range p.Extra {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	switch {
+	case len(p.Types) > 0 && p.Nullable:
+		m["type"] = append(slices.Clone(p.Types), "null")
+	case len(p.Types) > 0:
+		m["type"] = p.Types
+	case p.Nullable && p.Type != "":
+		m["type"] = []string{p.Type, "null"}
+	case p.Nullable:
+		m["nullable"] = true
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes a JSON Schema, inlining local $ref definitions.
+func (p *Property) UnmarshalJSON(data []byte) error {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	defs, _ := raw["$defs"].(map[string]any)
+	if defs == nil {
+		defs, _ = raw["definitions"].(map[string]any)
+	}
+	*p = schemaFromMap(raw, defs, 0)
+	return nil
+}
+
+// ParseSchema decodes a JSON Schema document.
+func ParseSchema(data []byte) (Property, error) {
+	var p Property
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Property{}, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return p, nil
+}
+
+func schemaFromMap(raw map[string]any, defs map[string]any, depth int) Property {
+	if ref, ok := raw["$ref"].(string); ok {
+		if resolved, ok := resolveRef(ref, defs); ok && depth < maxRefDepth {
+			p := schemaFromMap(resolved, defs, depth+1)
+			// Sibling keywords of $ref (e.g. description) override the definition
+			rest := maps.Clone(raw)
+			delete(rest, "$ref")
+			if len(rest) > 0 {
+				overlay := schemaFromMap(rest, defs, depth)
+				p = mergeSchema(p, overlay)
+			}
+			return p
+		}
+	}
+
+	p := Property{}
+	switch t := raw["type"].(type) {
+	case string:
+		p.Type = t
+	case []any:
+		for _, v := range t {
+			if s, _ := v.(string); s == "null" {
+				p.Nullable = true
+			} else if s != "" {
+				p.Types = append(p.Types, s)
+			}
+		}
+		if len(p.Types) > 0 {
+			p.Type = p.Types[0]
+		}
+		if len(p.Types) < 2 {
+			p.Types = nil
+		}
+	}
+	if nullable, _ := raw["nullable"].(bool); nullable {
+		p.Nullable = true
+	}
+	p.Description, _ = raw["description"].(string)
+	p.Enum, _ = raw["enum"].([]any)
+	p.Default = raw["default"]
+	p.Format, _ = raw["format"].(string)
+	p.Pattern, _ = raw["pattern"].(string)
+	p.Minimum = floatKeyword(raw, "minimum")
+	p.Maximum = floatKeyword(raw, "maximum")
+	p.ExclusiveMinimum = floatKeyword(raw, "exclusiveMinimum")
+	p.ExclusiveMaximum = floatKeyword(raw, "exclusiveMaximum")
+	// Draft-04 boolean exclusive bounds apply to minimum/maximum
+	if b, _ := raw["exclusiveMinimum"].(bool); b && p.Minimum != nil {
+		p.ExclusiveMinimum, p.Minimum = p.Minimum, nil
+	}
+	if b, _ := raw["exclusiveMaximum"].(bool); b && p.Maximum != nil {
+		p.ExclusiveMaximum, p.Maximum = p.Maximum, nil
+	}
+	p.MinLength = intKeyword(raw, "minLength")
+	p.MaxLength = intKeyword(raw, "maxLength")
+	p.MinItems = intKeyword(raw, "minItems")
+	p.MaxItems = intKeyword(raw, "maxItems")
+
+	if items, ok := raw["items"].(map[string]any); ok {
+		sub := schemaFromMap(items, defs, depth)
+		p.Items = &sub
+	}
+	if props, ok := raw["properties"].(map[string]any); ok {
+		p.Properties = make(map[string]Property, len(props))
+		for name, v := range props {
+			if m, ok := v.(map[string]any); ok {
+				p.Properties[name] = schemaFromMap(m, defs, depth)
+			} else {
+				p.Properties[name] = Property{}
+			}
+		}
+	}
+	if req, ok := raw["required"].([]any); ok {
+		for _, v := range req {
+			if s, ok := v.(string); ok {
+				p.Required = append(p.Required, s)
+			}
+		}
+	}
+	p.AnyOf = schemaList(raw["anyOf"], defs, depth)
+	p.OneOf = schemaList(raw["oneOf"], defs, depth)
+
+	for k, v := range raw {
+		if slices.Contains(typedKeywords, k) {
+			continue
+		}
+		if p.Extra == nil {
+			p.Extra = make(map[string]any)
+		}
+		p.Extra[k] = v
+	}
+	return p
+}
+
+func resolveRef(ref string, defs map[string]any) (map[string]any, bool) {
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		name, ok = strings.CutPrefix(ref, "#/definitions/")
+	}
+	if !ok {
+		return nil, false
+	}
+	def, ok := defs[name].(map[string]any)
+	return def, ok
+}
+
+// mergeSchema overlays the keywords set in b onto a. Untyped keywords are
+// merged one by one.
+func mergeSchema(a, b Property) Property {
+	extra := a.Extra
+	av := reflect.ValueOf(&a).Elem()
+	bv := reflect.ValueOf(b)
+	for i := range av.NumField() {
+		if !bv.Field(i).IsZero() {
+			av.Field(i).Set(bv.Field(i))
+		}
+	}
+	if len(extra) > 0 && len(b.Extra) > 0 {
+		a.Extra = maps.Clone(extra)
+		maps.Copy(a.Extra, b.Extra)
+	}
+	return a
+}
+
+func schemaList(v any, defs map[string]any, depth int) []Property {
+	list, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]Property, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]any); ok {
+			out = append(out, schemaFromMap(m, defs, depth))
+		}
+	}
+	return out
+}
+
+func floatKeyword(raw map[string]any, key string) *float64 {
+	if f, ok := raw[key].(float64); ok {
+		return &f
+	}
+	return nil
+}
+
+func intKeyword(raw map[string]any, key string) *int {
+	if f, ok := raw[key].(float64); ok {
+		n := int(f)
+		return &n
+	}
+	return nil
+}
+
+// types returns the types a value may have: the union, or Type alone.
+func (p *Property) types() []string {
+	if len(p.Types) > 0 {
+		return p.Types
+	}
+	if p.Type != "" {
+		return []string{p.Type}
+	}
+	return nil
+}
+
+// ObjectSchema returns the schema with the type defaulted to "object" and a
+// non-nil property map, as required by LLM function declarations.
+func (p Property) ObjectSchema() Property {
+	if p.Type == "" {
+		p.Type = "object"
+	}
+	if p.Properties == nil {
+		p.Properties = map[string]Property{}
+	}
+	return p
+}
+
+// Coerce converts a value to the schema type where the conversion is lossless
+// and returns the result. LLMs sometimes return numbers for string fields
+// (e.g. IP "192.168.1.100" as float64 3232235876), strings for numbers or
+// booleans, or arrays and objects encoded as JSON strings. Values that cannot
+// be converted are returned unchanged and left to validation.
+func (p *Property) Coerce(v any) any {
+	if v == nil {
+		return nil
+	}
+	if len(p.Types) > 1 {
+		// A union: keep values of any member type, else convert to the first that fits
+		if slices.ContainsFunc(p.Types, func(t string) bool { return matchesType(t, v) }) {
+			return v
+		}
+		for _, t := range p.Types {
+			member := *p
+			member.Type, member.Types = t, nil
+			if c := member.Coerce(v); matchesType(t, c) {
+				return c
+			}
+		}
+		return v
+	}
+	switch p.Type {
+	case "string":
+		switch val := v.(type) {
+		case float64:
+			if val == math.Trunc(val) && !math.IsInf(val, 0) {
+				return fmt.Sprintf("%.0f", val)
+			}
+			return fmt.Sprintf("%g", val)
+		case bool:
+			return fmt.Sprintf("%t", val)
+		}
+	case "number", "integer":
+		if s, ok := v.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				if p.Type == "number" || f == math.Trunc(f) {
+					return f
+				}
+			}
+		}
+	case "boolean":
+		if s, ok := v.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				return b
+			}
+		}
+	case "array":
+		if s, ok := v.(string); ok {
+			var arr []any
+			if err := json.Unmarshal([]byte(s), &arr); err != nil {
+				return v
+			}
+			v = arr
+		}
+		if arr, ok := v.([]any); ok && p.Items != nil {
+			for i := range arr {
+				arr[i] = p.Items.Coerce(arr[i])
+			}
+		}
+	case "object":
+		if s, ok := v.(string); ok {
+			var obj map[string]any
+			if err := json.Unmarshal([]byte(s), &obj); err != nil {
+				return v
+			}
+			v = obj
+		}
+		if obj, ok := v.(map[string]any); ok {
+			for name, sub := range p.Properties {
+				if val, ok := obj[name]; ok {
+					obj[name] = sub.Coerce(val)
+				}
+			}
+		}
+	}
+	return v
+}
+
+// SchemaIssue is one mismatch between a value and a schema.
+type SchemaIssue struct {
+	Path    string `json:"path"` // e.g. "owner.tags[2]", "" for the root
+	Message string `json:"message"`
+}
+
+func (i SchemaIssue) String() string {
+	path := i.Path
+	if path == "" {
+		path = "arguments"
+	}
+	return path + ": " + i.Message
+}
+
+// ValidationError lists the ways a value does not match a schema.
+type ValidationError struct {
+	Issues []SchemaIssue
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		parts[i] = issue.String()
+	}
+	return "invalid arguments: " + strings.Join(parts, "; ")
+}
+
+// Validate checks a decoded JSON value against the schema. It returns a
+// *ValidationError describing every mismatch, or nil. The format keyword is
+// not checked, and oneOf is treated like anyOf.
+func (p *Property) Validate(v any) error {
+	var issues []SchemaIssue
+	p.validate("", v, &issues)
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
+
+func (p *Property) validate(path string, v any, issues *[]SchemaIssue) {
+	report := func(format string, args ...any) {
+		*issues = append(*issues, SchemaIssue{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if v == nil {
+		if p.Type != "" && p.Type != "null" && !p.Nullable {
+			report("must be %s, got null", p.Type)
+		}
+		return
+	}
+
+	if types := p.types(); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return matchesType(t, v) }) {
+		report("must be %s, got %s", strings.Join(types, " or "), jsonType(v))
+		return
+	}
+
+	if len(p.Enum) > 0 && !slices.ContainsFunc(p.Enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+		report("must be one of %s", formatEnum(p.Enum))
+	}
+
+	if alternatives := slices.Concat(p.AnyOf, p.OneOf); len(alternatives) > 0 {
+		matched := slices.ContainsFunc(alternatives, func(alt Property) bool { return alt.Validate(v) == nil })
+		if !matched {
+			report("does not match any of the allowed schemas")
+		}
+	}
+
+	switch val := v.(type) {
+	case float64:
+		if p.Minimum != nil && val < *p.Minimum {
+			report("must be >= %g", *p.Minimum)
+		}
+		if p.Maximum != nil && val > *p.Maximum {
+			report("must be <= %g", *p.Maximum)
+		}
+		if p.ExclusiveMinimum != nil && val <= *p.ExclusiveMinimum {
+			report("must be > %g", *p.ExclusiveMinimum)
+		}
+		if p.ExclusiveMaximum != nil && val >= *p.ExclusiveMaximum {
+			report("must be < %g", *p.ExclusiveMaximum)
+		}
+	case string:
+		n := utf8.RuneCountInString(val)
+		if p.MinLength != nil && n < *p.MinLength {
+			report("must be at least %d characters", *p.MinLength)
+		}
+		if p.MaxLength != nil && n > *p.MaxLength {
+			report("must be at most %d characters", *p.MaxLength)
+		}
+		if p.Pattern != "" {
+			// Patterns using syntax unsupported by Go's RE2 are not checked
+			if re, err := regexp.Compile(p.Pattern); err == nil && !re.MatchString(val) {
+				report("must match pattern %s", p.Pattern)
+			}
+		}
+	case []any:
+		if p.MinItems != nil && len(val) < *p.MinItems {
+			report("must have at least %d items", *p.MinItems)
+		}
+		if p.MaxItems != nil && len(val) > *p.MaxItems {
+			report("must have at most %d items", *p.MaxItems)
+		}
+		if p.Items != nil {
+			for i, item := range val {
+				p.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, issues)
+			}
+		}
+	case map[string]any:
+		for _, name := range p.Required {
+			if _, ok := val[name]; !ok {
+				report("missing required property %q", name)
+			}
+		}
+		names := slices.Sorted(maps.Keys(p.Properties))
+		for _, name := range names {
+			if item, ok := val[name]; ok {
+				sub := p.Properties[name]
+				sub.validate(joinPath(path, name), item, issues)
+			}
+		}
+		if additional, ok := p.Extra["additionalProperties"].(bool); ok && !additional {
+			var unknown []string
+			for name := range val {
+				if _, ok := p.Properties[name]; !ok {
+					unknown = append(unknown, name)
+				}
+			}
+			sort.Strings(unknown)
+			for _, name := range unknown {
+				report("unknown property %q", name)
+			}
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func matchesType(typ string, v any) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f) && !math.IsInf(f, 0)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	// Unknown types are not checked
+	return true
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func formatEnum(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		b, _ := json.Marshal(v)
+		parts[i] = string(b)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,291 @@
+package mcp
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"mode": {"type": "string", "enum": ["fast", "safe"], "default": "safe"},
+		"count": {"type": "integer", "minimum": 1, "maximum": 10},
+		"ratio": {"type": "number", "exclusiveMinimum": 0},
+		"tags": {"type": "array", "items": {"type": "string", "minLength": 2}, "maxItems": 3},
+		"owner": {"$ref": "#/$defs/Person", "description": "Resource owner", "title": "Owner"},
+		"note": {"type": ["string", "null"]},
+		"id": {"type": "string", "pattern": "^[a-z]+-[0-9]+$", "x-internal": true}
+	},
+	"required": ["mode"],
+	"additionalProperties": false,
+	"$defs": {
+		"Person": {
+			"type": "object",
+			"description": "A person",
+			"properties": {"name": {"type": "string"}, "age": {"type": "integer"}},
+			"required": ["name"],
+			"additionalProperties": false
+		}
+	}
+}`
+
+func mustParseSchema(t *testing.T, data string) Property {
+	t.Helper()
+	p, err := ParseSchema([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseSchema() error: %v", err)
+	}
+	return p
+}
+
+func TestParseSchema(t *testing.T) {
+	p := mustParseSchema(t, testSchema)
+
+	if got := p.Properties["mode"].Enum; !reflect.DeepEqual(got, []any{"fast", "safe"}) {
+		t.Errorf("mode.enum = %v", got)
+	}
+	if got := p.Properties["mode"].Default; got != "safe" {
+		t.Errorf("mode.default = %v", got)
+	}
+	if c := p.Properties["count"]; c.Minimum == nil || *c.Minimum != 1 || c.Maximum == nil || *c.Maximum != 10 {
+		t.Errorf("count bounds = %v/%v", c.Minimum, c.Maximum)
+	}
+	if tags := p.Properties["tags"]; tags.Items == nil || tags.Items.Type != "string" || *tags.MaxItems != 3 {
+		t.Errorf("tags = %+v", tags)
+	}
+
+	owner := p.Properties["owner"]
+	if owner.Type != "object" || owner.Properties["name"].Type != "string" {
+		t.Errorf("owner $ref not inlined: %+v", owner)
+	}
+	if owner.Description != "Resource owner" {
+		t.Errorf("owner.description = %q, want sibling keyword to override", owner.Description)
+	}
+	if owner.Extra["title"] != "Owner" || owner.Extra["additionalProperties"] != false {
+		t.Errorf("owner.extra = %v, want the sibling title merged with the definition's keywords", owner.Extra)
+	}
+
+	if note := p.Properties["note"]; note.Type != "string" || !note.Nullable {
+		t.Errorf("note = %+v, want nullable string", note)
+	}
+	if p.Properties["id"].Extra["x-internal"] != true {
+		t.Errorf("unknown keyword not preserved: %+v", p.Properties["id"].Extra)
+	}
+	if _, ok := p.Extra["$defs"]; ok {
+		t.Error("$defs should be consumed by $ref inlining")
+	}
+}
+
+func TestParseSchema_RecursiveRef(t *testing.T) {
+	p := mustParseSchema(t, `{
+		"type": "object",
+		"properties": {"root": {"$ref": "#/$defs/Node"}},
+		"$defs": {"Node": {"type": "object", "properties": {"child": {"$ref": "#/$defs/Node"}}}}
+	}`)
+
+	depth := 0
+	for node := p.Properties["root"]; node.Type == "object"; node = node.Properties["child"] {
+		depth++
+		if depth > maxRefDepth+1 {
+			t.Fatal("recursive $ref was not bounded")
+		}
+	}
+}
+
+func TestProperty_MarshalRoundTrip(t *testing.T) {
+	p := mustParseSchema(t, testSchema)
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+
+	if m["additionalProperties"] != false {
+		t.Errorf("additionalProperties lost: %s", data)
+	}
+	props := m["properties"].(map[string]any)
+	if note := props["note"].(map[string]any); !reflect.DeepEqual(note["type"], []any{"string", "null"}) {
+		t.Errorf("note.type = %v, want [string null]", note["type"])
+	}
+	if id := props["id"].(map[string]any); id["x-internal"] != true || id["pattern"] != "^[a-z]+-[0-9]+$" {
+		t.Errorf("id = %v", id)
+	}
+
+	again := mustParseSchema(t, string(data))
+	if !reflect.DeepEqual(again.Properties["owner"], p.Properties["owner"]) {
+		t.Errorf("owner changed in round trip: %+v", again.Properties["owner"])
+	}
+}
+
+func TestProperty_Coerce(t *testing.T) {
+	p := mustParseSchema(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"count": {"type": "integer"},
+			"ratio": {"type": "number"},
+			"force": {"type": "boolean"},
+			"ids": {"type": "array", "items": {"type": "string"}},
+			"opts": {"type": "object", "properties": {"depth": {"type": "integer"}}}
+		}
+	}`)
+
+	tests := []struct {
+		name string
+		prop string
+		in   any
+		want any
+	}{
+		{"number to string", "name", float64(3232235876), "3232235876"},
+		{"bool to string", "name", true, "true"},
+		{"string to integer", "count", "42", float64(42)},
+		{"fractional string stays for integer", "count", "4.5", "4.5"},
+		{"string to number", "ratio", " 0.25 ", 0.25},
+		{"string to boolean", "force", "false", false},
+		{"invalid boolean unchanged", "force", "maybe", "maybe"},
+		{"array items", "ids", []any{float64(1), "b"}, []any{"1", "b"}},
+		{"JSON string to array", "ids", `[1, "b"]`, []any{"1", "b"}},
+		{"nested object", "opts", map[string]any{"depth": "3"}, map[string]any{"depth": float64(3)}},
+		{"JSON string to object", "opts", `{"depth": "2"}`, map[string]any{"depth": float64(2)}},
+		{"null unchanged", "name", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := map[string]any{tt.prop: tt.in}
+			p.Coerce(args)
+			if got := args[tt.prop]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestProperty_Validate(t *testing.T) {
+	p := mustParseSchema(t, testSchema)
+
+	tests := []struct {
+		name    string
+		args    map[string]any
+		wantErr []string
+	}{
+		{
+			name: "valid",
+			args: map[string]any{
+				"mode": "fast", "count": float64(3), "ratio": 0.5, "tags": []any{"ab", "cd"},
+				"owner": map[string]any{"name": "ann"}, "note": nil, "id": "res-1",
+			},
+		},
+		{name: "missing required", args: map[string]any{}, wantErr: []string{`arguments: missing required property "mode"`}},
+		{name: "enum", args: map[string]any{"mode": "slow"}, wantErr: []string{`mode: must be one of ["fast", "safe"]`}},
+		{name: "wrong type", args: map[string]any{"mode": "fast", "count": "3"}, wantErr: []string{"count: must be integer, got string"}},
+		{name: "not an integer", args: map[string]any{"mode": "fast", "count": 2.5}, wantErr: []string{"count: must be integer, got number"}},
+		{name: "maximum", args: map[string]any{"mode": "fast", "count": float64(11)}, wantErr: []string{"count: must be <= 10"}},
+		{name: "exclusive minimum", args: map[string]any{"mode": "fast", "ratio": float64(0)}, wantErr: []string{"ratio: must be > 0"}},
+		{name: "array items", args: map[string]any{"mode": "fast", "tags": []any{"a"}}, wantErr: []string{"tags[0]: must be at least 2 characters"}},
+		{name: "max items", args: map[string]any{"mode": "fast", "tags": []any{"aa", "bb", "cc", "dd"}}, wantErr: []string{"tags: must have at most 3 items"}},
+		{name: "nested required", args: map[string]any{"mode": "fast", "owner": map[string]any{"age": float64(3)}}, wantErr: []string{`owner: missing required property "name"`}},
+		{name: "null for non-nullable", args: map[string]any{"mode": nil}, wantErr: []string{"mode: must be string, got null"}},
+		{name: "pattern", args: map[string]any{"mode": "fast", "id": "RES"}, wantErr: []string{"id: must match pattern ^[a-z]+-[0-9]+$"}},
+		{name: "additional properties", args: map[string]any{"mode": "fast", "extra": true}, wantErr: []string{`arguments: unknown property "extra"`}},
+		{
+			name:    "multiple issues",
+			args:    map[string]any{"count": float64(0)},
+			wantErr: []string{`missing required property "mode"`, "count: must be >= 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Validate(tt.args)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("error = %v, want *ValidationError", err)
+			}
+			if len(verr.Issues) != len(tt.wantErr) {
+				t.Fatalf("issues = %q, want %d", verr.Issues, len(tt.wantErr))
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProperty_TypeUnion(t *testing.T) {
+	p := mustParseSchema(t, `{"type": ["string", "integer", "null"], "minLength": 2}`)
+
+	if !reflect.DeepEqual(p.Types, []string{"string", "integer"}) || p.Type != "string" || !p.Nullable {
+		t.Fatalf("parsed = %+v, want string/integer union, nullable", p)
+	}
+
+	tests := []struct {
+		name    string
+		value   any
+		wantErr string
+	}{
+		{name: "string", value: "ab"},
+		{name: "integer", value: float64(8080)},
+		{name: "null", value: nil},
+		{name: "string keywords still apply", value: "a", wantErr: "must be at least 2 characters"},
+		{name: "other type", value: true, wantErr: "must be string or integer, got boolean"},
+		{name: "number is not integer", value: 1.5, wantErr: "must be string or integer, got number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Validate(tt.value)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := p.Coerce(float64(8080)); got != float64(8080) {
+		t.Errorf("Coerce(8080) = %#v, want the integer kept", got)
+	}
+	if got := p.Coerce(true); got != "true" {
+		t.Errorf("Coerce(true) = %#v, want \"true\"", got)
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	if !reflect.DeepEqual(m["type"], []any{"string", "integer", "null"}) {
+		t.Errorf("type = %v, want the union kept in a round trip", m["type"])
+	}
+}
+
+func TestProperty_ValidateAnyOf(t *testing.T) {
+	p := mustParseSchema(t, `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`)
+
+	if err := p.Validate("x"); err != nil {
+		t.Errorf("string: unexpected error: %v", err)
+	}
+	if err := p.Validate(float64(2)); err != nil {
+		t.Errorf("integer: unexpected error: %v", err)
+	}
+	if err := p.Validate(true); err == nil {
+		t.Error("boolean: expected error")
+	}
+}
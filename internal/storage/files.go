@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+
+	"agent-stop-and-go/internal/conversation"
+)
+
+func (s *Storage) filePath(id string) string {
+	return filepath.Join(s.dataDir, "files", id)
+}
+
+// SaveFile stores the content of an attachment and its metadata in
+// files/{uuid} and files/{uuid}.json. An empty mimeType is detected from the
+// name's extension, then from the content.
+func (s *Storage) SaveFile(name, mimeType string, data []byte) (conversation.Attachment, error) {
+	if name != "" {
+		name = filepath.Base(name)
+	}
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	file := conversation.Attachment{
+		ID:       uuid.New().String(),
+		Name:     name,
+		MimeType: mimeType,
+		Size:     int64(len(data)),
+	}
+	meta, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return conversation.Attachment{}, fmt.Errorf("failed to marshal file metadata: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Join(s.dataDir, "files"), 0755); err != nil {
+		return conversation.Attachment{}, fmt.Errorf("failed to create files directory: %w", err)
+	}
+	path := s.filePath(file.ID)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return conversation.Attachment{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.WriteFile(path+".json", meta, 0644); err != nil {
+		return conversation.Attachment{}, fmt.Errorf("failed to write file metadata: %w", err)
+	}
+	return file, nil
+}
+
+// File returns the metadata of a stored file.
+func (s *Storage) File(id string) (conversation.Attachment, error) {
+	if uuid.Validate(id) != nil {
+		return conversation.Attachment{}, fmt.Errorf("file not found: %s", id)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(s.filePath(id) + ".json")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conversation.Attachment{}, fmt.Errorf("file not found: %s", id)
+		}
+		return conversation.Attachment{}, fmt.Errorf("failed to read file metadata: %w", err)
+	}
+	var file conversation.Attachment
+	if err := json.Unmarshal(data, &file); err != nil {
+		return conversation.Attachment{}, fmt.Errorf("failed to unmarshal file metadata: %w", err)
+	}
+	return file, nil
+}
+
+// LoadFile returns the content of a stored file.
+func (s *Storage) LoadFile(id string) ([]byte, error) {
+	if uuid.Validate(id) != nil {
+		return nil, fmt.Errorf("file not found: %s", id)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(s.filePath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, nil
+}
+
+// DeleteFile removes a stored file and its metadata.
+func (s *Storage) DeleteFile(id string) error {
+	if uuid.Validate(id) != nil {
+		return fmt.Errorf("file not found: %s", id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.filePath(id)
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file not found: %s", id)
+		}
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	if err := os.Remove(path + ".json"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file metadata: %w", err)
+	}
+	return nil
+}
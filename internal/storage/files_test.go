@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndLoadFile(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	tests := []struct {
+		name     string
+		fileName string
+		mimeType string
+		data     []byte
+		wantName string
+		wantMime string
+	}{
+		{"explicit type", "error.png", "image/png", png, "error.png", "image/png"},
+		{"type from extension", "notes.pdf", "", []byte("%PDF-1.7"), "notes.pdf", "application/pdf"},
+		{"type from content", "", "", png, "", "image/png"},
+		{"path stripped from name", "../../etc/passwd", "", []byte("root:x:0:0"), "passwd", "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := store.SaveFile(tt.fileName, tt.mimeType, tt.data)
+			if err != nil {
+				t.Fatalf("SaveFile() error: %v", err)
+			}
+			if file.Name != tt.wantName || file.MimeType != tt.wantMime || file.Size != int64(len(tt.data)) {
+				t.Errorf("SaveFile() = %+v, want name %q, type %q, size %d", file, tt.wantName, tt.wantMime, len(tt.data))
+			}
+
+			meta, err := store.File(file.ID)
+			if err != nil {
+				t.Fatalf("File() error: %v", err)
+			}
+			if meta != file {
+				t.Errorf("File() = %+v, want %+v", meta, file)
+			}
+			data, err := store.LoadFile(file.ID)
+			if err != nil {
+				t.Fatalf("LoadFile() error: %v", err)
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Errorf("LoadFile() = %q, want %q", data, tt.data)
+			}
+		})
+	}
+
+	for _, id := range []string{"00000000-0000-0000-0000-000000000000", "../conversation_x", ""} {
+		if _, err := store.File(id); err == nil || !strings.Contains(err.Error(), "file not found") {
+			t.Errorf("File(%q) error = %v, want not found", id, err)
+		}
+		if _, err := store.LoadFile(id); err == nil || !strings.Contains(err.Error(), "file not found") {
+			t.Errorf("LoadFile(%q) error = %v, want not found", id, err)
+		}
+	}
+}
+
+func TestDeleteFile(t *testing.T) {
+	store, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	file, err := store.SaveFile("notes.txt", "", []byte("hello"))
+	if err != nil {
+		t.Fatalf("SaveFile() error: %v", err)
+	}
+
+	if err := store.DeleteFile(file.ID); err != nil {
+		t.Fatalf("DeleteFile() error: %v", err)
+	}
+	if _, err := store.File(file.ID); err == nil {
+		t.Error("File() found the deleted file's metadata")
+	}
+	if _, err := store.LoadFile(file.ID); err == nil {
+		t.Error("LoadFile() found the deleted file")
+	}
+	for _, id := range []string{file.ID, "../conversation_x"} {
+		if err := store.DeleteFile(id); err == nil || !strings.Contains(err.Error(), "file not found") {
+			t.Errorf("DeleteFile(%q) error = %v, want not found", id, err)
+		}
+	}
+}